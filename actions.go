@@ -0,0 +1,152 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// actionsManifestPath é o caminho do manifesto com as definições dos comandos de ação
+const actionsManifestPath = "static/gif/actions.json"
+
+// embeddedActionsManifest é o manifesto de ações embutido no binário, usado quando o arquivo não pode ser carregado
+//
+//go:embed static/gif/actions.json
+var embeddedActionsManifest []byte
+
+// gifBaseDir é o diretório base das pastas de GIFs das ações
+const gifBaseDir = "static/gif"
+
+// ActionDefinition descreve um comando de ação com GIF (tapa, chute, beijo, etc.)
+type ActionDefinition struct {
+	Command     string   `json:"command"`     // Nome principal do comando (sem "!")
+	Aliases     []string `json:"aliases"`     // Nomes alternativos do comando
+	Folder      string   `json:"folder"`      // Pasta dentro de static/gif com os GIFs
+	Search      string   `json:"search"`      // Termo de busca em provedores online (padrão: o comando)
+	Verb        string   `json:"verb"`        // Texto da ação (ex: "deu um tapa em")
+	Emoji       string   `json:"emoji"`       // Emoji exibido na legenda
+	SelfText    string   `json:"self_text"`   // Texto usado quando o alvo é o próprio autor
+	Description string   `json:"description"` // Descrição exibida no !help
+	NSFW        bool     `json:"nsfw"`        // Se a ação só pode ser usada em grupos que permitem NSFW
+}
+
+// Usage retorna o texto de uso do comando
+func (a *ActionDefinition) Usage() string {
+	return fmt.Sprintf("!%s @usuario", a.Command)
+}
+
+// defaultActions retorna as ações do manifesto embutido, usadas quando o manifesto não pode ser carregado
+func defaultActions() []*ActionDefinition {
+	actions, err := parseActions(embeddedActionsManifest)
+	if err != nil {
+		log.Error().Err(err).Msg("Manifesto de ações embutido inválido")
+		return nil
+	}
+	return actions
+}
+
+// LoadActions carrega as definições de ações do manifesto JSON
+func LoadActions(path string) ([]*ActionDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler manifesto de ações: %w", err)
+	}
+	return parseActions(data)
+}
+
+// parseActions interpreta e valida o conteúdo de um manifesto de ações
+func parseActions(data []byte) ([]*ActionDefinition, error) {
+	var actions []*ActionDefinition
+	err := json.Unmarshal(data, &actions)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao interpretar manifesto de ações: %w", err)
+	}
+
+	// Validar entradas obrigatórias
+	for i, action := range actions {
+		if action.Command == "" || action.Folder == "" || action.Verb == "" {
+			return nil, fmt.Errorf("ação %d do manifesto sem command, folder ou verb", i+1)
+		}
+		action.Command = strings.ToLower(action.Command)
+	}
+
+	return actions, nil
+}
+
+// loadActionsOrDefault carrega o manifesto de ações e usa as ações padrão em caso de erro
+func loadActionsOrDefault(path string) []*ActionDefinition {
+	actions, err := LoadActions(path)
+	if err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("Usando ações padrão")
+		return dropShadowedActions(defaultActions())
+	}
+
+	actions = dropShadowedActions(actions)
+
+	// Avisar sobre pastas inexistentes (o comando continua funcionando com fallback em texto)
+	for _, action := range actions {
+		folder := filepath.Join(gifBaseDir, action.Folder)
+		if _, err := os.Stat(folder); err != nil {
+			log.Warn().Str("command", action.Command).Str("folder", folder).Msg("Pasta de GIFs da ação não encontrada")
+		}
+	}
+
+	log.Info().Int("actions", len(actions)).Str("path", path).Msg("Ações carregadas do manifesto")
+
+	return actions
+}
+
+// indexActions cria o índice de comandos (nome principal e aliases) para as ações
+// Nomes repetidos ficam com a primeira ação (nome principal antes de alias) e geram um aviso com as duas ações
+func indexActions(actions []*ActionDefinition) map[string]*ActionDefinition {
+	index := make(map[string]*ActionDefinition)
+	add := func(name string, action *ActionDefinition) {
+		if existing, ok := index[name]; ok {
+			if existing != action {
+				log.Warn().Str("name", name).Str("command", existing.Command).Str("ignored", action.Command).Msg("Nome de ação repetido no manifesto; mantendo a primeira ação")
+			}
+			return
+		}
+		index[name] = action
+	}
+	// Nomes principais primeiro, para um alias nunca esconder o comando de outra ação
+	for _, action := range actions {
+		add(action.Command, action)
+	}
+	for _, action := range actions {
+		if index[action.Command] != action {
+			continue // Ação repetida já descartada: seus aliases também ficam de fora
+		}
+		for _, alias := range action.Aliases {
+			add(strings.ToLower(alias), action)
+		}
+	}
+	return index
+}
+
+// dropShadowedActions descarta as ações e aliases com o nome de um comando embutido (ex: "piada")
+// O ProcessCommand trata esses nomes antes das ações, então elas nunca seriam executadas
+func dropShadowedActions(actions []*ActionDefinition) []*ActionDefinition {
+	kept := actions[:0]
+	for _, action := range actions {
+		if builtinCommands[action.Command] {
+			log.Warn().Str("command", action.Command).Msg("Ação do manifesto ignorada: o nome é de um comando do bot")
+			continue
+		}
+
+		aliases := action.Aliases[:0]
+		for _, alias := range action.Aliases {
+			if builtinCommands[strings.ToLower(alias)] {
+				log.Warn().Str("command", action.Command).Str("alias", alias).Msg("Alias de ação ignorado: o nome é de um comando do bot")
+				continue
+			}
+			aliases = append(aliases, alias)
+		}
+		action.Aliases = aliases
+		kept = append(kept, action)
+	}
+	return kept
+}
@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestDefaultActionsMatchManifest(t *testing.T) {
+	actions := defaultActions()
+	if len(actions) == 0 {
+		t.Fatal("defaultActions() vazio, esperava as ações do manifesto embutido")
+	}
+
+	fromFile, err := LoadActions(actionsManifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fromFile) != len(actions) {
+		t.Fatalf("defaultActions() = %d ações, manifesto tem %d", len(actions), len(fromFile))
+	}
+	for i := range actions {
+		if actions[i].Command != fromFile[i].Command || actions[i].Folder != fromFile[i].Folder {
+			t.Errorf("ação %d = %s (%s), manifesto tem %s (%s)", i, actions[i].Command, actions[i].Folder, fromFile[i].Command, fromFile[i].Folder)
+		}
+	}
+}
+
+func TestIndexActions(t *testing.T) {
+	tapa := &ActionDefinition{Command: "tapa", Aliases: []string{"Bofetada", "soco"}}
+	soco := &ActionDefinition{Command: "soco"}
+	outroTapa := &ActionDefinition{Command: "tapa", Aliases: []string{"tabefe"}}
+
+	index := indexActions([]*ActionDefinition{tapa, soco, outroTapa})
+
+	tests := []struct {
+		name string
+		want *ActionDefinition
+	}{
+		{name: "tapa", want: tapa},
+		{name: "bofetada", want: tapa},
+		{name: "soco", want: soco}, // O nome principal vence o alias de outra ação
+		{name: "tabefe"},           // Aliases da ação repetida são descartados junto com ela
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := index[tt.name]; got != tt.want {
+				t.Errorf("index[%q] = %+v, esperava %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+	if len(index) != 3 {
+		t.Errorf("índice com %d nomes, esperava 3", len(index))
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// adminNumbers retorna os números dos administradores do bot (flag -admins), só com dígitos
+func adminNumbers() map[string]bool {
+	numbers := make(map[string]bool)
+	for _, number := range strings.Split(*botAdmins, ",") {
+		number = strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, number)
+		if number != "" {
+			numbers[number] = true
+		}
+	}
+	return numbers
+}
+
+// isBotAdmin informa se o autor da mensagem é administrador do bot
+// Mensagens enviadas pelo próprio número do bot também contam como de administrador
+func isBotAdmin(evt *events.Message) bool {
+	if evt.Info.IsFromMe {
+		return true
+	}
+
+	admins := adminNumbers()
+	// Em grupos com LID o número de telefone vem no endereço alternativo
+	return admins[evt.Info.Sender.User] || (!evt.Info.SenderAlt.IsEmpty() && admins[evt.Info.SenderAlt.User])
+}
+
+// requireAdmin verifica se o autor é administrador e, se não for, avisa no chat
+func (bot *BotClient) requireAdmin(ctx context.Context, evt *events.Message) bool {
+	if isBotAdmin(evt) {
+		return true
+	}
+
+	log.Info().Str("sender", evt.Info.Sender.String()).Msg("Comando de administrador recusado")
+
+	errorMsg := "❌ Este comando é só para administradores do bot."
+	msg := &waProto.Message{
+		Conversation: &errorMsg,
+	}
+	_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao enviar mensagem de erro")
+	}
+	return false
+}
@@ -3,24 +3,26 @@ package main
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
 	"math/rand"
+	"net/http"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 	"unicode"
 
-	"go.mau.fi/whatsmeow"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
-	"google.golang.org/protobuf/proto"
 )
 
 // CommandHandler gerencia comandos especiais
 type CommandHandler struct {
-	// Sem dependências externas - usa arquivos locais
+	actions       []*ActionDefinition          // Ações com GIF na ordem do manifesto
+	actionIndex   map[string]*ActionDefinition // Comando/alias -> ação
+	localProvider *LocalGIFProvider            // Provedor de GIFs das pastas locais
+	gifProvider   GIFProvider                  // Provedor usado pelos comandos de ação
+	stories       storyLocks                   // Locks das histórias colaborativas por grupo
 }
 
 // GroupMessageProcessor processa mensagens provenientes de grupos
@@ -31,48 +33,113 @@ type GroupMessageProcessor struct {
 }
 
 // NewCommandHandler cria um novo gerenciador de comandos
+// As ações com GIF são carregadas do manifesto em static/gif/actions.json
 func NewCommandHandler() *CommandHandler {
-	return &CommandHandler{}
+	actions := loadActionsOrDefault(actionsManifestPath)
+	localProvider := NewLocalGIFProvider(gifBaseDir, NewGIFConverter(gifCacheDir))
+	return &CommandHandler{
+		actions:       actions,
+		actionIndex:   indexActions(actions),
+		localProvider: localProvider,
+		gifProvider:   localProvider,
+	}
 }
 
 // ProcessCommand processa um comando especial
 func (ch *CommandHandler) ProcessCommand(ctx context.Context, command string, args []string, evt *events.Message, bot *BotClient) error {
 	switch strings.ToLower(command) {
-	case "tapa":
-		return ch.handleTapaCommand(ctx, args, evt, bot)
-	case "chute":
-		return ch.handleChuteCommand(ctx, args, evt, bot)
-	case "voadora":
-		return ch.handleVoadoraCommand(ctx, args, evt, bot)
-	case "beijo":
-		return ch.handleBeijoCommand(ctx, args, evt, bot)
-	case "abraco", "abraço":
-		return ch.handleAbracoCommand(ctx, args, evt, bot)
-	case "tiro":
-		return ch.handleTiroCommand(ctx, args, evt, bot)
 	case "piada":
-		return ch.handlePiadaCommand(ctx, evt, bot)
+		return ch.handlePiadaCommand(ctx, args, evt, bot)
 	case "cantada":
 		return ch.handleCantadaCommand(ctx, args, evt, bot)
 	case "historia", "história":
 		return ch.handleHistoriaCommand(ctx, args, evt, bot)
+	case "mais":
+		return bot.handleMaisCommand(ctx, evt.Info.Chat, evt.Info.Sender)
+	case "continuar":
+		return ch.handleContinuarCommand(ctx, args, evt, bot)
+	case "lembrete":
+		return ch.handleLembreteCommand(ctx, args, evt, bot)
+	case "lembretes":
+		return ch.handleLembretesCommand(ctx, evt, bot)
+	case "cancelarlembrete":
+		return ch.handleCancelarLembreteCommand(ctx, args, evt, bot)
 	case "autodestruicao", "autodestruição":
 		return ch.handleAutodestruicaoCommand(ctx, args, evt, bot)
+	case "resumo":
+		return ch.handleResumoCommand(ctx, args, evt, bot)
 	case "roletacasais", "roleta", "casais":
 		return ch.handleRoletaCasaisCommand(ctx, evt, bot)
+	case "imagem", "img":
+		return ch.handleImagemCommand(ctx, args, evt, bot)
+	case "persona":
+		return ch.handlePersonaCommand(ctx, args, evt, bot)
+	case "modelo":
+		return ch.handleModeloCommand(ctx, args, evt, bot)
+	case "uso":
+		return ch.handleUsoCommand(ctx, args, evt, bot)
+	case "cache":
+		return ch.handleCacheCommand(ctx, args, evt, bot)
+	case "faq":
+		return ch.handleFaqCommand(ctx, args, evt, bot)
+	case "nsfw":
+		return ch.handleNSFWCommand(ctx, args, evt, bot)
 	case "help", "ajuda", "menu":
 		return ch.handleHelpCommand(ctx, evt, bot)
 	default:
+		// Comandos de ação definidos no manifesto (tapa, chute, etc.)
+		if action, ok := ch.actionIndex[strings.ToLower(command)]; ok {
+			return ch.handleActionCommand(ctx, args, evt, bot, action)
+		}
 		// Comando não reconhecido
 		return nil
 	}
 }
 
+// builtinCommands são os nomes tratados diretamente pelo ProcessCommand (e o !explique, tratado no eventHandler)
+// Ações do manifesto com esses nomes nunca seriam executadas e são descartadas ao carregar
+var builtinCommands = map[string]bool{
+	"piada": true, "cantada": true, "historia": true, "história": true, "mais": true, "continuar": true,
+	"lembrete": true, "lembretes": true, "cancelarlembrete": true, "autodestruicao": true, "autodestruição": true,
+	"resumo": true, "roletacasais": true, "roleta": true, "casais": true, "imagem": true, "img": true,
+	"persona": true, "modelo": true, "uso": true, "cache": true, "faq": true, "nsfw": true,
+	"help": true, "ajuda": true, "menu": true, "explique": true,
+}
+
+// privateCommands são os comandos que também funcionam no privado
+// (no privado as demais mensagens vão para a IA)
+var privateCommands = map[string]bool{
+	"lembrete":         true,
+	"lembretes":        true,
+	"cancelarlembrete": true,
+	"persona":          true,
+	"modelo":           true,
+	"uso":              true,
+	"cache":            true,
+	"faq":              true,
+}
+
+// parsePrivateCommand identifica os comandos que funcionam em mensagens privadas
+func parsePrivateCommand(msgText string) (string, []string, bool) {
+	parts := strings.Fields(msgText)
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "!") {
+		return "", nil, false
+	}
+
+	command := strings.ToLower(strings.TrimPrefix(parts[0], "!"))
+	if !privateCommands[command] {
+		return "", nil, false
+	}
+	return command, parts[1:], true
+}
+
 // handleActionCommand processa comandos de ação genéricos (tapa, chute, etc.)
-func (ch *CommandHandler) handleActionCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient, folder, actionName, emoji, commandUsage string) error {
-	if len(args) == 0 {
-		// Sem menção, enviar mensagem de erro
-		errorMsg := fmt.Sprintf("❌ Use: %s\nExemplo: %s @johndoe", commandUsage, commandUsage)
+func (ch *CommandHandler) handleActionCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient, action *ActionDefinition) error {
+	// Resolver os alvos mencionados (um ou vários: !abraco @a @b @c) ou o autor da mensagem citada
+	targets := ch.resolveMentions(ctx, args, evt, bot)
+	if len(targets) == 0 {
+		// Sem menção nem citação, enviar mensagem de erro
+		errorMsg := fmt.Sprintf("❌ Use: %s\nExemplo: !%s @johndoe (ou responda a mensagem de alguém com !%s)", action.Usage(), action.Command, action.Command)
 		msg := &waProto.Message{
 			Conversation: &errorMsg,
 		}
@@ -80,82 +147,67 @@ func (ch *CommandHandler) handleActionCommand(ctx context.Context, args []string
 		return err
 	}
 
-	// Extrair menção do primeiro argumento
-	targetMention := args[0]
-	var targetJID string
-	var targetName string
-
-	// Verificar se é uma menção válida (@usuario)
-	if strings.HasPrefix(targetMention, "@") {
-		targetJID, targetName = ch.extractMentionInfo(targetMention, evt)
-	} else {
-		// Se não é uma menção com @, usar como nome simples
-		targetName = targetMention
-	}
-
-	// Buscar GIF aleatório na pasta específica
-	gifPath, err := ch.searchLocalGIF(folder)
-	if err != nil {
-		log.Warn().Err(err).Str("folder", folder).Msg("Erro ao buscar GIF local")
-		// Fallback: enviar mensagem de texto com menção (se houver JID)
-		caption := fmt.Sprintf("%s *%s* %s *%s*!", emoji, evt.Info.PushName, actionName, targetName)
-		if targetJID != "" {
-			caption = fmt.Sprintf("%s *%s* %s *@%s*!", emoji, evt.Info.PushName, actionName, targetName)
-			return ch.sendMentionMessage(ctx, caption, targetJID, evt, bot)
-		} else {
+	// Verificar se ações NSFW são permitidas no grupo
+	if action.NSFW {
+		rules := bot.groupProcessor.GetGroupRules(evt.Info.Chat.String())
+		if !rules.AllowNSFW {
+			errorMsg := fmt.Sprintf("❌ O comando !%s não está liberado neste grupo.", action.Command)
 			msg := &waProto.Message{
-				Conversation: &caption,
+				Conversation: &errorMsg,
 			}
 			_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
 			return err
 		}
 	}
 
-	// Enviar GIF com menção (se houver JID)
-	caption := fmt.Sprintf("%s *%s* %s *%s*!", emoji, evt.Info.PushName, actionName, targetName)
-	if targetJID != "" {
-		caption = fmt.Sprintf("%s *%s* %s *@%s*!", emoji, evt.Info.PushName, actionName, targetName)
-		return ch.sendGIFMessageWithMention(ctx, gifPath, caption, targetJID, evt, bot)
-	} else {
-		return ch.sendGIFMessage(ctx, gifPath, caption, evt, bot)
-	}
-}
+	mentions := mentionJIDs(targets)
 
-// handleTapaCommand processa o comando !tapa
-func (ch *CommandHandler) handleTapaCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
-	return ch.handleActionCommand(ctx, args, evt, bot, "slap", "deu um tapa em", "🤚", "!tapa @usuario")
-}
+	// Montar legenda (com texto especial quando o único alvo é o próprio autor)
+	caption := fmt.Sprintf("%s *%s* %s %s!", action.Emoji, evt.Info.PushName, action.Verb, joinMentionTexts(targets))
+	if len(targets) == 1 && targets[0].JID != "" && action.SelfText != "" && ch.isSelfTarget(targets[0].JID, evt) {
+		caption = fmt.Sprintf("%s *%s* %s!", action.Emoji, evt.Info.PushName, action.SelfText)
+		mentions = nil
+	}
 
-// handleChuteCommand processa o comando !chute
-func (ch *CommandHandler) handleChuteCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
-	return ch.handleActionCommand(ctx, args, evt, bot, "kick", "deu um chute em", "🦵", "!chute @usuario")
-}
+	log.Info().
+		Str("command", action.Command).
+		Int("targets", len(targets)).
+		Strs("mentioned", mentions).
+		Msg("Executando comando de ação")
 
-// handleVoadoraCommand processa o comando !voadora
-func (ch *CommandHandler) handleVoadoraCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
-	return ch.handleActionCommand(ctx, args, evt, bot, "flying", "deu uma voadora em", "💥", "!voadora @usuario")
-}
+	// Buscar GIF aleatório para a ação (pastas locais ou Tenor, se configurado)
+	gifPath, err := ch.gifProvider.FindGIF(ctx, action)
+	if err != nil {
+		log.Warn().Err(err).Str("provider", ch.gifProvider.Name()).Str("command", action.Command).Msg("Erro ao buscar GIF")
+		// Fallback: enviar mensagem de texto com menções (se houver JIDs)
+		return ch.sendReplyMessage(ctx, caption, replyContextInfo(evt, targets, mentions), evt, bot)
+	}
 
-// handleBeijoCommand processa o comando !beijo
-func (ch *CommandHandler) handleBeijoCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
-	return ch.handleActionCommand(ctx, args, evt, bot, "kiss", "deu um beijo em", "💋", "!beijo @usuario")
+	// Enviar GIF com legenda, menções e citação (quando o alvo veio de uma resposta) na mesma mensagem
+	return bot.mediaService.SendGIF(ctx, evt.Info.Chat, gifPath, caption, replyContextInfo(evt, targets, mentions))
 }
 
-// handleAbracoCommand processa o comando !abraco
-func (ch *CommandHandler) handleAbracoCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
-	return ch.handleActionCommand(ctx, args, evt, bot, "hug", "deu um abraço em", "🤗", "!abraco @usuario")
+// isSelfTarget verifica se o alvo de uma ação é o próprio autor da mensagem
+// Compara com o endereço principal e o alternativo (PN/LID) do remetente
+func (ch *CommandHandler) isSelfTarget(targetJID string, evt *events.Message) bool {
+	target, err := types.ParseJID(targetJID)
+	if err != nil {
+		return false
+	}
+	return target.User == evt.Info.Sender.User ||
+		(!evt.Info.SenderAlt.IsEmpty() && target.User == evt.Info.SenderAlt.User)
 }
 
-// handleTiroCommand processa o comando !tiro
-func (ch *CommandHandler) handleTiroCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
-	return ch.handleActionCommand(ctx, args, evt, bot, "shot", "atirou em", "🔫", "!tiro @usuario")
-}
+// handlePiadaCommand processa o comando !piada [categoria|top]
+func (ch *CommandHandler) handlePiadaCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
+	// !piada top repete uma das piadas mais bem avaliadas da conversa
+	if len(args) > 0 && strings.EqualFold(args[0], "top") {
+		return ch.handlePiadaTopCommand(ctx, evt, bot)
+	}
 
-// handlePiadaCommand processa o comando !piada
-func (ch *CommandHandler) handlePiadaCommand(ctx context.Context, evt *events.Message, bot *BotClient) error {
-	// Verificar se o cliente Gemini está configurado
-	if bot.geminiClient == nil {
-		errorMsg := "❌ Gemini não está configurado. Configure a API key para usar este comando."
+	// Verificar se o provedor de IA está configurado
+	if bot.llm == nil {
+		errorMsg := "❌ IA não está configurada. Configure um provedor (ex: API key do Gemini) para usar este comando."
 		msg := &waProto.Message{
 			Conversation: &errorMsg,
 		}
@@ -163,14 +215,30 @@ func (ch *CommandHandler) handlePiadaCommand(ctx context.Context, evt *events.Me
 		return err
 	}
 
+	// Categoria opcional (ex: !piada tiozao)
+	var category JokeCategory
+	if len(args) > 0 {
+		var ok bool
+		category, ok = findJokeCategory(args[0])
+		if !ok {
+			errorMsg := fmt.Sprintf("❌ Categoria desconhecida: %s\nCategorias: %s\nExemplo: !piada tiozao", args[0], jokeCategoryKeys())
+			msg := &waProto.Message{
+				Conversation: &errorMsg,
+			}
+			_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+			return err
+		}
+	}
+
 	// Enviar evento de "digitando"
 	errTyping := bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresenceComposing, types.ChatPresenceMediaText)
 	if errTyping != nil {
 		log.Warn().Err(errTyping).Msg("Erro ao enviar status de digitando")
 	}
 
-	// Carregar histórico de piadas anteriores
-	jokesHistory, err := bot.chatContext.LoadJokesHistory(ctx, 50) // Últimas 50 piadas
+	// Carregar histórico de piadas anteriores desta conversa
+	chatJID := evt.Info.Chat.String()
+	jokesHistory, err := bot.chatContext.LoadJokesHistory(ctx, chatJID, 50) // Últimas 50 piadas
 	if err != nil {
 		log.Warn().Err(err).Msg("Erro ao carregar histórico de piadas, continuando sem histórico")
 		jokesHistory = []string{}
@@ -190,37 +258,59 @@ Requisitos:
 - NÃO use emojis
 - Responda APENAS com a piada, sem explicações ou comentários adicionais`
 
+	if category.Key != "" {
+		basePrompt += fmt.Sprintf("\n- Categoria: %s", category.Prompt)
+	}
+
 	// Combinar prompt base com histórico
 	prompt := basePrompt + historyText + "\n\nConte a piada agora:"
 
 	log.Info().
+		Str("category", category.Key).
 		Int("historySize", len(jokesHistory)).
-		Msg("Gerando piada com Gemini (com histórico)")
-
-	// Gerar piada usando a API do Gemini
-	piada, err := bot.geminiClient.GenerateContent(ctx, prompt)
-	if err != nil {
-		log.Error().Err(err).Msg("Erro ao gerar piada com Gemini")
+		Msg("Gerando piada com IA (com histórico)")
+
+	// Gerar piada usando o provedor de IA, descartando piadas parecidas com as já contadas na conversa
+	// Cada piada recusada entra no prompt da próxima tentativa, para a IA não insistir nela
+	var piada string
+	var rejected []string
+	for attempt := 1; attempt <= jokeMaxAttempts; attempt++ {
+		attemptPrompt := prompt
+		if len(rejected) > 0 {
+			attemptPrompt = prompt + "\n\nEssas piadas já foram contadas nesta conversa, NÃO repita nenhuma delas nem conte uma variação:\n- " +
+				strings.Join(rejected, "\n- ") + "\n\nConte uma piada totalmente diferente:"
+		}
+		piada, err = bot.llm.GenerateContent(withInstruction(bot.aiContext(ctx, evt, personaHumor, "piada"), basePrompt), attemptPrompt)
+		if err != nil {
+			break
+		}
 
-		// Encerrar status de digitando
-		bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
+		similar, score, repeated := findSimilarJoke(piada, jokesHistory)
+		if !repeated {
+			break
+		}
 
-		// Informar erro ao usuário
-		errorMsg := "❌ Erro ao gerar piada. Tente novamente mais tarde."
-		msg := &waProto.Message{
-			Conversation: &errorMsg,
+		log.Info().
+			Int("attempt", attempt).
+			Float64("similarity", score).
+			Str("similar", similar).
+			Msg("Piada repetida na conversa, gerando outra")
+		rejected = append(rejected, strings.TrimSpace(piada))
+
+		if attempt == jokeMaxAttempts {
+			err = fmt.Errorf("nenhuma piada inédita após %d tentativas", jokeMaxAttempts)
 		}
-		_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
-		return err
 	}
+	if err != nil {
+		// Encerrar status de digitando
+		bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
 
-	// Limitar tamanho da piada
-	if len(piada) > 500 {
-		piada = piada[:500] + "..."
+		// Informar erro ao usuário (ou o aviso de IA indisponível)
+		return bot.handleAIError(ctx, evt.Info.Chat, err, "Erro ao gerar piada com IA", "❌ Erro ao gerar piada. Tente novamente mais tarde.")
 	}
 
 	// Salvar piada no histórico antes de enviar
-	err = bot.chatContext.SaveJoke(ctx, piada)
+	jokeID, err := bot.chatContext.SaveJoke(ctx, chatJID, category.Key, piada)
 	if err != nil {
 		log.Warn().Err(err).Msg("Erro ao salvar piada no histórico, mas continuando")
 		// Não retornar erro aqui, pois a piada já foi gerada
@@ -230,17 +320,27 @@ Requisitos:
 	bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
 
 	// Enviar piada gerada
-	piadaMsg := fmt.Sprintf("😄 *Piada:*\n\n%s", piada)
-	msg := &waProto.Message{
-		Conversation: &piadaMsg,
+	title := "😄 *Piada:*"
+	if category.Key != "" {
+		title = fmt.Sprintf("😄 *Piada de %s:*", category.Name)
 	}
-	_, err = bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+	piadaMsg := fmt.Sprintf("%s\n\n%s\n\n_Reaja com 😂 ou 👎 para avaliar_", title, piada)
+	messageID, err := bot.chunker.Send(ctx, evt.Info.Chat, evt.Info.Sender, piadaMsg, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("Erro ao enviar piada")
 		return err
 	}
 
+	// Associar a mensagem à piada para receber avaliações por reação
+	if jokeID != 0 {
+		err = bot.chatContext.LinkJokeMessage(ctx, messageID, jokeID)
+		if err != nil {
+			log.Warn().Err(err).Msg("Erro ao associar mensagem à piada")
+		}
+	}
+
 	log.Info().
+		Str("category", category.Key).
 		Int("length", len(piada)).
 		Int("historySize", len(jokesHistory)).
 		Msg("Piada enviada e salva no histórico com sucesso")
@@ -248,11 +348,58 @@ Requisitos:
 	return nil
 }
 
+// handlePiadaTopCommand repete uma das piadas mais bem avaliadas da conversa
+func (ch *CommandHandler) handlePiadaTopCommand(ctx context.Context, evt *events.Message, bot *BotClient) error {
+	topJokes, err := bot.chatContext.LoadTopJokes(ctx, evt.Info.Chat.String(), 5)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao carregar piadas mais bem avaliadas")
+		errorMsg := "❌ Erro ao buscar as melhores piadas. Tente novamente mais tarde."
+		msg := &waProto.Message{
+			Conversation: &errorMsg,
+		}
+		_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+		return err
+	}
+
+	if len(topJokes) == 0 {
+		infoMsg := "🤷 Nenhuma piada avaliada ainda. Reaja com 😂 às piadas do !piada para montar o ranking."
+		msg := &waProto.Message{
+			Conversation: &infoMsg,
+		}
+		_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+		return err
+	}
+
+	// Sortear entre as melhores para não repetir sempre a mesma
+	position := rand.Intn(len(topJokes))
+	joke := topJokes[position]
+
+	piadaMsg := fmt.Sprintf("🏆 *Piada top #%d* (😂 %d)\n\n%s", position+1, joke.Score, joke.Text)
+	messageID, err := bot.chunker.Send(ctx, evt.Info.Chat, evt.Info.Sender, piadaMsg, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao enviar piada top")
+		return err
+	}
+
+	// Reações à repetição também contam para a piada original
+	err = bot.chatContext.LinkJokeMessage(ctx, messageID, joke.ID)
+	if err != nil {
+		log.Warn().Err(err).Msg("Erro ao associar mensagem à piada")
+	}
+
+	log.Info().
+		Int64("jokeID", joke.ID).
+		Int("score", joke.Score).
+		Msg("Piada top enviada")
+
+	return nil
+}
+
 // handleCantadaCommand processa o comando !cantada
 func (ch *CommandHandler) handleCantadaCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
-	// Verificar se o cliente Gemini está configurado
-	if bot.geminiClient == nil {
-		errorMsg := "❌ Gemini não está configurado. Configure a API key para usar este comando."
+	// Verificar se o provedor de IA está configurado
+	if bot.llm == nil {
+		errorMsg := "❌ IA não está configurada. Configure um provedor (ex: API key do Gemini) para usar este comando."
 		msg := &waProto.Message{
 			Conversation: &errorMsg,
 		}
@@ -260,9 +407,10 @@ func (ch *CommandHandler) handleCantadaCommand(ctx context.Context, args []strin
 		return err
 	}
 
-	// Verificar se há um usuário mencionado
-	if len(args) == 0 {
-		errorMsg := "❌ Use: !cantada @usuario\nExemplo: !cantada @johndoe"
+	// Verificar se há um usuário mencionado ou uma mensagem citada
+	targets := ch.resolveMentions(ctx, args, evt, bot)
+	if len(targets) == 0 {
+		errorMsg := "❌ Use: !cantada @usuario\nExemplo: !cantada @johndoe (ou responda a mensagem de alguém com !cantada)"
 		msg := &waProto.Message{
 			Conversation: &errorMsg,
 		}
@@ -270,16 +418,13 @@ func (ch *CommandHandler) handleCantadaCommand(ctx context.Context, args []strin
 		return err
 	}
 
-	// Extrair informações da menção
-	targetMention := args[0]
-	var targetJID string
-	var targetName string
+	// Extrair informações da menção (a cantada é direcionada ao primeiro alvo)
+	target := targets[0]
+	targetJID := target.JID
+	targetName := target.Name
 
-	if strings.HasPrefix(targetMention, "@") {
-		targetJID, targetName = ch.extractMentionInfo(targetMention, evt)
-	} else {
-		targetName = targetMention
-	}
+	// Argumentos restantes definem o tema (ex: !cantada @fulano nerd)
+	theme := cantadaTheme(args, target)
 
 	// Enviar evento de "digitando"
 	errTyping := bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresenceComposing, types.ChatPresenceMediaText)
@@ -287,10 +432,15 @@ func (ch *CommandHandler) handleCantadaCommand(ctx context.Context, args []strin
 		log.Warn().Err(errTyping).Msg("Erro ao enviar status de digitando")
 	}
 
-	// Criar prompt para gerar cantada
-	prompt := `Você é um especialista em criar cantadas criativas e engraçadas em português brasileiro.
+	// Carregar histórico de cantadas anteriores
+	cantadasHistory, err := bot.chatContext.LoadCantadasHistory(ctx, evt.Info.Chat.String(), 30) // Últimas 30 cantadas da conversa
+	if err != nil {
+		log.Warn().Err(err).Msg("Erro ao carregar histórico de cantadas, continuando sem histórico")
+		cantadasHistory = []string{}
+	}
 
-Crie uma cantada 
+	// Criar prompt para gerar cantada
+	basePrompt := `Você é um especialista em criar cantadas criativas e engraçadas em português brasileiro.
 
 Requisitos:
 - A cantada deve ser criativa e engraçada
@@ -300,53 +450,53 @@ Requisitos:
 - Máximo de 3-4 frases
 - NÃO use emojis
 - Responda APENAS com a cantada, sem explicações ou comentários adicionais
-- A cantada deve ser direcionada à pessoa mencionada
+- A cantada deve ser direcionada à pessoa mencionada`
+
+	var details strings.Builder
+	// Números não são nomes: só usar o nome quando o contato foi resolvido
+	if targetName != "" && !ch.isOnlyNumber(strings.TrimPrefix(targetName, "+")) {
+		details.WriteString(fmt.Sprintf("\n- A pessoa se chama %s; pode usar o nome (ou um trocadilho com ele) na cantada", targetName))
+	}
+	if evt.Info.PushName != "" {
+		details.WriteString(fmt.Sprintf("\n- Quem está mandando a cantada é %s", evt.Info.PushName))
+	}
+	if theme != "" {
+		details.WriteString(fmt.Sprintf("\n- Tema da cantada: %s (use referências desse tema)", theme))
+	}
 
-Crie a cantada agora:`
+	// Combinar prompt base com detalhes e histórico
+	prompt := basePrompt + details.String() + FormatCantadasHistory(cantadasHistory) + "\n\nCrie a cantada agora:"
 
 	log.Info().
 		Str("target", targetName).
 		Str("targetJID", targetJID).
-		Msg("Gerando cantada com Gemini")
+		Str("theme", theme).
+		Int("historySize", len(cantadasHistory)).
+		Msg("Gerando cantada com IA")
 
-	// Gerar cantada usando a API do Gemini
-	cantada, err := bot.geminiClient.GenerateContent(ctx, prompt)
+	// Gerar cantada usando o provedor de IA
+	cantada, err := bot.llm.GenerateContent(withInstruction(bot.aiContext(ctx, evt, personaHumor, "cantada"), basePrompt), prompt)
 	if err != nil {
-		log.Error().Err(err).Msg("Erro ao gerar cantada com Gemini")
-
 		// Encerrar status de digitando
 		bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
 
-		// Informar erro ao usuário
-		errorMsg := "❌ Erro ao gerar cantada. Tente novamente mais tarde."
-		msg := &waProto.Message{
-			Conversation: &errorMsg,
-		}
-		_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
-		return err
+		// Informar erro ao usuário (ou o aviso de IA indisponível)
+		return bot.handleAIError(ctx, evt.Info.Chat, err, "Erro ao gerar cantada com IA", "❌ Erro ao gerar cantada. Tente novamente mais tarde.")
 	}
 
-	// Limitar tamanho da cantada
-	if len(cantada) > 500 {
-		cantada = cantada[:500] + "..."
+	// Salvar cantada no histórico antes de enviar
+	err = bot.chatContext.SaveCantada(ctx, evt.Info.Chat.String(), targetJID, theme, cantada)
+	if err != nil {
+		log.Warn().Err(err).Msg("Erro ao salvar cantada no histórico, mas continuando")
 	}
 
 	// Encerrar status de digitando
 	bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
 
-	// Enviar cantada gerada com menção ao usuário
-	cantadaMsg := fmt.Sprintf("💕 *Cantada para @%s:*\n\n%s", targetName, cantada)
-
-	// Se temos o JID, enviar com menção clicável
-	if targetJID != "" {
-		err = ch.sendMentionMessage(ctx, cantadaMsg, targetJID, evt, bot)
-	} else {
-		// Fallback: enviar sem menção clicável
-		msg := &waProto.Message{
-			Conversation: &cantadaMsg,
-		}
-		_, err = bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
-	}
+	// Enviar cantada gerada com menção ao usuário (clicável quando temos o JID)
+	cantadaMsg := fmt.Sprintf("💕 *Cantada para %s:*\n\n%s", target.MentionText(), cantada)
+	cantadaTargets := []MentionTarget{target}
+	_, err = bot.chunker.Send(ctx, evt.Info.Chat, evt.Info.Sender, cantadaMsg, replyContextInfo(evt, cantadaTargets, mentionJIDs(cantadaTargets)))
 
 	if err != nil {
 		log.Error().Err(err).Msg("Erro ao enviar cantada")
@@ -363,9 +513,9 @@ Crie a cantada agora:`
 
 // handleHistoriaCommand processa o comando !historia
 func (ch *CommandHandler) handleHistoriaCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
-	// Verificar se o cliente Gemini está configurado
-	if bot.geminiClient == nil {
-		errorMsg := "❌ Gemini não está configurado. Configure a API key para usar este comando."
+	// Verificar se o provedor de IA está configurado
+	if bot.llm == nil {
+		errorMsg := "❌ IA não está configurada. Configure um provedor (ex: API key do Gemini) para usar este comando."
 		msg := &waProto.Message{
 			Conversation: &errorMsg,
 		}
@@ -373,6 +523,16 @@ func (ch *CommandHandler) handleHistoriaCommand(ctx context.Context, args []stri
 		return err
 	}
 
+	// Modo colaborativo: !historia iniciar <gênero> / !historia fim
+	if len(args) > 0 {
+		switch strings.ToLower(args[0]) {
+		case "iniciar", "comecar", "começar":
+			return ch.handleHistoriaIniciarCommand(ctx, args[1:], evt, bot)
+		case "fim", "encerrar", "terminar":
+			return ch.handleHistoriaFimCommand(ctx, evt, bot)
+		}
+	}
+
 	// Extrair tipo da história dos argumentos
 	historiaTipo := "aventura" // Tipo padrão
 	if len(args) > 0 {
@@ -386,7 +546,8 @@ func (ch *CommandHandler) handleHistoriaCommand(ctx context.Context, args []stri
 	}
 
 	// Criar prompt para gerar história
-	prompt := fmt.Sprintf(`Você é um contador de histórias criativo e envolvente em português brasileiro.
+	// O gênero vem do usuário: a instrução (sem o gênero) fica separada para decidir sobre os emojis
+	instruction := `Você é um contador de histórias criativo e envolvente em português brasileiro.
 
 Crie uma história do gênero: %s
 
@@ -405,22 +566,37 @@ Requisitos:
 - Se for aventura, seja emocionante e dinâmica
 - Se for ficção científica, seja criativa e interessante
 
-Crie a história agora:`, historiaTipo, historiaTipo)
+Crie a história agora:`
+	prompt := fmt.Sprintf(instruction, historiaTipo, historiaTipo)
 
 	log.Info().
 		Str("tipo", historiaTipo).
-		Msg("Gerando história com Gemini")
+		Msg("Gerando história com IA")
 
-	// Gerar história usando a API do Gemini
-	historia, err := bot.geminiClient.GenerateContent(ctx, prompt)
+	// Gerar história em streaming: o texto aparece aos poucos por edições da mensagem
+	header := fmt.Sprintf("📖 *História de %s:*\n\n", strings.Title(historiaTipo))
+	historia, err := bot.chunker.SendStream(ctx, evt.Info.Chat, evt.Info.Sender, header, bot.llm.GenerateContentStream(withInstruction(bot.aiContext(ctx, evt, personaHistoria, "historia"), instruction), prompt))
 	if err != nil {
-		log.Error().Err(err).Msg("Erro ao gerar história com Gemini")
-
 		// Encerrar status de digitando
 		bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
 
-		// Informar erro ao usuário
-		errorMsg := "❌ Erro ao gerar história. Tente novamente mais tarde."
+		// Informar erro ao usuário (ou o aviso de IA indisponível)
+		return bot.handleAIError(ctx, evt.Info.Chat, err, "Erro ao gerar história com IA", "❌ Erro ao gerar história. Tente novamente mais tarde.")
+	}
+
+	log.Info().
+		Int("length", len(historia)).
+		Str("tipo", historiaTipo).
+		Msg("História enviada com sucesso")
+
+	return nil
+}
+
+// handleImagemCommand processa o comando !imagem para gerar imagens com IA
+func (ch *CommandHandler) handleImagemCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
+	// Verificar se o provedor de IA está configurado
+	if bot.llm == nil {
+		errorMsg := "❌ IA não está configurada. Configure um provedor (ex: API key do Gemini) para usar este comando."
 		msg := &waProto.Message{
 			Conversation: &errorMsg,
 		}
@@ -428,55 +604,125 @@ Crie a história agora:`, historiaTipo, historiaTipo)
 		return err
 	}
 
-	// Limitar tamanho da história (histórias podem ser mais longas)
-	if len(historia) > 3000 {
-		historia = historia[:3000] + "\n\n... (história truncada)"
+	// Nem todo provedor gera imagens (ex: APIs compatíveis com OpenAI)
+	images, ok := imageGeneratorOf(bot.llm)
+	if !ok {
+		errorMsg := fmt.Sprintf("❌ O provedor de IA atual (%s) não gera imagens.", bot.llm.Name())
+		msg := &waProto.Message{
+			Conversation: &errorMsg,
+		}
+		_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+		return err
 	}
 
-	// Encerrar status de digitando
-	bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
+	// Verificar se há descrição
+	if len(args) == 0 {
+		errorMsg := "❌ Use: !imagem <descrição>\nExemplo: !imagem um pato surfando no Maranhão"
+		msg := &waProto.Message{
+			Conversation: &errorMsg,
+		}
+		_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+		return err
+	}
 
-	// Enviar história gerada
-	historiaMsg := fmt.Sprintf("📖 *História de %s:*\n\n%s", strings.Title(historiaTipo), historia)
-	msg := &waProto.Message{
-		Conversation: &historiaMsg,
+	// Verificar se a geração de imagens está habilitada para o grupo
+	groupJID := evt.Info.Chat.String()
+	rules := bot.groupProcessor.GetGroupRules(groupJID)
+	if !rules.EnableAI || !rules.EnableImages {
+		errorMsg := "❌ Geração de imagens está desabilitada neste grupo."
+		msg := &waProto.Message{
+			Conversation: &errorMsg,
+		}
+		_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+		return err
 	}
-	_, err = bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
-	if err != nil {
-		log.Error().Err(err).Msg("Erro ao enviar história")
+
+	// Verificar cooldown de imagens (mais restrito que comandos de texto)
+	if !bot.groupProcessor.canGenerateImage(rules) {
+		remaining := time.Duration(rules.ImageCooldown)*time.Second - time.Since(rules.LastImage)
+		remainingSeconds := int(remaining.Seconds())
+		if remainingSeconds < 1 {
+			remainingSeconds = 1
+		}
+		errorMsg := fmt.Sprintf("⏳ Aguarde %d segundo(s) para gerar outra imagem.", remainingSeconds)
+		msg := &waProto.Message{
+			Conversation: &errorMsg,
+		}
+		_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
 		return err
 	}
 
+	// Imagens também contam na cota mensal de tokens do grupo
+	ctx = bot.usageContext(ctx, evt, "imagem")
+	if err := checkQuota(ctx); err != nil {
+		return bot.handleAIError(ctx, evt.Info.Chat, err, "Geração de imagem recusada", "❌ Erro ao gerar imagem. Tente novamente mais tarde.")
+	}
+
+	// Reservar o cooldown antes de gerar para evitar pedidos simultâneos
+	// Se a geração ou o envio falhar, a reserva é desfeita: o cooldown só conta para imagens entregues
+	previousImage := rules.LastImage
+	rules.LastImage = time.Now()
+
+	descricao := strings.Join(args, " ")
+
+	// Enviar evento de "digitando"
+	errTyping := bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresenceComposing, types.ChatPresenceMediaText)
+	if errTyping != nil {
+		log.Warn().Err(errTyping).Msg("Erro ao enviar status de digitando")
+	}
+
 	log.Info().
-		Int("length", len(historia)).
-		Str("tipo", historiaTipo).
-		Msg("História enviada com sucesso")
+		Str("prompt", descricao).
+		Str("model", images.GetImageModel()).
+		Msg("Gerando imagem com IA")
 
-	return nil
-}
+	// Gerar imagem usando o provedor de IA
+	imageData, mimeType, err := images.GenerateImage(ctx, descricao)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao gerar imagem com IA")
+		rules.LastImage = previousImage
 
-// extractMentionInfo extrai informações de menção de uma mensagem
-func (ch *CommandHandler) extractMentionInfo(mentionText string, evt *events.Message) (string, string) {
-	// Verificar se há informações de contexto da mensagem
-	if evt.Message.GetExtendedTextMessage() == nil || evt.Message.GetExtendedTextMessage().GetContextInfo() == nil {
-		// Sem informações de contexto, retornar apenas o nome
-		return "", strings.TrimPrefix(mentionText, "@")
+		// Encerrar status de digitando
+		bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
+
+		// Informar erro ao usuário
+		errorMsg := "❌ Erro ao gerar imagem. Tente novamente mais tarde."
+		msg := &waProto.Message{
+			Conversation: &errorMsg,
+		}
+		_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+		return err
+	}
+
+	if mimeType == "" {
+		mimeType = http.DetectContentType(imageData)
 	}
 
-	contextInfo := evt.Message.GetExtendedTextMessage().GetContextInfo()
-	mentionedJIDs := contextInfo.GetMentionedJID()
+	// Encerrar status de digitando
+	bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
+
+	// Enviar imagem com a descrição como legenda
+	caption := fmt.Sprintf("🎨 %s", descricao)
+	err = bot.mediaService.SendImage(ctx, evt.Info.Chat, imageData, mimeType, caption)
+	if err != nil {
+		log.Error().Err(err).Int("size", len(imageData)).Msg("Erro ao enviar imagem")
+		rules.LastImage = previousImage
 
-	if len(mentionedJIDs) == 0 {
-		// Sem JIDs mencionados, retornar apenas o nome
-		return "", strings.TrimPrefix(mentionText, "@")
+		errorMsg := "❌ Erro ao enviar imagem. Tente novamente mais tarde."
+		msg := &waProto.Message{
+			Conversation: &errorMsg,
+		}
+		_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+		return err
 	}
 
-	// Para simplificar, pegar o primeiro JID mencionado
-	// Em uma implementação mais robusta, mapearia o JID correto baseado na posição da menção
-	targetJID := mentionedJIDs[0]
-	targetName := strings.TrimPrefix(mentionText, "@")
+	log.Info().
+		Int("size", len(imageData)).
+		Str("mimetype", mimeType).
+		Str("prompt", descricao).
+		Msg("Imagem enviada com sucesso")
 
-	return targetJID, targetName
+	return nil
 }
 
 // handleAutodestruicaoCommand processa o comando de auto-destruição
@@ -594,18 +840,37 @@ func (ch *CommandHandler) handleAutodestruicaoCommand(ctx context.Context, args
 	return nil
 }
 
+// cantadaTheme extrai o tema da cantada dos argumentos que não identificam o alvo
+// Ex: "!cantada @fulano nerd" -> "nerd"; "!cantada fulano nerd" -> "nerd"
+func cantadaTheme(args []string, target MentionTarget) string {
+	var words []string
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "@") {
+			continue
+		}
+		// Sem menção nem citação, o primeiro argumento é o nome do alvo
+		if i == 0 && target.JID == "" && !target.Quoted {
+			continue
+		}
+		words = append(words, arg)
+	}
+	return strings.Join(words, " ")
+}
+
 // getParticipantName tenta obter o nome do participante de todas as formas possíveis
+// JIDs do tipo LID são convertidos para o número de telefone quando o contato não é encontrado
 func (ch *CommandHandler) getParticipantName(ctx context.Context, jid types.JID, bot *BotClient) string {
-	// Tentar obter o nome do contato do store
-	contact, err := bot.WAClient.Store.Contacts.GetContact(ctx, jid)
-	if err == nil {
-		// Priorizar FullName
-		if contact.FullName != "" {
-			return contact.FullName
-		}
-		// Se não tiver FullName, usar PushName
-		if contact.PushName != "" {
-			return contact.PushName
+	if name := ch.contactName(ctx, jid, bot); name != "" {
+		return name
+	}
+
+	// Menções em grupos podem vir como LID; tentar o contato pelo número de telefone
+	if jid.Server == types.HiddenUserServer && bot.WAClient.Store.LIDs != nil {
+		pn, err := bot.WAClient.Store.LIDs.GetPNForLID(ctx, jid)
+		if err == nil && !pn.IsEmpty() {
+			if name := ch.contactName(ctx, pn, bot); name != "" {
+				return name
+			}
 		}
 	}
 
@@ -614,6 +879,22 @@ func (ch *CommandHandler) getParticipantName(ctx context.Context, jid types.JID,
 	return ""
 }
 
+// contactName busca o nome de um contato no store (FullName, PushName, FirstName ou BusinessName)
+func (ch *CommandHandler) contactName(ctx context.Context, jid types.JID, bot *BotClient) string {
+	contact, err := bot.WAClient.Store.Contacts.GetContact(ctx, jid.ToNonAD())
+	if err != nil || !contact.Found {
+		return ""
+	}
+
+	// Priorizar FullName, depois PushName
+	for _, name := range []string{contact.FullName, contact.PushName, contact.FirstName, contact.BusinessName} {
+		if name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
 // isOnlyNumber verifica se a string contém apenas números
 func (ch *CommandHandler) isOnlyNumber(s string) bool {
 	for _, r := range s {
@@ -727,37 +1008,82 @@ func (ch *CommandHandler) handleRoletaCasaisCommand(ctx context.Context, evt *ev
 
 // handleHelpCommand mostra a lista de comandos disponíveis
 func (ch *CommandHandler) handleHelpCommand(ctx context.Context, evt *events.Message, bot *BotClient) error {
-	helpMsg := `*🤖 Comandos Disponíveis:*
-
-• *!tapa @usuario* - Dar um tapa virtual em alguém com GIF
-• *!chute @usuario* - Dar um chute virtual em alguém com GIF
-• *!voadora @usuario* - Dar uma voadora virtual em alguém com GIF
-• *!beijo @usuario* - Dar um beijo virtual em alguém com GIF
-• *!abraco @usuario* - Dar um abraço virtual em alguém com GIF
-• *!tiro @usuario* - Atirar virtualmente em alguém com GIF
-• *!piada* - Contar uma piada gerada por IA
-• *!cantada @usuario* - Gerar uma cantada para alguém usando IA
+	var help strings.Builder
+	help.WriteString("*🤖 Comandos Disponíveis:*\n\n")
+
+	// Comandos de ação carregados do manifesto
+	for _, action := range ch.actions {
+		help.WriteString(fmt.Sprintf("• *%s* - %s\n", action.Usage(), action.Description))
+	}
+
+	help.WriteString(`• *!piada [categoria]* - Contar uma piada gerada por IA (categorias: ` + jokeCategoryKeys() + `)
+• *!piada top* - Repetir uma das piadas mais bem avaliadas (reaja com 😂 ou 👎 às piadas)
+• *!cantada @usuario [tema]* - Gerar uma cantada personalizada para alguém usando IA
 • *!historia [tipo]* - Gerar uma história usando IA (ex: !historia terror, !historia comedia)
+• *!historia iniciar [tipo]* - Começar uma história colaborativa no grupo
+• *!continuar <ideia>* - Sugerir o que acontece a seguir na história colaborativa
+• *!historia fim* - Encerrar a história colaborativa e receber o arquivo completo
+• *!imagem <descrição>* - Gerar uma imagem usando IA
+• *!lembrete <quando> <texto>* - Agendar um lembrete (ex: amanhã 9h, em 30 minutos, toda segunda 8h)
+• *!lembretes* - Listar os lembretes agendados na conversa
+• *!cancelarlembrete <número>* - Cancelar um lembrete seu
 • *!explique* - Explicar uma mensagem marcada (marque uma mensagem e digite !explique)
+• *!resumo [mensagens|tempo]* - Resumir a conversa do grupo com IA (padrão: últimas 50 mensagens)
 • *!autodestruicao [minutos]* - Pausar o bot por X minutos com countdown (padrão: 5 min, máximo: 60 min)
 • *!roletacasais* ou *!roleta* - Formar casais aleatórios com os membros do grupo
+• *!mais* - Ver o restante de uma resposta longa
 • *!help* ou *!ajuda* - Mostrar esta lista de comandos
 
 _Exemplos:_
-• !tapa @amigo
-• !chute @amigo
-• !beijo @amigo
-• !abraco @amigo
-• !tiro @amigo
-• !piada
+`)
+
+	for _, action := range ch.actions {
+		help.WriteString(fmt.Sprintf("• !%s @amigo\n", action.Command))
+	}
+
+	help.WriteString(`• !piada
+• !piada tiozao
+• !piada top
 • !cantada @amigo
+• !cantada @amigo nerd
 • !historia terror
 • !historia comedia
+• !historia iniciar terror
+• !continuar a luz se apaga e alguém bate na porta
+• !historia fim
+• !imagem um pato surfando
+• !lembrete amanhã 9h pagar o boleto
+• !lembrete toda segunda 8h reunião
+• !cancelarlembrete 3
 • Marque uma mensagem e digite: !explique
+• !resumo 100 (últimas 100 mensagens)
+• !resumo 2h (últimas 2 horas)
+• Responda a mensagem de alguém com: !tapa (o autor vira o alvo)
 • !autodestruicao 10 (pausa por 10 minutos)
 • !roletacasais (forma casais aleatórios)
-• !help`
-
+• Mencione o bot: "me lembra amanhã às 9h de pagar o boleto"
+• !help`)
+
+	// Comandos de administração só aparecem para os administradores do bot
+	if isBotAdmin(evt) {
+		help.WriteString(`
+
+*🔧 Administração:*
+• *!persona [nome]* - Ver os parâmetros de geração das personas da IA
+• *!persona <nome> <parâmetro> <valor>* - Alterar temperatura, topp, tokens, pensamento, parada ou formato (ex: !persona humor temperatura 1.1)
+• *!persona <nome> padrao* - Voltar a persona aos parâmetros padrão
+• *!modelo* - Ver o modelo de IA em uso (global e neste chat)
+• *!modelo lista* - Listar os modelos disponíveis na API
+• *!modelo <nome>* - Trocar o modelo só neste chat (!modelo padrao volta ao global)
+• *!modelo global <nome>* - Trocar o modelo de todos os chats
+• *!uso* - Tokens consumidos e custo estimado da IA (hoje e no mês)
+• *!uso cota <tokens>* - Definir a cota mensal de IA do grupo (ex: 500mil; 0 = sem limite; padrao volta à cota padrão)
+• *!cache* - Acertos do cache de respostas da IA (!cache limpar apaga as respostas guardadas)
+• *!faq* - Documentos da base de conhecimento (!faq buscar <pergunta> testa a busca, !faq recarregar relê a pasta)
+• *!nsfw on|off* - Liberar ou bloquear as ações marcadas como NSFW neste grupo`)
+	}
+
+	helpMsg := help.String()
 	msg := &waProto.Message{
 		Conversation: &helpMsg,
 	}
@@ -765,268 +1091,94 @@ _Exemplos:_
 	return err
 }
 
-// searchLocalGIF busca um GIF aleatório em uma pasta específica
-func (ch *CommandHandler) searchLocalGIF(folder string) (string, error) {
-	// Caminho para a pasta de GIFs
-	gifDir := filepath.Join("static/gif", folder)
+// handleNSFWCommand mostra ou altera se as ações marcadas como NSFW são permitidas no grupo (só administradores)
+// Uso: !nsfw | !nsfw on | !nsfw off
+func (ch *CommandHandler) handleNSFWCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
+	if !bot.requireAdmin(ctx, evt) {
+		return nil
+	}
 
-	// Listar arquivos na pasta
-	files, err := ioutil.ReadDir(gifDir)
-	if err != nil {
-		return "", fmt.Errorf("erro ao ler diretório de GIFs: %w", err)
+	if evt.Info.Chat.Server != types.GroupServer {
+		return ch.sendReplyMessage(ctx, "❌ Este comando só funciona em grupos!", nil, evt, bot)
 	}
 
-	// Filtrar apenas arquivos .mp4
-	var gifFiles []string
-	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(strings.ToLower(file.Name()), ".mp4") {
-			gifFiles = append(gifFiles, file.Name())
+	groupJID := evt.Info.Chat.String()
+	rules := bot.groupProcessor.GetGroupRules(groupJID)
+
+	if len(args) == 0 {
+		status := "bloqueadas"
+		if rules.AllowNSFW {
+			status = "liberadas"
 		}
+		return ch.sendReplyMessage(ctx, fmt.Sprintf("🔞 Ações NSFW estão *%s* neste grupo.\nUse !nsfw on ou !nsfw off para mudar.", status), nil, evt, bot)
 	}
 
-	// Verificar se há GIFs disponíveis
-	if len(gifFiles) == 0 {
-		return "", fmt.Errorf("nenhum arquivo GIF encontrado na pasta %s", gifDir)
+	switch strings.ToLower(args[0]) {
+	case "on", "sim", "liberar":
+		bot.groupProcessor.EnableNSFW(groupJID)
+		log.Info().Str("group", groupJID).Str("by", evt.Info.Sender.String()).Msg("Ações NSFW liberadas no grupo")
+		return ch.sendReplyMessage(ctx, "✅ Ações NSFW liberadas neste grupo.", nil, evt, bot)
+	case "off", "nao", "não", "bloquear":
+		bot.groupProcessor.DisableNSFW(groupJID)
+		log.Info().Str("group", groupJID).Str("by", evt.Info.Sender.String()).Msg("Ações NSFW bloqueadas no grupo")
+		return ch.sendReplyMessage(ctx, "✅ Ações NSFW bloqueadas neste grupo.", nil, evt, bot)
 	}
-
-	// Selecionar GIF aleatório
-	randomIndex := rand.Intn(len(gifFiles))
-	selectedGIF := gifFiles[randomIndex]
-
-	// Retornar caminho completo do arquivo
-	return filepath.Join(gifDir, selectedGIF), nil
+	return ch.sendReplyMessage(ctx, "❌ Use: !nsfw on ou !nsfw off", nil, evt, bot)
 }
 
-// searchLocalSlapGIF busca um GIF aleatório na pasta local de slaps
-func (ch *CommandHandler) searchLocalSlapGIF() (string, error) {
-	return ch.searchLocalGIF("slap")
+// searchLocalGIF busca um GIF aleatório em uma pasta local específica
+func (ch *CommandHandler) searchLocalGIF(folder string) (string, error) {
+	return ch.localProvider.findInFolder(folder)
 }
 
-// sendMentionMessage envia uma mensagem de texto com menção
-func (ch *CommandHandler) sendMentionMessage(ctx context.Context, text, targetJID string, evt *events.Message, bot *BotClient) error {
-	msg := &waProto.Message{
-		ExtendedTextMessage: &waProto.ExtendedTextMessage{
-			Text: &text,
-			ContextInfo: &waProto.ContextInfo{
-				MentionedJID: []string{targetJID},
-			},
-		},
-	}
-
-	_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
-	return err
+// EnableTenor passa a buscar GIFs no Tenor, usando as pastas locais como fallback
+// baseURL pode apontar para um servidor local que simule a API (vazio usa o endereço oficial)
+func (ch *CommandHandler) EnableTenor(apiKey, baseURL string) {
+	tenor := NewTenorGIFProvider(apiKey, baseURL, tenorCacheDir)
+	ch.gifProvider = NewFallbackGIFProvider(tenor, ch.localProvider)
 }
 
-// sendGIFMessage envia uma mensagem com GIF local
-func (ch *CommandHandler) sendGIFMessage(ctx context.Context, gifPath, caption string, evt *events.Message, bot *BotClient) error {
-	// Ler o arquivo GIF
-	gifData, err := ioutil.ReadFile(gifPath)
-	if err != nil {
-		log.Warn().Err(err).Str("path", gifPath).Msg("Erro ao ler arquivo GIF")
-		// Fallback: enviar apenas texto
-		message := fmt.Sprintf("%s\n\n[GIF indisponível]", caption)
-		msg := &waProto.Message{
-			Conversation: &message,
-		}
-		_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
-		return err
-	}
-
-	filename := filepath.Base(gifPath)
-
-	// Tentar upload do arquivo
-	log.Info().
-		Str("gif", filename).
-		Int("size", len(gifData)).
-		Msg("Iniciando upload do GIF")
-
-	uploadResp, err := bot.WAClient.Upload(ctx, gifData, whatsmeow.MediaVideo)
-	if err != nil {
-		log.Error().Err(err).Str("path", gifPath).Int("size", len(gifData)).Msg("Erro ao fazer upload do GIF")
-		// Fallback: enviar apenas texto
-		message := fmt.Sprintf("%s\n\n[GIF: %s]", caption, filename)
-		msg := &waProto.Message{
-			Conversation: &message,
-		}
-		_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
-		return err
-	}
-
-	log.Info().
-		Str("gif", filename).
-		Str("url", uploadResp.URL).
-		Uint64("fileLength", uploadResp.FileLength).
-		Msg("Upload do GIF concluído com sucesso")
-
-	// Criar mensagem com o GIF anexado como vídeo (GIFs são enviados como VideoMessage com GifPlayback=true)
-	msg := &waProto.Message{
-		VideoMessage: &waProto.VideoMessage{
-			URL:           proto.String(uploadResp.URL),
-			DirectPath:    proto.String(uploadResp.DirectPath),
-			Mimetype:      proto.String("video/mp4"),
-			FileLength:    proto.Uint64(uploadResp.FileLength),
-			MediaKey:      uploadResp.MediaKey,
-			FileEncSHA256: uploadResp.FileEncSHA256,
-			FileSHA256:    uploadResp.FileSHA256,
-			GifPlayback:   proto.Bool(true),
-			Width:         proto.Uint32(500), // Largura padrão (necessário)
-			Height:        proto.Uint32(500), // Altura padrão (necessário)
-		},
-	}
-
-	// Tentar enviar o GIF primeiro
-	log.Info().
-		Str("gif", filename).
-		Str("chat", evt.Info.Chat.String()).
-		Msg("Enviando GIF como VideoMessage")
-
-	_, err = bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Str("gif", filename).
-			Str("url", uploadResp.URL).
-			Str("directPath", uploadResp.DirectPath).
-			Uint64("fileLength", uploadResp.FileLength).
-			Msg("Erro detalhado ao enviar GIF")
-		// Fallback: tentar enviar apenas a mensagem de texto
-		textMsg := &waProto.Message{
-			Conversation: &caption,
-		}
-		_, fallbackErr := bot.WAClient.SendMessage(ctx, evt.Info.Chat, textMsg)
-		if fallbackErr != nil {
-			log.Error().Err(fallbackErr).Msg("Erro ao enviar mensagem de fallback")
-		}
-		return err
+// PrepareGIFs converte antecipadamente os GIFs de todas as ações do manifesto
+func (ch *CommandHandler) PrepareGIFs() {
+	folders := make([]string, 0, len(ch.actions))
+	for _, action := range ch.actions {
+		folders = append(folders, filepath.Join(gifBaseDir, action.Folder))
 	}
+	ch.localProvider.converter.WarmUp(folders)
+}
 
-	log.Info().
-		Str("gif", filename).
-		Msg("GIF enviado com sucesso como VideoMessage")
+// searchLocalSlapGIF busca um GIF aleatório na pasta local de slaps
+func (ch *CommandHandler) searchLocalSlapGIF() (string, error) {
+	return ch.searchLocalGIF("slap")
+}
 
-	// Enviar a mensagem de texto separadamente após o GIF
-	if caption != "" {
-		textMsg := &waProto.Message{
-			Conversation: &caption,
-		}
-		_, err = bot.WAClient.SendMessage(ctx, evt.Info.Chat, textMsg)
-		if err != nil {
-			log.Warn().Err(err).Msg("Erro ao enviar legenda do GIF, mas GIF foi enviado")
-			// Não retornar erro aqui, pois o GIF já foi enviado
+// sendMentionMessage envia uma mensagem de texto com menções
+func (ch *CommandHandler) sendMentionMessage(ctx context.Context, text string, mentions []string, evt *events.Message, bot *BotClient) error {
+	var contextInfo *waProto.ContextInfo
+	if len(mentions) > 0 {
+		contextInfo = &waProto.ContextInfo{
+			MentionedJID: mentions,
 		}
 	}
-
-	log.Info().
-		Str("gif", filename).
-		Int("size", len(gifData)).
-		Msg("GIF enviado com sucesso")
-
-	return nil
+	return ch.sendReplyMessage(ctx, text, contextInfo, evt, bot)
 }
 
-// sendGIFMessageWithMention envia uma mensagem com GIF local e menção
-func (ch *CommandHandler) sendGIFMessageWithMention(ctx context.Context, gifPath, caption, targetJID string, evt *events.Message, bot *BotClient) error {
-	// Ler o arquivo GIF
-	gifData, err := ioutil.ReadFile(gifPath)
-	if err != nil {
-		log.Warn().Err(err).Str("path", gifPath).Msg("Erro ao ler arquivo GIF")
-		// Fallback: enviar apenas texto com menção
-		return ch.sendMentionMessage(ctx, fmt.Sprintf("%s\n\n[GIF indisponível]", caption), targetJID, evt, bot)
-	}
-
-	filename := filepath.Base(gifPath)
-
-	// Tentar upload do arquivo
-	log.Info().
-		Str("gif", filename).
-		Int("size", len(gifData)).
-		Str("mentioned", targetJID).
-		Msg("Iniciando upload do GIF com menção")
-
-	uploadResp, err := bot.WAClient.Upload(ctx, gifData, whatsmeow.MediaVideo)
-	if err != nil {
-		log.Error().Err(err).Str("path", gifPath).Int("size", len(gifData)).Msg("Erro ao fazer upload do GIF")
-		// Fallback: enviar apenas texto com menção
-		return ch.sendMentionMessage(ctx, fmt.Sprintf("%s\n\n[GIF indisponível]", caption), targetJID, evt, bot)
-	}
-
-	log.Info().
-		Str("gif", filename).
-		Str("url", uploadResp.URL).
-		Uint64("fileLength", uploadResp.FileLength).
-		Msg("Upload do GIF concluído com sucesso")
-
-	// Criar mensagem com o GIF anexado como vídeo (GIFs são enviados como VideoMessage com GifPlayback=true)
+// sendReplyMessage envia uma mensagem de texto com ContextInfo (menções e/ou mensagem citada)
+func (ch *CommandHandler) sendReplyMessage(ctx context.Context, text string, contextInfo *waProto.ContextInfo, evt *events.Message, bot *BotClient) error {
 	msg := &waProto.Message{
-		VideoMessage: &waProto.VideoMessage{
-			URL:           proto.String(uploadResp.URL),
-			DirectPath:    proto.String(uploadResp.DirectPath),
-			Mimetype:      proto.String("video/mp4"),
-			FileLength:    proto.Uint64(uploadResp.FileLength),
-			MediaKey:      uploadResp.MediaKey,
-			FileEncSHA256: uploadResp.FileEncSHA256,
-			FileSHA256:    uploadResp.FileSHA256,
-			GifPlayback:   proto.Bool(true),
-			Width:         proto.Uint32(500), // Largura padrão (necessário)
-			Height:        proto.Uint32(500), // Altura padrão (necessário)
-		},
-	}
-
-	// Enviar o GIF primeiro
-	log.Info().
-		Str("gif", filename).
-		Str("chat", evt.Info.Chat.String()).
-		Str("mentioned", targetJID).
-		Msg("Enviando GIF como VideoMessage com menção")
-
-	_, err = bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Str("gif", filename).
-			Str("url", uploadResp.URL).
-			Str("directPath", uploadResp.DirectPath).
-			Uint64("fileLength", uploadResp.FileLength).
-			Str("mentioned", targetJID).
-			Msg("Erro detalhado ao enviar GIF com menção")
-		// Fallback: tentar enviar apenas a mensagem com menção
-		return ch.sendMentionMessage(ctx, caption, targetJID, evt, bot)
+		Conversation: &text,
 	}
-
-	log.Info().
-		Str("gif", filename).
-		Str("mentioned", targetJID).
-		Msg("GIF enviado com sucesso como VideoMessage")
-
-	// Enviar a mensagem com menção separadamente após o GIF
-	if caption != "" {
-		if targetJID != "" {
-			// Enviar mensagem com menção
-			err = ch.sendMentionMessage(ctx, caption, targetJID, evt, bot)
-			if err != nil {
-				log.Warn().Err(err).Msg("Erro ao enviar legenda com menção do GIF, mas GIF foi enviado")
-				// Não retornar erro aqui, pois o GIF já foi enviado
-			}
-		} else {
-			// Enviar mensagem simples sem menção
-			textMsg := &waProto.Message{
-				Conversation: &caption,
-			}
-			_, err = bot.WAClient.SendMessage(ctx, evt.Info.Chat, textMsg)
-			if err != nil {
-				log.Warn().Err(err).Msg("Erro ao enviar legenda do GIF, mas GIF foi enviado")
-				// Não retornar erro aqui, pois o GIF já foi enviado
-			}
+	if contextInfo != nil {
+		msg = &waProto.Message{
+			ExtendedTextMessage: &waProto.ExtendedTextMessage{
+				Text:        &text,
+				ContextInfo: contextInfo,
+			},
 		}
 	}
 
-	log.Info().
-		Str("gif", filename).
-		Int("size", len(gifData)).
-		Str("mentioned", targetJID).
-		Msg("GIF com menção enviado com sucesso via sendGIFMessageWithMention")
-
-	return nil
+	_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+	return err
 }
 
 // GroupRules define regras específicas para cada grupo
@@ -1042,6 +1194,10 @@ type GroupRules struct {
 	LastResponse     time.Time `json:"last_response"`     // Última resposta enviada
 	IsPaused         bool      `json:"is_paused"`         // Se o bot está pausado no grupo
 	PausedUntil      time.Time `json:"paused_until"`      // Quando a pausa termina
+	EnableImages     bool      `json:"enable_images"`     // Se geração de imagens está habilitada
+	ImageCooldown    int       `json:"image_cooldown"`    // Cooldown entre imagens geradas (segundos)
+	LastImage        time.Time `json:"last_image"`        // Última imagem gerada
+	AllowNSFW        bool      `json:"allow_nsfw"`        // Se ações marcadas como NSFW são permitidas
 }
 
 // NewGroupMessageProcessor cria um novo processador de mensagens de grupo
@@ -1060,6 +1216,13 @@ func (gmp *GroupMessageProcessor) ProcessGroupMessage(ctx context.Context, evt *
 	// Verificar se existem regras para este grupo
 	rules := gmp.getGroupRules(groupJID)
 
+	// Gravar toda mensagem (exceto comandos) no histórico do grupo, usado pelo !resumo e como contexto da IA
+	// A gravação acontece mesmo com o bot pausado ou a IA desabilitada: o bot só não responde
+	var messageID int64
+	if !strings.HasPrefix(msgText, "!") {
+		messageID = gmp.recordMessage(ctx, evt, msgText)
+	}
+
 	// Verificar se o bot está pausado (ignora TODAS as funções, incluindo comandos)
 	if rules.IsPaused {
 		// Verificar se a pausa já expirou
@@ -1120,7 +1283,7 @@ func (gmp *GroupMessageProcessor) ProcessGroupMessage(ctx context.Context, evt *
 			Bool("quoted", botQuoted).
 			Msg("Bot mencionado ou citado, processando com IA")
 
-		return gmp.processWithAI(ctx, evt, msgText, rules)
+		return gmp.processWithAI(ctx, evt, msgText, messageID, rules)
 	}
 
 	// Se RequireMention está ativo e bot não foi mencionado, ignorar
@@ -1137,7 +1300,7 @@ func (gmp *GroupMessageProcessor) ProcessGroupMessage(ctx context.Context, evt *
 		Str("user", evt.Info.Sender.String()).
 		Msg("Processando mensagem com IA (RequireMention desativado)")
 
-	return gmp.processWithAI(ctx, evt, msgText, rules)
+	return gmp.processWithAI(ctx, evt, msgText, messageID, rules)
 }
 
 // processCommand processa comandos especiais
@@ -1179,6 +1342,8 @@ func (gmp *GroupMessageProcessor) getGroupRules(groupJID string) *GroupRules {
 		CustomPrompt:     "",
 		ResponseCooldown: 30,                           // 30 segundos entre respostas
 		LastResponse:     time.Now().Add(-time.Minute), // Permitir resposta imediata
+		EnableImages:     true,                         // Geração de imagens habilitada por padrão
+		ImageCooldown:    120,                          // 2 minutos entre imagens (mais restrito que texto)
 	}
 
 	gmp.groupRules[groupJID] = defaultRules
@@ -1214,6 +1379,11 @@ func (gmp *GroupMessageProcessor) canRespond(rules *GroupRules) bool {
 	return time.Since(rules.LastResponse) > time.Duration(rules.ResponseCooldown)*time.Second
 }
 
+// canGenerateImage verifica se pode gerar uma nova imagem baseado no cooldown de imagens
+func (gmp *GroupMessageProcessor) canGenerateImage(rules *GroupRules) bool {
+	return time.Since(rules.LastImage) > time.Duration(rules.ImageCooldown)*time.Second
+}
+
 // isMentioned verifica se o bot foi mencionado na mensagem
 func (gmp *GroupMessageProcessor) isMentioned(evt *events.Message, msgText string) bool {
 	botJID := gmp.bot.WAClient.Store.ID.ToNonAD().String()
@@ -1309,10 +1479,11 @@ func (gmp *GroupMessageProcessor) isBotQuoted(evt *events.Message) bool {
 }
 
 // processWithAI processa a mensagem usando IA
-func (gmp *GroupMessageProcessor) processWithAI(ctx context.Context, evt *events.Message, msgText string, rules *GroupRules) error {
-	// Verificar se o cliente Gemini está configurado
-	if gmp.bot.geminiClient == nil {
-		log.Warn().Msg("Gemini client não configurado, ignorando mensagem de grupo")
+// messageID é o ID da mensagem no histórico do grupo (0 se ela não foi gravada)
+func (gmp *GroupMessageProcessor) processWithAI(ctx context.Context, evt *events.Message, msgText string, messageID int64, rules *GroupRules) error {
+	// Verificar se o provedor de IA está configurado
+	if gmp.bot.llm == nil {
+		log.Warn().Msg("Provedor de IA não configurado, ignorando mensagem de grupo")
 		return nil
 	}
 
@@ -1322,58 +1493,36 @@ func (gmp *GroupMessageProcessor) processWithAI(ctx context.Context, evt *events
 		log.Warn().Err(errTyping).Msg("Erro ao enviar status de digitando em grupo")
 	}
 
-	// Carregar histórico do grupo (limitado)
-	groupHistory, err := gmp.bot.chatContext.LoadGroupMessages(ctx, rules.GroupJID, rules.MaxMessages)
+	// Carregar histórico do grupo (limitado) até a mensagem atual, que vai separada no prompt
+	// O corte é pelo ID gravado por recordMessage: mensagens que chegam enquanto outra é processada
+	// não tomam o lugar dela nem aparecem como anteriores
+	groupHistory, err := gmp.bot.chatContext.LoadGroupMessagesBefore(ctx, rules.GroupJID, messageID, rules.MaxMessages)
 	if err != nil {
 		log.Error().Err(err).Str("group", rules.GroupJID).Msg("Erro ao carregar histórico do grupo")
 		groupHistory = []ChatMessage{}
 	}
 
-	// Salvar mensagem do usuário
-	err = gmp.bot.chatContext.SaveMessage(ctx, rules.GroupJID, "user", fmt.Sprintf("%s: %s", evt.Info.Sender.User, msgText))
-	if err != nil {
-		log.Error().Err(err).Str("group", rules.GroupJID).Msg("Erro ao salvar mensagem do grupo")
-	}
-
 	// Criar prompt para grupo
-	prompt := gmp.createGroupPrompt(rules, groupHistory, msgText, evt.Info.Sender.User)
-
-	// Gerar resposta com Gemini
-	response, err := gmp.bot.geminiClient.GenerateContent(ctx, prompt)
+	prompt := gmp.createGroupPrompt(rules, groupHistory, msgText, groupSenderName(evt))
+
+	// Gerar resposta da IA em streaming (mensagem atualizada por edições)
+	// A IA pode chamar ferramentas (lembretes, membros do grupo, comandos do bot) antes de responder
+	// Só a instrução do grupo decide se os emojis saem da resposta, nunca o texto dos membros
+	aiCtx := withInstruction(gmp.bot.aiContext(ctx, evt, personaGrupo, "grupo"), gmp.groupSystemPrompt(rules))
+	stream := streamWithTools(aiCtx, gmp.bot.llm, prompt, gmp.bot.tools, &ToolContext{Bot: gmp.bot, Event: evt})
+	response, err := gmp.bot.chunker.SendStream(ctx, evt.Info.Chat, evt.Info.Sender, "🤖 ", stream)
 	if err != nil {
-		log.Error().Err(err).Msg("Erro ao gerar resposta para grupo")
-
-		errorMsg := "❌ Erro ao processar solicitação no grupo."
-		msg := &waProto.Message{
-			Conversation: &errorMsg,
-		}
-		gmp.bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+		gmp.bot.handleAIError(ctx, evt.Info.Chat, err, "Erro ao gerar resposta para grupo", "❌ Erro ao processar solicitação no grupo.")
 		return err
 	}
 
-	// Limitar tamanho da resposta (respostas curtas e diretas)
-	if len(response) > 500 {
-		response = response[:500] + "..."
-	}
-
-	// Salvar resposta da IA
-	err = gmp.bot.chatContext.SaveMessage(ctx, rules.GroupJID, "assistant", response)
-	if err != nil {
-		log.Error().Err(err).Str("group", rules.GroupJID).Msg("Erro ao salvar resposta da IA no grupo")
-	}
-
 	// Atualizar timestamp da última resposta
 	rules.LastResponse = time.Now()
 
-	// Enviar resposta
-	responseMsg := fmt.Sprintf("🤖 %s", response)
-	msg := &waProto.Message{
-		Conversation: &responseMsg,
-	}
-	_, err = gmp.bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+	// Salvar resposta da IA
+	_, err = gmp.bot.chatContext.SaveMessage(ctx, rules.GroupJID, "assistant", response)
 	if err != nil {
-		log.Error().Err(err).Msg("Erro ao enviar resposta para grupo")
-		return err
+		log.Error().Err(err).Str("group", rules.GroupJID).Msg("Erro ao salvar resposta da IA no grupo")
 	}
 
 	// Encerrar status de digitando
@@ -1389,8 +1538,31 @@ func (gmp *GroupMessageProcessor) processWithAI(ctx context.Context, evt *events
 	return nil
 }
 
-// createGroupPrompt cria o prompt personalizado para mensagens de grupo
-func (gmp *GroupMessageProcessor) createGroupPrompt(rules *GroupRules, history []ChatMessage, userMessage, userName string) string {
+// recordMessage grava uma mensagem do grupo no histórico, no formato "Nome: texto"
+// Retorna o ID da mensagem gravada (0 se a gravação falhou)
+func (gmp *GroupMessageProcessor) recordMessage(ctx context.Context, evt *events.Message, msgText string) int64 {
+	messageID, err := gmp.bot.chatContext.SaveMessage(ctx, evt.Info.Chat.String(), "user", groupHistoryText(evt, msgText))
+	if err != nil {
+		log.Error().Err(err).Str("group", evt.Info.Chat.String()).Msg("Erro ao salvar mensagem do grupo")
+	}
+	return messageID
+}
+
+// groupHistoryText é o texto gravado no histórico do grupo: o nome de quem enviou e a mensagem
+func groupHistoryText(evt *events.Message, msgText string) string {
+	return fmt.Sprintf("%s: %s", groupSenderName(evt), msgText)
+}
+
+// groupSenderName retorna o nome de exibição de quem enviou a mensagem (ou o número, sem nome)
+func groupSenderName(evt *events.Message) string {
+	if evt.Info.PushName != "" {
+		return evt.Info.PushName
+	}
+	return evt.Info.Sender.User
+}
+
+// groupSystemPrompt retorna a instrução do bot no grupo: o prompt personalizado ou o padrão
+func (gmp *GroupMessageProcessor) groupSystemPrompt(rules *GroupRules) string {
 	systemPrompt := rules.CustomPrompt
 	if systemPrompt == "" {
 		// Prompt padrão para grupos - direto, curto e natural
@@ -1430,6 +1602,12 @@ func (gmp *GroupMessageProcessor) createGroupPrompt(rules *GroupRules, history [
 ## Contexto da Conversa
 A conversa atual do grupo está abaixo. Use apenas para entender o contexto, mas responda de forma DIRETA e CURTA:`
 	}
+	return systemPrompt
+}
+
+// createGroupPrompt cria o prompt personalizado para mensagens de grupo
+func (gmp *GroupMessageProcessor) createGroupPrompt(rules *GroupRules, history []ChatMessage, userMessage, userName string) string {
+	systemPrompt := gmp.groupSystemPrompt(rules)
 
 	// Formatar histórico do grupo
 	conversationHistory := FormatConversationHistory(history)
@@ -1515,6 +1693,30 @@ func (gmp *GroupMessageProcessor) DisableAI(groupJID string) {
 	rules.EnableAI = false
 }
 
+// EnableImages habilita a geração de imagens para um grupo
+func (gmp *GroupMessageProcessor) EnableImages(groupJID string) {
+	rules := gmp.getGroupRules(groupJID)
+	rules.EnableImages = true
+}
+
+// DisableImages desabilita a geração de imagens para um grupo
+func (gmp *GroupMessageProcessor) DisableImages(groupJID string) {
+	rules := gmp.getGroupRules(groupJID)
+	rules.EnableImages = false
+}
+
+// EnableNSFW permite as ações marcadas como NSFW em um grupo
+func (gmp *GroupMessageProcessor) EnableNSFW(groupJID string) {
+	rules := gmp.getGroupRules(groupJID)
+	rules.AllowNSFW = true
+}
+
+// DisableNSFW bloqueia as ações marcadas como NSFW em um grupo
+func (gmp *GroupMessageProcessor) DisableNSFW(groupJID string) {
+	rules := gmp.getGroupRules(groupJID)
+	rules.AllowNSFW = false
+}
+
 // SetCustomPrompt define um prompt personalizado para o grupo
 func (gmp *GroupMessageProcessor) SetCustomPrompt(groupJID, prompt string) {
 	rules := gmp.getGroupRules(groupJID)
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// aiUnavailableNoticeWindow é o intervalo mínimo entre dois avisos de IA indisponível no mesmo chat
+const aiUnavailableNoticeWindow = 5 * time.Minute
+
+// aiUnavailableMessage é o aviso enviado enquanto o circuito está aberto
+const aiUnavailableMessage = "⚠️ IA temporariamente indisponível. Tente de novo em alguns minutos.\nOs comandos sem IA (!tapa, !roletacasais, !lembrete, !help...) continuam funcionando."
+
+// ErrLLMUnavailable é retornado sem chamar o provedor enquanto o circuito está aberto
+var ErrLLMUnavailable = errors.New("IA temporariamente indisponível")
+
+// Estados do circuit breaker
+const (
+	breakerClosed   = "closed"    // Normal: chamadas passam
+	breakerOpen     = "open"      // Falhas demais: chamadas são recusadas até o cooldown passar
+	breakerHalfOpen = "half-open" // Cooldown passou: uma chamada de teste decide se fecha ou reabre
+)
+
+// CircuitBreaker interrompe as chamadas à IA depois de falhas consecutivas
+type CircuitBreaker struct {
+	threshold int           // Falhas consecutivas para abrir o circuito
+	cooldown  time.Duration // Tempo aberto antes da chamada de teste
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool // Há uma chamada de teste em andamento (half-open)
+}
+
+// NewCircuitBreaker cria um circuit breaker fechado
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     breakerClosed,
+	}
+}
+
+// State retorna o estado atual do circuito
+func (cb *CircuitBreaker) State() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// Allow informa se uma chamada pode ser feita agora
+// Com o circuito aberto e o cooldown vencido, libera uma única chamada de teste
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case breakerOpen:
+		if time.Since(cb.openedAt) < cb.cooldown {
+			return ErrLLMUnavailable
+		}
+		cb.state = breakerHalfOpen
+		cb.probing = true
+		log.Info().Msg("Circuit breaker da IA em teste (half-open)")
+		return nil
+	case breakerHalfOpen:
+		if cb.probing {
+			return ErrLLMUnavailable
+		}
+		cb.probing = true
+		return nil
+	}
+	return nil
+}
+
+// Record registra o resultado de uma chamada liberada por Allow
+// Erros que não indicam problema no provedor (bloqueio de segurança, cancelamento) não contam como falha
+func (cb *CircuitBreaker) Record(ctx context.Context, err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == breakerHalfOpen {
+		cb.probing = false
+	}
+
+	if err != nil && (errors.Is(err, ErrLLMBlocked) || ctx.Err() != nil || errors.Is(err, context.Canceled)) {
+		// Não diz nada sobre a saúde do provedor; em half-open, liberar um novo teste
+		return
+	}
+
+	if err == nil {
+		if cb.state != breakerClosed {
+			log.Info().Msg("Circuit breaker da IA fechado: provedor voltou a responder")
+		}
+		cb.state = breakerClosed
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	if cb.state == breakerHalfOpen || cb.failures >= cb.threshold {
+		if cb.state == breakerClosed {
+			log.Error().
+				Err(err).
+				Int("failures", cb.failures).
+				Dur("cooldown", cb.cooldown).
+				Msg("Circuit breaker da IA aberto: chamadas suspensas")
+		}
+		cb.state = breakerOpen
+		cb.openedAt = time.Now()
+	}
+}
+
+// BreakerProvider envolve um provedor de IA com um circuit breaker
+type BreakerProvider struct {
+	inner   LLMProvider
+	breaker *CircuitBreaker
+}
+
+// NewBreakerProvider cria o provedor protegido pelo circuit breaker
+func NewBreakerProvider(inner LLMProvider, breaker *CircuitBreaker) *BreakerProvider {
+	return &BreakerProvider{
+		inner:   inner,
+		breaker: breaker,
+	}
+}
+
+// Unwrap retorna o provedor envolvido
+func (b *BreakerProvider) Unwrap() LLMProvider {
+	return b.inner
+}
+
+// Name identifica o provedor nos logs
+func (b *BreakerProvider) Name() string {
+	return b.inner.Name()
+}
+
+// GetModel retorna o modelo atual
+func (b *BreakerProvider) GetModel() string {
+	return b.inner.GetModel()
+}
+
+// SetModel troca o modelo
+func (b *BreakerProvider) SetModel(model string) {
+	b.inner.SetModel(model)
+}
+
+// GenerateContent gera uma resposta se o circuito permitir
+func (b *BreakerProvider) GenerateContent(ctx context.Context, prompt string) (string, error) {
+	return resultText(b.Generate(ctx, prompt, nil))
+}
+
+// GenerateContentWithHistory gera uma resposta com histórico se o circuito permitir
+func (b *BreakerProvider) GenerateContentWithHistory(ctx context.Context, prompt string, history []ChatMessage) (string, error) {
+	return resultText(b.Generate(ctx, prompt, history))
+}
+
+// Generate gera a resposta completa se o circuito permitir
+func (b *BreakerProvider) Generate(ctx context.Context, prompt string, history []ChatMessage) (*LLMResult, error) {
+	if err := b.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	result, err := b.inner.Generate(ctx, prompt, history)
+	b.breaker.Record(ctx, err)
+	return result, err
+}
+
+// CountTokens conta os tokens se o circuito permitir
+func (b *BreakerProvider) CountTokens(ctx context.Context, text string) (int, error) {
+	if err := b.breaker.Allow(); err != nil {
+		return 0, err
+	}
+	count, err := b.inner.CountTokens(ctx, text)
+	b.breaker.Record(ctx, err)
+	return count, err
+}
+
+// EmbeddingModel retorna o modelo de embeddings do provedor envolvido (vazio se ele não gera embeddings)
+func (b *BreakerProvider) EmbeddingModel() string {
+	embedder, ok := embedderOf(b.inner)
+	if !ok {
+		return ""
+	}
+	return embedder.EmbeddingModel()
+}
+
+// Embed gera os embeddings se o circuito permitir
+func (b *BreakerProvider) Embed(ctx context.Context, texts []string, query bool) ([][]float32, error) {
+	embedder, ok := embedderOf(b.inner)
+	if !ok {
+		return nil, fmt.Errorf("o provedor %s não gera embeddings", b.Name())
+	}
+	if err := b.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	vectors, err := embedder.Embed(ctx, texts, query)
+	b.breaker.Record(ctx, err)
+	return vectors, err
+}
+
+// GenerateContentStream gera a resposta em streaming se o circuito permitir
+func (b *BreakerProvider) GenerateContentStream(ctx context.Context, prompt string) iter.Seq2[string, error] {
+	return b.stream(ctx, func() iter.Seq2[string, error] {
+		return b.inner.GenerateContentStream(ctx, prompt)
+	})
+}
+
+// GenerateContentStreamWithTools gera a resposta em streaming com ferramentas se o circuito permitir
+func (b *BreakerProvider) GenerateContentStreamWithTools(ctx context.Context, prompt string, tools *ToolRegistry, toolCtx *ToolContext) iter.Seq2[string, error] {
+	return b.stream(ctx, func() iter.Seq2[string, error] {
+		return streamWithTools(ctx, b.inner, prompt, tools, toolCtx)
+	})
+}
+
+// stream repassa o streaming e registra o resultado no circuit breaker
+// Um streaming que entregou texto conta como sucesso, mesmo se for interrompido depois
+func (b *BreakerProvider) stream(ctx context.Context, open func() iter.Seq2[string, error]) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		if err := b.breaker.Allow(); err != nil {
+			yield("", err)
+			return
+		}
+
+		var streamErr error
+		delivered := false
+		defer func() {
+			if delivered {
+				streamErr = nil
+			}
+			b.breaker.Record(ctx, streamErr)
+		}()
+
+		for text, err := range open() {
+			if err != nil {
+				streamErr = err
+				yield("", err)
+				return
+			}
+			delivered = true
+			if !yield(text, nil) {
+				return
+			}
+		}
+	}
+}
+
+// aiNoticeLimiter controla o aviso de IA indisponível: no máximo um por chat a cada janela
+type aiNoticeLimiter struct {
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+}
+
+// shouldNotify informa se o chat deve receber o aviso agora (e registra o envio)
+func (l *aiNoticeLimiter) shouldNotify(chat types.JID) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lastSent == nil {
+		l.lastSent = make(map[string]time.Time)
+	}
+
+	now := time.Now()
+	for key, sentAt := range l.lastSent {
+		if now.Sub(sentAt) >= aiUnavailableNoticeWindow {
+			delete(l.lastSent, key)
+		}
+	}
+
+	if _, recent := l.lastSent[chat.String()]; recent {
+		return false
+	}
+	l.lastSent[chat.String()] = now
+	return true
+}
+
+// handleAIError registra e informa ao chat uma falha de geração da IA
+// Com o circuito aberto ou a cota do grupo esgotada, envia só o aviso (uma vez por janela) e não polui o log;
+// bloqueios dos filtros de segurança recebem uma mensagem explicando o motivo
+func (bot *BotClient) handleAIError(ctx context.Context, chat types.JID, err error, logMsg, errorMsg string) error {
+	if errors.Is(err, ErrLLMUnavailable) {
+		log.Debug().Str("chat", chat.String()).Msg("IA indisponível (circuito aberto)")
+		if !bot.aiNotices.shouldNotify(chat) {
+			return nil
+		}
+		errorMsg = aiUnavailableMessage
+	} else if errors.Is(err, ErrLLMQuotaExceeded) {
+		log.Info().Err(err).Str("chat", chat.String()).Msg("Chamada à IA recusada pela cota do grupo")
+		if !bot.aiNotices.shouldNotify(chat) {
+			return nil
+		}
+		errorMsg = aiQuotaExceededMessage
+	} else if blockedMsg, blocked := blockedUserMessage(err); blocked {
+		// Bloqueio dos filtros: explicar o motivo em vez do erro genérico
+		log.Warn().Err(err).Msg(logMsg)
+		errorMsg = blockedMsg
+	} else {
+		log.Error().Err(err).Msg(logMsg)
+	}
+
+	msg := &waProto.Message{
+		Conversation: &errorMsg,
+	}
+	_, err = bot.WAClient.SendMessage(ctx, chat, msg)
+	return err
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// initCacheTables cria a tabela do cache de respostas da IA
+func (c *ChatContext) initCacheTables() error {
+	// expires_at é guardado em segundos Unix para descartar entradas vencidas direto no SQL
+	createCacheTableQuery := `
+		CREATE TABLE IF NOT EXISTS llm_cache (
+			cache_key TEXT PRIMARY KEY,
+			command TEXT NOT NULL DEFAULT '',
+			model TEXT NOT NULL,
+			response TEXT NOT NULL,
+			tokens INTEGER NOT NULL DEFAULT 0,
+			hits INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL
+		);
+	`
+
+	_, err := c.db.Exec(createCacheTableQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar tabela llm_cache: %w", err)
+	}
+
+	createCacheIndexQuery := `
+		CREATE INDEX IF NOT EXISTS idx_llm_cache_expires
+		ON llm_cache (expires_at);
+	`
+
+	_, err = c.db.Exec(createCacheIndexQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar índice llm_cache: %w", err)
+	}
+
+	return nil
+}
+
+// GetCachedResponse busca uma resposta válida no cache e conta o acerto
+func (c *ChatContext) GetCachedResponse(ctx context.Context, key string, now time.Time) (string, bool, error) {
+	var response string
+	query := `SELECT response FROM llm_cache WHERE cache_key = ? AND expires_at > ?`
+	err := c.db.QueryRowContext(ctx, query, key, now.Unix()).Scan(&response)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("erro ao buscar resposta no cache: %w", err)
+	}
+
+	_, err = c.db.ExecContext(ctx, `UPDATE llm_cache SET hits = hits + 1 WHERE cache_key = ?`, key)
+	if err != nil {
+		return "", false, fmt.Errorf("erro ao contar acerto do cache: %w", err)
+	}
+
+	return response, true, nil
+}
+
+// SaveCachedResponse guarda uma resposta no cache até expiresAt e descarta as entradas vencidas
+func (c *ChatContext) SaveCachedResponse(ctx context.Context, key, command, model, response string, tokens int, expiresAt time.Time) error {
+	now := time.Now()
+
+	_, err := c.db.ExecContext(ctx, `DELETE FROM llm_cache WHERE expires_at <= ?`, now.Unix())
+	if err != nil {
+		return fmt.Errorf("erro ao descartar respostas vencidas do cache: %w", err)
+	}
+
+	query := `
+		INSERT INTO llm_cache (cache_key, command, model, response, tokens, hits, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, 0, ?, ?)
+		ON CONFLICT(cache_key) DO UPDATE SET
+			response = excluded.response,
+			tokens = excluded.tokens,
+			hits = 0,
+			created_at = excluded.created_at,
+			expires_at = excluded.expires_at
+	`
+
+	_, err = c.db.ExecContext(ctx, query, key, command, model, response, tokens, now.Unix(), expiresAt.Unix())
+	if err != nil {
+		return fmt.Errorf("erro ao salvar resposta no cache: %w", err)
+	}
+
+	return nil
+}
+
+// FlushResponseCache apaga todas as respostas do cache e retorna quantas foram apagadas
+func (c *ChatContext) FlushResponseCache(ctx context.Context) (int64, error) {
+	result, err := c.db.ExecContext(ctx, `DELETE FROM llm_cache`)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao limpar cache de respostas: %w", err)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("erro ao verificar limpeza do cache: %w", err)
+	}
+
+	return removed, nil
+}
+
+// CacheEntryStats resume as respostas válidas guardadas no cache de um comando
+type CacheEntryStats struct {
+	Command     string
+	Entries     int64
+	Hits        int64 // Acertos das entradas atuais (desde que foram guardadas)
+	SavedTokens int64 // Tokens que teriam sido gastos sem o cache
+}
+
+// ResponseCacheStats resume as respostas válidas do cache por comando
+func (c *ChatContext) ResponseCacheStats(ctx context.Context, now time.Time) ([]CacheEntryStats, error) {
+	query := `
+		SELECT command, COUNT(*), SUM(hits), SUM(hits * tokens)
+		FROM llm_cache
+		WHERE expires_at > ?
+		GROUP BY command
+		ORDER BY command
+	`
+
+	rows, err := c.db.QueryContext(ctx, query, now.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("erro ao resumir cache de respostas: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []CacheEntryStats
+	for rows.Next() {
+		var entry CacheEntryStats
+		err := rows.Scan(&entry.Command, &entry.Entries, &entry.Hits, &entry.SavedTokens)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler resumo do cache: %w", err)
+		}
+		stats = append(stats, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao percorrer resumo do cache: %w", err)
+	}
+
+	return stats, nil
+}
+
+// cacheCounters são os acertos e erros do cache de um comando desde que o bot iniciou
+type cacheCounters struct {
+	Hits   int64
+	Misses int64
+}
+
+// ResponseCache guarda as respostas da IA dos comandos determinísticos (!explique, !resumo...)
+// A chave é o hash do modelo, do prompt e da configuração de geração, então qualquer mudança
+// de modelo ou de parâmetros da persona gera uma nova resposta
+type ResponseCache struct {
+	chatContext *ChatContext
+	ttl         time.Duration // Validade de cada resposta (0 desliga o cache)
+
+	mu       sync.Mutex
+	counters map[string]*cacheCounters // Por comando
+}
+
+// NewResponseCache cria o cache de respostas com a validade informada
+func NewResponseCache(chatContext *ChatContext, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		chatContext: chatContext,
+		ttl:         ttl,
+		counters:    make(map[string]*cacheCounters),
+	}
+}
+
+// Enabled informa se o cache está ligado
+func (rc *ResponseCache) Enabled() bool {
+	return rc != nil && rc.ttl > 0
+}
+
+// responseCacheKey calcula a chave da resposta: SHA-256 do modelo, do prompt e da configuração da persona
+func responseCacheKey(model, prompt string, persona Persona) string {
+	config, err := json.Marshal(struct {
+		Persona    Persona
+		Generation GenerationSettings
+		Safety     []SafetySetting
+	}{persona, personas.Generation(persona), personas.SafetySettings(persona)})
+	if err != nil {
+		// Os ajustes são sempre serializáveis; sem eles a chave ainda separa modelo e prompt
+		log.Warn().Err(err).Msg("Erro ao serializar configuração para o cache")
+	}
+
+	hash := sha256.New()
+	for _, part := range []string{model, prompt, string(config)} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Generate responde ao prompt pelo cache ou, na falta, pelo provedor, guardando a resposta
+// O modelo e a persona vêm do contexto da chamada (aiContext); respostas cortadas pelo limite
+// de tokens ou geradas por um modelo de fallback não são guardadas (a chave é do modelo principal)
+// Falhas do banco só vão para o log: o comando segue pela IA
+func (rc *ResponseCache) Generate(ctx context.Context, provider LLMProvider, command, prompt string) (string, error) {
+	if !rc.Enabled() {
+		return provider.GenerateContent(ctx, prompt)
+	}
+
+	model := modelFromContext(ctx, provider.GetModel())
+	key := responseCacheKey(model, prompt, personaFromContext(ctx))
+
+	response, found, err := rc.chatContext.GetCachedResponse(ctx, key, time.Now())
+	if err != nil {
+		log.Warn().Err(err).Str("command", command).Msg("Erro ao consultar cache de respostas")
+	}
+	if found {
+		rc.count(command, true)
+		log.Debug().Str("command", command).Str("model", model).Msg("Resposta da IA servida pelo cache")
+		return response, nil
+	}
+	rc.count(command, false)
+
+	result, err := provider.Generate(ctx, prompt, nil)
+	if err != nil {
+		return "", err
+	}
+	if result.Truncated() {
+		return result.Text, nil
+	}
+	if result.Model != "" && result.Model != model {
+		log.Debug().Str("command", command).Str("model", model).Str("fallback", result.Model).Msg("Resposta de modelo de fallback não vai para o cache")
+		return result.Text, nil
+	}
+
+	err = rc.chatContext.SaveCachedResponse(context.WithoutCancel(ctx), key, command, model, result.Text,
+		result.Usage.TotalTokens, time.Now().Add(rc.ttl))
+	if err != nil {
+		log.Warn().Err(err).Str("command", command).Msg("Erro ao guardar resposta no cache")
+	}
+	return result.Text, nil
+}
+
+// count registra um acerto ou um erro do cache
+func (rc *ResponseCache) count(command string, hit bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	counters, ok := rc.counters[command]
+	if !ok {
+		counters = &cacheCounters{}
+		rc.counters[command] = counters
+	}
+	if hit {
+		counters.Hits++
+	} else {
+		counters.Misses++
+	}
+}
+
+// Counters retorna uma cópia dos acertos e erros por comando desde que o bot iniciou
+func (rc *ResponseCache) Counters() map[string]cacheCounters {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	counters := make(map[string]cacheCounters, len(rc.counters))
+	for command, value := range rc.counters {
+		counters[command] = *value
+	}
+	return counters
+}
+
+// handleCacheCommand mostra as métricas do cache de respostas e permite limpá-lo (só administradores)
+// Uso: !cache | !cache limpar
+func (ch *CommandHandler) handleCacheCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
+	if !bot.requireAdmin(ctx, evt) {
+		return nil
+	}
+
+	if !bot.cache.Enabled() {
+		return ch.sendReplyMessage(ctx, "ℹ️ O cache de respostas da IA está desligado (-cachettl=0).", nil, evt, bot)
+	}
+
+	if len(args) > 0 {
+		switch strings.ToLower(args[0]) {
+		case "limpar", "limpa", "apagar":
+			removed, err := bot.chatContext.FlushResponseCache(ctx)
+			if err != nil {
+				log.Error().Err(err).Msg("Erro ao limpar cache de respostas")
+				return ch.sendReplyMessage(ctx, "❌ Erro ao limpar o cache de respostas.", nil, evt, bot)
+			}
+			log.Info().Int64("removed", removed).Str("by", evt.Info.Sender.String()).Msg("Cache de respostas da IA limpo")
+			return ch.sendReplyMessage(ctx, fmt.Sprintf("🧹 Cache de respostas limpo: %d resposta(s) apagada(s).", removed), nil, evt, bot)
+		default:
+			return ch.sendReplyMessage(ctx, "❌ Use: !cache (métricas) ou !cache limpar", nil, evt, bot)
+		}
+	}
+
+	stats, err := bot.chatContext.ResponseCacheStats(ctx, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao resumir cache de respostas")
+		return ch.sendReplyMessage(ctx, "❌ Erro ao consultar o cache de respostas.", nil, evt, bot)
+	}
+	counters := bot.cache.Counters()
+
+	// Comandos com respostas guardadas ou com consultas desde que o bot iniciou
+	commands := make([]string, 0, len(counters))
+	for command := range counters {
+		commands = append(commands, command)
+	}
+	for _, entry := range stats {
+		if !slices.Contains(commands, entry.Command) {
+			commands = append(commands, entry.Command)
+		}
+	}
+	slices.Sort(commands)
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "🗄️ *Cache de respostas da IA* (validade: %s)\n", bot.cache.ttl)
+	if len(commands) == 0 {
+		text.WriteString("\nNenhuma resposta guardada ainda.")
+	}
+	for _, command := range commands {
+		var entry CacheEntryStats
+		for _, candidate := range stats {
+			if candidate.Command == command {
+				entry = candidate
+			}
+		}
+		counter := counters[command]
+
+		fmt.Fprintf(&text, "\n*!%s*\n", command)
+		if total := counter.Hits + counter.Misses; total > 0 {
+			fmt.Fprintf(&text, "Desde que o bot iniciou: %d acerto(s) em %d consulta(s) (%d%%)\n",
+				counter.Hits, total, counter.Hits*100/total)
+		}
+		fmt.Fprintf(&text, "Guardadas: %d resposta(s), %d acerto(s), ~%s tokens economizados\n",
+			entry.Entries, entry.Hits, formatTokenCount(entry.SavedTokens))
+	}
+	text.WriteString("\n!cache limpar - apagar todas as respostas guardadas")
+
+	return ch.sendReplyMessage(ctx, text.String(), nil, evt, bot)
+}
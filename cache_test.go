@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// newTestChatContext cria um ChatContext em um SQLite em memória
+func newTestChatContext(t *testing.T) *ChatContext {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	chatContext, err := NewChatContext(db, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chatContext
+}
+
+func TestResponseCacheGenerate(t *testing.T) {
+	tests := []struct {
+		name         string
+		primaryErr   error
+		wantText     string
+		wantPrimary  int // Chamadas ao modelo principal nas duas gerações
+		wantFallback int // Chamadas ao modelo de fallback nas duas gerações
+	}{
+		{name: "resposta do principal vem do cache", wantText: "principal", wantPrimary: 1},
+		{name: "resposta do fallback não é guardada", primaryErr: &OpenAIStatusError{StatusCode: http.StatusNotFound}, wantText: "reserva", wantPrimary: 2, wantFallback: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := NewFakeProvider("principal")
+			primary.SetError(tt.primaryErr)
+			fallback := NewFakeProvider("reserva")
+			fallback.SetModel("reserva")
+			provider := NewResilientProvider(primary, []LLMProvider{fallback}, 1)
+
+			cache := NewResponseCache(newTestChatContext(t), time.Hour)
+			for i := range 2 {
+				text, err := cache.Generate(context.Background(), provider, "explique", "o que é um boleto?")
+				if err != nil {
+					t.Fatalf("Generate() %d erro inesperado: %v", i, err)
+				}
+				if text != tt.wantText {
+					t.Errorf("Generate() %d = %q, esperava %q", i, text, tt.wantText)
+				}
+			}
+
+			if got := len(primary.Prompts()); got != tt.wantPrimary {
+				t.Errorf("chamadas ao principal = %d, esperava %d", got, tt.wantPrimary)
+			}
+			if got := len(fallback.Prompts()); got != tt.wantFallback {
+				t.Errorf("chamadas ao fallback = %d, esperava %d", got, tt.wantFallback)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// chunkPendingTTL é o tempo que o restante de uma resposta fica disponível para o !mais
+const chunkPendingTTL = 30 * time.Minute
+
+// chunkMoreFooter é o aviso adicionado à última parte enviada quando ainda há conteúdo
+const chunkMoreFooter = "\n\n_(continua... digite *!mais* para ver o resto)_"
+
+// MessageChunker divide respostas longas em várias mensagens do WhatsApp
+// Envia no máximo maxParts mensagens por vez; o restante fica guardado para o !mais
+type MessageChunker struct {
+	client   *whatsmeow.Client
+	maxRunes int           // Tamanho máximo de cada mensagem (em caracteres)
+	maxParts int           // Mensagens enviadas por vez antes de exigir !mais
+	delay    time.Duration // Intervalo entre mensagens (com "digitando")
+
+	mu      sync.Mutex
+	pending map[string]*pendingChunks // Chat + quem pediu -> partes ainda não enviadas
+}
+
+// pendingChunks guarda as partes de uma resposta que excederam o limite de mensagens
+type pendingChunks struct {
+	chunks    []string
+	expiresAt time.Time
+}
+
+// NewMessageChunker cria um novo divisor de mensagens
+func NewMessageChunker(client *whatsmeow.Client, maxRunes, maxParts int, delay time.Duration) *MessageChunker {
+	if maxRunes < 100 {
+		maxRunes = 100
+	}
+	if maxParts < 1 {
+		maxParts = 1
+	}
+
+	return &MessageChunker{
+		client:   client,
+		maxRunes: maxRunes,
+		maxParts: maxParts,
+		delay:    delay,
+		pending:  make(map[string]*pendingChunks),
+	}
+}
+
+// Send envia um texto possivelmente longo para o chat, dividido em partes
+// O ContextInfo (menções/citação) vai apenas na primeira mensagem, cujo ID é retornado
+// O restante fica guardado para o !mais de quem pediu a resposta (requester)
+func (mc *MessageChunker) Send(ctx context.Context, chat, requester types.JID, text string, contextInfo *waProto.ContextInfo) (types.MessageID, error) {
+	chunks := splitMessage(strings.TrimSpace(text), mc.maxRunes)
+
+	// Uma nova resposta substitui qualquer restante anterior da mesma pessoa no chat
+	var rest []string
+	if len(chunks) > mc.maxParts {
+		chunks, rest = chunks[:mc.maxParts], chunks[mc.maxParts:]
+	}
+	mc.setPending(chat, requester, rest)
+
+	return mc.sendChunks(ctx, chat, chunks, contextInfo, len(rest) > 0)
+}
+
+// SendMore envia as próximas partes guardadas para quem pediu no chat
+// Retorna false quando não há nada pendente
+func (mc *MessageChunker) SendMore(ctx context.Context, chat, requester types.JID) (bool, error) {
+	key := pendingKey(chat, requester)
+	mc.mu.Lock()
+	pending, ok := mc.pending[key]
+	if !ok || time.Now().After(pending.expiresAt) {
+		delete(mc.pending, key)
+		mc.mu.Unlock()
+		return false, nil
+	}
+
+	chunks := pending.chunks
+	var rest []string
+	if len(chunks) > mc.maxParts {
+		chunks, rest = chunks[:mc.maxParts], chunks[mc.maxParts:]
+	}
+	if len(rest) > 0 {
+		pending.chunks = rest
+	} else {
+		delete(mc.pending, key)
+	}
+	mc.mu.Unlock()
+
+	_, err := mc.sendChunks(ctx, chat, chunks, nil, len(rest) > 0)
+	return true, err
+}
+
+// pendingKey identifica o restante guardado: em grupos, cada pessoa tem o seu
+func pendingKey(chat, requester types.JID) string {
+	return chat.String() + "|" + requester.ToNonAD().String()
+}
+
+// setPending guarda (ou limpa) o restante de uma resposta para quem pediu no chat
+func (mc *MessageChunker) setPending(chat, requester types.JID, rest []string) {
+	key := pendingKey(chat, requester)
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	// Remover restantes expirados de outros chats
+	now := time.Now()
+	for key, pending := range mc.pending {
+		if now.After(pending.expiresAt) {
+			delete(mc.pending, key)
+		}
+	}
+
+	if len(rest) == 0 {
+		delete(mc.pending, key)
+		return
+	}
+	mc.pending[key] = &pendingChunks{
+		chunks:    rest,
+		expiresAt: now.Add(chunkPendingTTL),
+	}
+}
+
+// sendChunks envia as partes em ordem, com "digitando" e um pequeno intervalo entre elas
+func (mc *MessageChunker) sendChunks(ctx context.Context, chat types.JID, chunks []string, contextInfo *waProto.ContextInfo, hasMore bool) (types.MessageID, error) {
+	var firstID types.MessageID
+	defer mc.client.SendChatPresence(ctx, chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
+
+	for i, chunk := range chunks {
+		if i > 0 {
+			mc.client.SendChatPresence(ctx, chat, types.ChatPresenceComposing, types.ChatPresenceMediaText)
+			select {
+			case <-ctx.Done():
+				return firstID, ctx.Err()
+			case <-time.After(mc.delay):
+			}
+		}
+
+		if hasMore && i == len(chunks)-1 {
+			chunk += chunkMoreFooter
+		}
+
+		msg := &waProto.Message{
+			Conversation: &chunk,
+		}
+		if i == 0 && contextInfo != nil {
+			msg = &waProto.Message{
+				ExtendedTextMessage: &waProto.ExtendedTextMessage{
+					Text:        &chunk,
+					ContextInfo: contextInfo,
+				},
+			}
+		}
+
+		resp, err := mc.client.SendMessage(ctx, chat, msg)
+		if err != nil {
+			return firstID, fmt.Errorf("erro ao enviar parte %d/%d: %w", i+1, len(chunks), err)
+		}
+		if i == 0 {
+			firstID = resp.ID
+		}
+	}
+
+	if len(chunks) > 1 || hasMore {
+		log.Info().
+			Str("chat", chat.String()).
+			Int("parts", len(chunks)).
+			Bool("hasMore", hasMore).
+			Msg("Resposta longa enviada em partes")
+	}
+
+	return firstID, nil
+}
+
+// splitMessage divide um texto em partes de até maxRunes caracteres
+// Prefere quebrar entre parágrafos, depois entre linhas, frases e palavras; nunca no meio de um caractere
+func splitMessage(text string, maxRunes int) []string {
+	var chunks []string
+	for utf8.RuneCountInString(text) > maxRunes {
+		cut := splitPoint(text, maxRunes)
+		chunk := strings.TrimSpace(text[:cut])
+		if chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		text = strings.TrimSpace(text[cut:])
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
+
+// splitPoint retorna a posição (em bytes) onde o texto deve ser quebrado
+func splitPoint(text string, maxRunes int) int {
+	// Limite em bytes correspondente a maxRunes caracteres
+	limit := len(text)
+	count := 0
+	for i := range text {
+		if count == maxRunes {
+			limit = i
+			break
+		}
+		count++
+	}
+	window := text[:limit]
+
+	// Não aceitar quebras muito no começo (gera mensagens minúsculas)
+	minCut := len(window) / 3
+
+	for _, sep := range []string{"\n\n", "\n"} {
+		if i := strings.LastIndex(window, sep); i > minCut {
+			return i + len(sep)
+		}
+	}
+
+	// Fim de frase: pontuação seguida de espaço
+	best := -1
+	for _, sep := range []string{". ", "! ", "? ", "… ", ".\" ", "; "} {
+		if i := strings.LastIndex(window, sep); i > minCut && i+len(sep) > best {
+			best = i + len(sep)
+		}
+	}
+	if best > 0 {
+		return best
+	}
+
+	if i := strings.LastIndex(window, " "); i > minCut {
+		return i + 1
+	}
+
+	// Sem separadores: quebrar no limite de caracteres
+	return limit
+}
+
+// handleMaisCommand processa o comando !mais enviando o restante da última resposta longa pedida pelo autor no chat
+func (bot *BotClient) handleMaisCommand(ctx context.Context, chat, requester types.JID) error {
+	sent, err := bot.chunker.SendMore(ctx, chat, requester)
+	if err != nil || sent {
+		return err
+	}
+
+	infoMsg := "🤷 Não há mais nada para mostrar."
+	msg := &waProto.Message{
+		Conversation: &infoMsg,
+	}
+	_, err = bot.WAClient.SendMessage(ctx, chat, msg)
+	return err
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxRunes int
+		want     []string
+	}{
+		{name: "texto curto fica inteiro", text: "olá", maxRunes: 10, want: []string{"olá"}},
+		{name: "quebra entre parágrafos", text: "primeiro parágrafo\n\nsegundo", maxRunes: 20, want: []string{"primeiro parágrafo", "segundo"}},
+		{name: "quebra no fim da frase", text: "Frase um. Frase dois longa", maxRunes: 20, want: []string{"Frase um.", "Frase dois longa"}},
+		{name: "quebra entre palavras", text: "uma duas três quatro", maxRunes: 12, want: []string{"uma duas", "três quatro"}},
+		{name: "acentos sem espaço respeitam caracteres", text: "ááááá", maxRunes: 2, want: []string{"áá", "áá", "á"}},
+		{name: "emojis sem espaço respeitam caracteres", text: "😀😁😂🤣😃", maxRunes: 3, want: []string{"😀😁😂", "🤣😃"}},
+		{name: "texto vazio", text: "", maxRunes: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitMessage(tt.text, tt.maxRunes)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("splitMessage(%q, %d) = %q, esperava %q", tt.text, tt.maxRunes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitMessageRuneBoundaries(t *testing.T) {
+	text := strings.Repeat("ação👍🏽é", 40) + " " + strings.Repeat("você não 🇧🇷 ", 30)
+	const maxRunes = 17
+
+	chunks := splitMessage(text, maxRunes)
+	for i, chunk := range chunks {
+		if !utf8.ValidString(chunk) {
+			t.Errorf("parte %d com UTF-8 inválido: %q", i, chunk)
+		}
+		if n := utf8.RuneCountInString(chunk); n > maxRunes {
+			t.Errorf("parte %d com %d caracteres, máximo %d", i, n, maxRunes)
+		}
+	}
+
+	// Nenhum caractere se perde: só os espaços das quebras somem
+	strip := func(s string) string { return strings.Join(strings.Fields(s), "") }
+	if got := strip(strings.Join(chunks, "")); got != strip(text) {
+		t.Errorf("partes juntas = %q, esperava %q", got, strip(text))
+	}
+}
+
+func TestSplitPoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxRunes int
+		want     int // Posição em bytes
+	}{
+		{name: "sem separador corta no limite de caracteres", text: "ééééé", maxRunes: 3, want: 6},
+		{name: "quebra de linha", text: "linha um\nlinha dois", maxRunes: 12, want: 9},
+		{name: "espaço cedo demais é ignorado", text: "a bcdefghijkl", maxRunes: 9, want: 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitPoint(tt.text, tt.maxRunes); got != tt.want {
+				t.Errorf("splitPoint(%q, %d) = %d, esperava %d", tt.text, tt.maxRunes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageChunkerPendingPerRequester(t *testing.T) {
+	group := types.NewJID("123456789", types.GroupServer)
+	otherGroup := types.NewJID("987654321", types.GroupServer)
+	ana := types.NewJID("5598999999999", types.DefaultUserServer)
+	anaPhone := types.JID{User: ana.User, Server: ana.Server, Device: 3}
+	joao := types.NewJID("5511988887777", types.DefaultUserServer)
+
+	mc := NewMessageChunker(nil, 100, 1, 0)
+	mc.setPending(group, ana, []string{"resto da Ana"})
+	mc.setPending(group, joao, []string{"resto do João"})
+	mc.setPending(otherGroup, ana, []string{"resto da Ana no outro grupo"})
+
+	tests := []struct {
+		name      string
+		chat      types.JID
+		requester types.JID
+		want      []string // nil quando não há nada pendente
+	}{
+		{name: "cada pessoa tem o seu restante", chat: group, requester: joao, want: []string{"resto do João"}},
+		{name: "aparelho da mesma pessoa usa o mesmo restante", chat: group, requester: anaPhone, want: []string{"resto da Ana"}},
+		{name: "cada chat tem o seu restante", chat: otherGroup, requester: ana, want: []string{"resto da Ana no outro grupo"}},
+		{name: "outra pessoa não pega o restante", chat: otherGroup, requester: joao},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			if pending, ok := mc.pending[pendingKey(tt.chat, tt.requester)]; ok {
+				got = pending.chunks
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("restante = %q, esperava %q", got, tt.want)
+			}
+		})
+	}
+
+	// Nova resposta curta da Ana limpa só o restante dela
+	mc.setPending(group, ana, nil)
+	if _, ok := mc.pending[pendingKey(group, ana)]; ok {
+		t.Error("restante da Ana continua guardado depois de uma resposta curta")
+	}
+	if _, ok := mc.pending[pendingKey(group, joao)]; !ok {
+		t.Error("restante do João sumiu com a resposta da Ana")
+	}
+
+	// Restante expirado não é enviado pelo !mais
+	mc.pending[pendingKey(group, joao)].expiresAt = time.Now().Add(-time.Second)
+	sent, err := mc.SendMore(context.Background(), group, joao)
+	if err != nil || sent {
+		t.Errorf("SendMore() = %v, %v; esperava nada pendente", sent, err)
+	}
+	if _, ok := mc.pending[pendingKey(group, joao)]; ok {
+		t.Error("restante expirado continua guardado")
+	}
+}
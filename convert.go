@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gifCacheDir é o diretório onde ficam os MP4 convertidos a partir de GIF/WebP
+const gifCacheDir = "cache/gif"
+
+// ffmpegConvertTimeout limita uma conversão; um ffmpeg travado não pode prender o comando
+const ffmpegConvertTimeout = time.Minute
+
+// supportedGIFExtensions são as extensões aceitas nas pastas de ações
+var supportedGIFExtensions = map[string]bool{
+	".mp4":  true,
+	".gif":  true,
+	".webp": true,
+}
+
+// GIFConverter converte GIFs e WebPs para MP4 H.264 compatível com o WhatsApp
+// A conversão usa o binário ffmpeg local; sem ele, apenas arquivos .mp4 são utilizados
+type GIFConverter struct {
+	ffmpegPath string
+	cacheDir   string
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex // Evita converter o mesmo arquivo em paralelo
+}
+
+// NewGIFConverter cria um novo conversor usando o ffmpeg encontrado no PATH
+func NewGIFConverter(cacheDir string) *GIFConverter {
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		log.Warn().Msg("ffmpeg não encontrado no PATH, arquivos .gif e .webp serão ignorados")
+		ffmpegPath = ""
+	}
+
+	return &GIFConverter{
+		ffmpegPath: ffmpegPath,
+		cacheDir:   cacheDir,
+		locks:      make(map[string]*sync.Mutex),
+	}
+}
+
+// Available informa se a conversão está disponível
+func (c *GIFConverter) Available() bool {
+	return c != nil && c.ffmpegPath != ""
+}
+
+// ToMP4 retorna o caminho de um MP4 equivalente ao arquivo informado
+// Arquivos .mp4 são retornados como estão; os demais são convertidos e guardados em cache
+func (c *GIFConverter) ToMP4(path string) (string, error) {
+	if strings.EqualFold(filepath.Ext(path), ".mp4") {
+		return path, nil
+	}
+
+	if !c.Available() {
+		return "", fmt.Errorf("ffmpeg indisponível para converter %s", filepath.Base(path))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("erro ao ler arquivo para conversão: %w", err)
+	}
+
+	// Cache indexado pelo conteúdo do arquivo original
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	output := filepath.Join(c.cacheDir, hash+".mp4")
+
+	lock := c.lockFor(hash)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := os.Stat(output); err == nil {
+		return output, nil
+	}
+
+	err = os.MkdirAll(c.cacheDir, 0751)
+	if err != nil {
+		return "", fmt.Errorf("erro ao criar diretório de cache: %w", err)
+	}
+
+	// Converter para um arquivo temporário e renomear ao final para não deixar MP4 pela metade
+	tmpOutput := output + ".tmp"
+	convertCtx, cancel := context.WithTimeout(context.Background(), ffmpegConvertTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(convertCtx, c.ffmpegPath,
+		"-y",
+		"-loglevel", "error",
+		"-i", path,
+		"-movflags", "+faststart",
+		"-pix_fmt", "yuv420p",
+		"-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2", // H.264 exige dimensões pares
+		"-c:v", "libx264",
+		"-an",
+		"-f", "mp4",
+		tmpOutput)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmpOutput)
+		return "", fmt.Errorf("erro ao converter %s: %w: %s", filepath.Base(path), err, strings.TrimSpace(string(out)))
+	}
+
+	err = os.Rename(tmpOutput, output)
+	if err != nil {
+		return "", fmt.Errorf("erro ao salvar MP4 convertido: %w", err)
+	}
+
+	log.Info().
+		Str("source", path).
+		Str("output", output).
+		Msg("GIF convertido para MP4")
+
+	return output, nil
+}
+
+// WarmUp converte antecipadamente todos os arquivos não-MP4 das pastas informadas
+func (c *GIFConverter) WarmUp(folders []string) {
+	if !c.Available() {
+		return
+	}
+
+	converted := 0
+	for _, folder := range folders {
+		for _, path := range listGIFFiles(folder) {
+			if strings.EqualFold(filepath.Ext(path), ".mp4") {
+				continue
+			}
+			if _, err := c.ToMP4(path); err != nil {
+				log.Warn().Err(err).Str("path", path).Msg("Erro ao pré-converter GIF")
+				continue
+			}
+			converted++
+		}
+	}
+
+	log.Info().Int("files", converted).Msg("Pré-conversão de GIFs concluída")
+}
+
+// lockFor retorna o mutex associado a um arquivo de saída
+func (c *GIFConverter) lockFor(hash string) *sync.Mutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lock, ok := c.locks[hash]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.locks[hash] = lock
+	}
+	return lock
+}
+
+// listGIFFiles lista os arquivos suportados de uma pasta de ação
+// Quando existem versões do mesmo arquivo (ex: bang.gif e bang.mp4), apenas o .mp4 é mantido
+func listGIFFiles(folder string) []string {
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return nil
+	}
+
+	byName := make(map[string]string)
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if !supportedGIFExtensions[ext] {
+			continue
+		}
+
+		base := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		current, exists := byName[base]
+		if !exists {
+			names = append(names, base)
+		}
+		if !exists || ext == ".mp4" || (ext == ".gif" && !strings.EqualFold(filepath.Ext(current), ".mp4")) {
+			byName[base] = entry.Name()
+		}
+	}
+
+	files := make([]string, 0, len(names))
+	for _, base := range names {
+		files = append(files, filepath.Join(folder, byName[base]))
+	}
+	return files
+}
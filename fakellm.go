@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"iter"
+	"strings"
+	"sync"
+)
+
+// fakeDefaultResponses são as respostas usadas pelo FakeProvider quando nenhuma foi configurada
+var fakeDefaultResponses = []string{
+	"Resposta de teste número um.",
+	"Resposta de teste número dois, um pouco mais longa para exercitar o streaming.",
+	"Resposta de teste número três.",
+}
+
+// FakeProvider é um provedor de IA determinístico, sem rede, para testes e desenvolvimento
+// A resposta é escolhida pelo hash do prompt, então o mesmo prompt sempre gera a mesma resposta
+type FakeProvider struct {
+	model     string
+	responses []string
+
+	mu      sync.Mutex
+	prompts []string // Prompts recebidos, na ordem (para inspeção em testes)
+	err     error    // Erro retornado em todas as chamadas, quando definido
+}
+
+// NewFakeProvider cria um provedor falso com as respostas informadas (ou as respostas padrão)
+func NewFakeProvider(responses ...string) *FakeProvider {
+	if len(responses) == 0 {
+		responses = fakeDefaultResponses
+	}
+	return &FakeProvider{
+		model:     "fake",
+		responses: responses,
+	}
+}
+
+// Name identifica o provedor nos logs
+func (f *FakeProvider) Name() string {
+	return "fake"
+}
+
+// GetModel retorna o modelo atual
+func (f *FakeProvider) GetModel() string {
+	return f.model
+}
+
+// SetModel define o modelo a ser usado (apenas guardado)
+func (f *FakeProvider) SetModel(model string) {
+	f.model = model
+}
+
+// SetError faz todas as chamadas seguintes falharem com o erro informado (nil volta ao normal)
+func (f *FakeProvider) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// Prompts retorna uma cópia dos prompts recebidos até agora
+func (f *FakeProvider) Prompts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.prompts...)
+}
+
+// GenerateContent retorna a resposta determinística do prompt
+func (f *FakeProvider) GenerateContent(ctx context.Context, prompt string) (string, error) {
+	return f.respond(ctx, prompt)
+}
+
+// GenerateContentWithHistory ignora o histórico e responde como GenerateContent
+func (f *FakeProvider) GenerateContentWithHistory(ctx context.Context, prompt string, history []ChatMessage) (string, error) {
+	return f.respond(ctx, prompt)
+}
+
+// Generate responde como GenerateContent, com o uso de tokens estimado
+func (f *FakeProvider) Generate(ctx context.Context, prompt string, history []ChatMessage) (*LLMResult, error) {
+	response, err := f.respond(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	model := modelFromContext(ctx, f.model)
+	usage := fakeUsage(prompt, response)
+	recordUsage(ctx, model, usage, 0)
+	return &LLMResult{
+		Text:         response,
+		Parts:        1,
+		FinishReason: finishReasonStop,
+		Usage:        usage,
+		Model:        model,
+	}, nil
+}
+
+// fakeUsage estima os tokens de uma resposta do provedor falso
+func fakeUsage(prompt, response string) LLMUsage {
+	usage := LLMUsage{
+		PromptTokens:    estimateTokens(prompt),
+		CandidateTokens: estimateTokens(response),
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CandidateTokens
+	return usage
+}
+
+// GenerateContentStream entrega a resposta palavra por palavra (texto acumulado)
+func (f *FakeProvider) GenerateContentStream(ctx context.Context, prompt string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		response, err := f.respond(ctx, prompt)
+		if err != nil {
+			yield("", err)
+			return
+		}
+		recordUsage(ctx, modelFromContext(ctx, f.model), fakeUsage(prompt, response), 0)
+
+		words := strings.Fields(response)
+		for i := range words {
+			if !yield(strings.Join(words[:i+1], " "), nil) {
+				return
+			}
+		}
+	}
+}
+
+// ListModels retorna só o modelo configurado (o provedor falso aceita qualquer nome)
+func (f *FakeProvider) ListModels(ctx context.Context) ([]string, error) {
+	return []string{f.model}, nil
+}
+
+// CountTokens estima os tokens do texto
+func (f *FakeProvider) CountTokens(ctx context.Context, text string) (int, error) {
+	return estimateTokens(text), nil
+}
+
+// respond registra o prompt e escolhe a resposta pelo hash dele
+func (f *FakeProvider) respond(ctx context.Context, prompt string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.prompts = append(f.prompts, prompt)
+	if f.err != nil {
+		return "", f.err
+	}
+	if len(f.responses) == 0 {
+		return "", fmt.Errorf("resposta vazia do provedor falso")
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(prompt))
+	return f.responses[hash.Sum32()%uint32(len(f.responses))], nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// Expressões usadas na conversão de Markdown para a formatação do WhatsApp
+var (
+	mdFenceRe      = regexp.MustCompile("^\\s*```")
+	mdHeaderRe     = regexp.MustCompile(`^\s{0,3}#{1,6}\s+(.+?)\s*#*\s*$`)
+	mdRuleRe       = regexp.MustCompile(`^\s{0,3}([-*_])(\s*([-*_])){2,}\s*$`)
+	mdBulletRe     = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+	mdImageRe      = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)[^)]*\)`)
+	mdLinkRe       = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)[^)]*\)`)
+	mdBoldRe       = regexp.MustCompile(`\*\*([^*\n]+?)\*\*|__([^_\n]+?)__`)
+	mdItalicRe     = regexp.MustCompile(`(^|[^*\w])\*([^*\s][^*\n]*?)\*([^*\w]|$)`)
+	mdStrikeRe     = regexp.MustCompile(`~~([^~\n]+?)~~`)
+	mdTableSepRe   = regexp.MustCompile(`^\s*\|?\s*:?-{2,}:?\s*(\|\s*:?-{2,}:?\s*)*\|?\s*$`)
+	mdBlankLinesRe = regexp.MustCompile(`\n{3,}`)
+	mdMultiSpaceRe = regexp.MustCompile(` {2,}`)
+
+	// noEmojiPromptRe detecta prompts que proíbem emojis na resposta
+	noEmojiPromptRe = regexp.MustCompile(`(?i)(não|nao) use emojis|sem emojis`)
+)
+
+// Marcadores temporários para o negrito não ser confundido com itálico durante a conversão
+const (
+	boldOpen  = "\x01"
+	boldClose = "\x02"
+)
+
+// promptForbidsEmojis informa se o prompt pede uma resposta sem emojis
+func promptForbidsEmojis(prompt string) bool {
+	return noEmojiPromptRe.MatchString(prompt)
+}
+
+// instructionContextKey é a chave da instrução do sistema no contexto da chamada
+type instructionContextKey struct{}
+
+// withInstruction marca a chamada à IA com a instrução do sistema (persona, prompt do grupo ou do comando),
+// sem o texto dos usuários nem o histórico
+func withInstruction(ctx context.Context, instruction string) context.Context {
+	return context.WithValue(ctx, instructionContextKey{}, instruction)
+}
+
+// emojisForbidden informa se a instrução da chamada proíbe emojis
+// Só a instrução conta: um usuário escrevendo "sem emojis" não muda as outras respostas
+func emojisForbidden(ctx context.Context) bool {
+	instruction, _ := ctx.Value(instructionContextKey{}).(string)
+	return promptForbidsEmojis(instruction)
+}
+
+// formatResponse aplica a formatação do WhatsApp em uma resposta da IA
+// Remove os emojis quando a instrução os proíbe
+func formatResponse(text string, stripEmojis bool) string {
+	text = MarkdownToWhatsApp(text)
+	if stripEmojis {
+		text = StripEmojis(text)
+	}
+	return text
+}
+
+// MarkdownToWhatsApp converte Markdown para a formatação suportada pelo WhatsApp
+// **negrito** -> *negrito*, *itálico* -> _itálico_, ~~riscado~~ -> ~riscado~, títulos viram negrito,
+// links viram "texto (url)", listas usam "•" e tabelas são achatadas em linhas simples.
+// Blocos de código são mantidos como blocos monoespaçados (```), sem a linguagem.
+func MarkdownToWhatsApp(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	result := make([]string, 0, len(lines))
+
+	inFence := false
+	var table [][]string
+
+	flushTable := func() {
+		result = append(result, flattenTable(table)...)
+		table = nil
+	}
+
+	for _, line := range lines {
+		// Blocos de código: conteúdo mantido sem conversão
+		if mdFenceRe.MatchString(line) {
+			if len(table) > 0 {
+				flushTable()
+			}
+			inFence = !inFence
+			result = append(result, "```")
+			continue
+		}
+		if inFence {
+			result = append(result, line)
+			continue
+		}
+
+		// Tabelas: acumular as linhas e achatar ao final
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "|") && strings.Count(trimmed, "|") >= 2 {
+			if !mdTableSepRe.MatchString(trimmed) {
+				table = append(table, splitTableRow(trimmed))
+			}
+			continue
+		}
+		if len(table) > 0 {
+			flushTable()
+		}
+
+		// Linhas horizontais não existem no WhatsApp
+		if mdRuleRe.MatchString(line) {
+			result = append(result, "")
+			continue
+		}
+
+		// Títulos viram negrito
+		if m := mdHeaderRe.FindStringSubmatch(line); m != nil {
+			title := strings.NewReplacer("**", "", "__", "").Replace(m[1])
+			result = append(result, boldOpen+convertInline(title)+boldClose)
+			continue
+		}
+
+		// Listas com "-", "*" ou "+" usam "•"
+		line = mdBulletRe.ReplaceAllString(line, "$1• ")
+
+		result = append(result, convertInline(line))
+	}
+	if len(table) > 0 {
+		flushTable()
+	}
+	// Bloco de código sem fechamento
+	if inFence {
+		result = append(result, "```")
+	}
+
+	text = strings.Join(result, "\n")
+	text = strings.NewReplacer(boldOpen, "*", boldClose, "*").Replace(text)
+	text = mdBlankLinesRe.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// convertInline converte a formatação dentro de uma linha (links, negrito, itálico e riscado)
+func convertInline(line string) string {
+	// Código inline é mantido como está (o WhatsApp também usa `código`)
+	parts := strings.Split(line, "`")
+	for i := 0; i < len(parts); i += 2 {
+		part := parts[i]
+		part = mdImageRe.ReplaceAllString(part, "$2")
+		part = mdLinkRe.ReplaceAllStringFunc(part, func(match string) string {
+			m := mdLinkRe.FindStringSubmatch(match)
+			if m[1] == m[2] {
+				return m[2]
+			}
+			return m[1] + " (" + m[2] + ")"
+		})
+		part = mdBoldRe.ReplaceAllStringFunc(part, func(match string) string {
+			m := mdBoldRe.FindStringSubmatch(match)
+			return boldOpen + m[1] + m[2] + boldClose
+		})
+		// O itálico usa "_" no WhatsApp; aplicar duas vezes para itálicos adjacentes
+		part = mdItalicRe.ReplaceAllString(part, "${1}_${2}_${3}")
+		part = mdItalicRe.ReplaceAllString(part, "${1}_${2}_${3}")
+		part = mdStrikeRe.ReplaceAllString(part, "~$1~")
+		parts[i] = part
+	}
+	return strings.Join(parts, "`")
+}
+
+// splitTableRow separa as células de uma linha de tabela Markdown
+func splitTableRow(row string) []string {
+	row = strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|")
+	cells := strings.Split(row, "|")
+	for i, cell := range cells {
+		cells[i] = convertInline(strings.TrimSpace(cell))
+	}
+	return cells
+}
+
+// flattenTable transforma uma tabela em linhas de texto: o cabeçalho em negrito e cada linha como item
+func flattenTable(rows [][]string) []string {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	lines := []string{boldOpen + strings.Join(rows[0], " | ") + boldClose}
+	for _, row := range rows[1:] {
+		lines = append(lines, "• "+strings.Join(row, " | "))
+	}
+	return lines
+}
+
+// StripEmojis remove emojis (e seus modificadores) do texto
+func StripEmojis(text string) string {
+	var result strings.Builder
+	for _, r := range text {
+		if isEmojiRune(r) {
+			continue
+		}
+		result.WriteRune(r)
+	}
+
+	// Limpar espaços duplicados deixados pelos emojis removidos (mantendo a indentação)
+	lines := strings.Split(result.String(), "\n")
+	for i, line := range lines {
+		body := strings.TrimLeft(line, " ")
+		indent := line[:len(line)-len(body)]
+		lines[i] = strings.TrimRight(indent+mdMultiSpaceRe.ReplaceAllString(body, " "), " ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// isEmojiRune informa se o caractere pertence aos blocos de emojis
+func isEmojiRune(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // Emoticons, símbolos, transportes, bandeiras, etc.
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // Símbolos diversos e dingbats
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // Setas e estrelas (⭐, ⬆)
+		return true
+	case r >= 0x2300 && r <= 0x23FF: // Símbolos técnicos (⌚, ⏰)
+		return true
+	case r == 0xFE0F || r == 0x200D || r == 0x20E3: // Seletor de variação, ZWJ e keycap
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // Tags de bandeiras
+		return true
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+func TestMarkdownToWhatsApp(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "título vira negrito", text: "## Receita de bolo", want: "*Receita de bolo*"},
+		{name: "título com negrito e fechamento", text: "# **Resumo** #", want: "*Resumo*"},
+		{name: "negrito e itálico", text: "**forte** e *leve*", want: "*forte* e _leve_"},
+		{name: "riscado", text: "~~antigo~~ novo", want: "~antigo~ novo"},
+		{name: "lista com hífen e asterisco", text: "- um\n* dois\n  + três", want: "• um\n• dois\n  • três"},
+		{name: "link com texto", text: "veja [a documentação](https://go.dev/doc) aqui", want: "veja a documentação (https://go.dev/doc) aqui"},
+		{name: "link com a própria URL", text: "[https://go.dev](https://go.dev)", want: "https://go.dev"},
+		{name: "imagem vira a URL", text: "![logo](https://go.dev/logo.png)", want: "https://go.dev/logo.png"},
+		{name: "snake_case não vira itálico", text: "use a variável max_output_tokens e o snake_case", want: "use a variável max_output_tokens e o snake_case"},
+		{name: "multiplicação não vira itálico", text: "2 * 3 * 4 = 24", want: "2 * 3 * 4 = 24"},
+		{name: "código inline é mantido", text: "rode `go test ./... **agora**` já", want: "rode `go test ./... **agora**` já"},
+		{
+			name: "bloco de código perde a linguagem e não é convertido",
+			text: "Exemplo:\n```go\nx := **y** * z\n# comentário\n```\nfim",
+			want: "Exemplo:\n```\nx := **y** * z\n# comentário\n```\nfim",
+		},
+		{name: "bloco de código sem fechamento", text: "```\ncódigo", want: "```\ncódigo\n```"},
+		{
+			name: "tabela achatada com cabeçalho em negrito",
+			text: "Preços:\n| Item | Preço |\n|:-----|------:|\n| Café | **R$ 5** |\n| Pão | R$ 1 |\nfim",
+			want: "Preços:\n*Item | Preço*\n• Café | *R$ 5*\n• Pão | R$ 1\nfim",
+		},
+		{name: "linha horizontal e linhas em branco extras", text: "a\n\n---\n\n\nb", want: "a\n\nb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MarkdownToWhatsApp(tt.text); got != tt.want {
+				t.Errorf("MarkdownToWhatsApp(%q) = %q, esperava %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripEmojis(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "sem emojis", text: "Olá, tudo bem? Ação!", want: "Olá, tudo bem? Ação!"},
+		{name: "emoji simples", text: "Bom dia 😀 pessoal", want: "Bom dia pessoal"},
+		{name: "tom de pele", text: "Valeu 👍🏽 demais", want: "Valeu demais"},
+		{name: "sequência com ZWJ", text: "Família 👨‍👩‍👧 feliz", want: "Família feliz"},
+		{name: "bandeira", text: "Vai 🇧🇷!", want: "Vai !"},
+		{name: "símbolo com seletor de variação", text: "Amor ❤️ e sol ☀️", want: "Amor e sol"},
+		{name: "keycap mantém o dígito", text: "Passo 1️⃣ feito", want: "Passo 1 feito"},
+		{name: "mantém a indentação", text: "  • item ⭐\n    sub ✅ item", want: "• item\n    sub item"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripEmojis(tt.text); got != tt.want {
+				t.Errorf("StripEmojis(%q) = %q, esperava %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
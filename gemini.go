@@ -3,17 +3,33 @@ package main
 import (
 	"context"
 	"fmt"
+	"iter"
 	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"google.golang.org/genai"
 )
 
-// GeminiClient é o cliente para interagir com a API do Gemini
+// GeminiClient é o cliente para interagir com a API do Gemini (implementa LLMProvider)
 type GeminiClient struct {
-	client *genai.Client
-	model  string
+	client     *genai.Client
+	mu         sync.RWMutex // Protege model: o !modelo global troca o modelo com o bot rodando
+	model      string
+	imageModel string // Modelo usado para geração de imagens
+
+	embeddingModel string // Modelo usado para os embeddings da base de conhecimento
 }
 
+// Parâmetros dos embeddings do Gemini
+const (
+	geminiEmbeddingDims  = 768 // Dimensões pedidas ao modelo (menos espaço no banco, qualidade parecida)
+	geminiEmbeddingBatch = 100 // Textos por requisição (limite da API)
+)
+
 // NewGeminiClient cria uma nova instância do cliente Gemini
 // A API key pode ser fornecida via variável de ambiente GEMINI_API_KEY
 // ou passada diretamente como parâmetro
@@ -39,78 +55,474 @@ func NewGeminiClient(apiKey string) (*GeminiClient, error) {
 	}
 
 	return &GeminiClient{
-		client: client,
-		model:  "gemini-2.5-flash", // Modelo padrão
+		client:     client,
+		model:      "gemini-2.5-flash",       // Modelo padrão
+		imageModel: "gemini-2.5-flash-image", // Modelo padrão para imagens
+
+		embeddingModel: "gemini-embedding-001", // Modelo padrão para embeddings
 	}, nil
 }
 
+// Name identifica o provedor nos logs
+func (g *GeminiClient) Name() string {
+	return "gemini"
+}
+
 // SetModel define o modelo a ser usado
 func (g *GeminiClient) SetModel(model string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	g.model = model
 }
 
 // GetModel retorna o modelo atual
 func (g *GeminiClient) GetModel() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 	return g.model
 }
 
+// SetImageModel define o modelo a ser usado para geração de imagens
+func (g *GeminiClient) SetImageModel(model string) {
+	g.imageModel = model
+}
+
+// GetImageModel retorna o modelo atual de geração de imagens
+func (g *GeminiClient) GetImageModel() string {
+	return g.imageModel
+}
+
+// SetEmbeddingModel define o modelo a ser usado para embeddings
+func (g *GeminiClient) SetEmbeddingModel(model string) {
+	g.embeddingModel = model
+}
+
+// EmbeddingModel retorna o modelo de embeddings (identifica os vetores guardados no banco)
+func (g *GeminiClient) EmbeddingModel() string {
+	return g.embeddingModel
+}
+
+// Embed gera os embeddings dos textos, em lotes
+// Perguntas e documentos usam tipos de tarefa diferentes para melhorar a busca
+// A API não informa os tokens dos embeddings: o uso registrado é estimado pelo tamanho dos textos
+func (g *GeminiClient) Embed(ctx context.Context, texts []string, query bool) ([][]float32, error) {
+	config := &genai.EmbedContentConfig{
+		TaskType:             "RETRIEVAL_DOCUMENT",
+		OutputDimensionality: int32Ptr(geminiEmbeddingDims),
+	}
+	if query {
+		config.TaskType = "RETRIEVAL_QUERY"
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += geminiEmbeddingBatch {
+		batch := texts[start:min(start+geminiEmbeddingBatch, len(texts))]
+		contents := make([]*genai.Content, len(batch))
+		for i, text := range batch {
+			contents[i] = genai.NewContentFromText(text, genai.RoleUser)
+		}
+
+		start := time.Now()
+		response, err := g.client.Models.EmbedContent(ctx, g.embeddingModel, contents, config)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao gerar embeddings: %w", err)
+		}
+		recordUsage(ctx, g.embeddingModel, LLMUsage{PromptTokens: estimateTokens(strings.Join(batch, "\n"))}, time.Since(start))
+		if len(response.Embeddings) != len(batch) {
+			return nil, fmt.Errorf("o Gemini retornou %d embeddings para %d textos", len(response.Embeddings), len(batch))
+		}
+		for _, embedding := range response.Embeddings {
+			if embedding == nil || len(embedding.Values) == 0 {
+				return nil, fmt.Errorf("embedding vazio na resposta do Gemini")
+			}
+			vectors = append(vectors, embedding.Values)
+		}
+	}
+	return vectors, nil
+}
+
 // GenerateContent gera conteúdo de texto usando o Gemini
 func (g *GeminiClient) GenerateContent(ctx context.Context, prompt string) (string, error) {
-	// Criar conteúdo com o prompt
+	return resultText(g.Generate(ctx, prompt, nil))
+}
+
+// Generate gera uma resposta completa: todas as partes de texto, motivo de término,
+// avaliações de segurança e tokens consumidos
+// Os filtros de segurança seguem a persona marcada no contexto (withPersona)
+func (g *GeminiClient) Generate(ctx context.Context, prompt string, history []ChatMessage) (*LLMResult, error) {
+	contents := geminiContents(prompt, history)
+
+	model := modelFromContext(ctx, g.GetModel())
+	start := time.Now()
+	response, err := g.client.Models.GenerateContent(ctx, model, contents, g.generateConfig(ctx, model))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao gerar conteúdo: %w", err)
+	}
+	// Respostas bloqueadas ou vazias também consomem tokens
+	recordUsage(ctx, model, geminiUsage(response.UsageMetadata), time.Since(start))
+
+	return geminiResult(response, model, emojisForbidden(ctx))
+}
+
+// generateConfig monta a configuração da chamada com os filtros de segurança e os parâmetros
+// de geração da persona marcada no contexto
+func (g *GeminiClient) generateConfig(ctx context.Context, model string) *genai.GenerateContentConfig {
+	persona := personaFromContext(ctx)
+	generation := personas.Generation(persona)
+
+	config := &genai.GenerateContentConfig{
+		Temperature:      generation.Temperature,
+		TopP:             generation.TopP,
+		StopSequences:    generation.StopSequences,
+		ResponseMIMEType: generation.ResponseMIMEType,
+	}
+	if generation.MaxOutputTokens != nil {
+		config.MaxOutputTokens = *generation.MaxOutputTokens
+	}
+	if budget := generation.ThinkingBudget; budget != nil && geminiSupportsThinkingBudget(model, *budget) {
+		config.ThinkingConfig = &genai.ThinkingConfig{ThinkingBudget: budget}
+	}
+
+	for _, setting := range personas.SafetySettings(persona) {
+		config.SafetySettings = append(config.SafetySettings, &genai.SafetySetting{
+			Category:  genai.HarmCategory(setting.Category),
+			Threshold: genai.HarmBlockThreshold(setting.Threshold),
+		})
+	}
+	return config
+}
+
+// geminiSupportsThinkingBudget informa se o modelo aceita o orçamento de raciocínio
+// Modelos sem raciocínio (1.5, 2.0) rejeitam o thinkingConfig e os modelos Pro não podem desligá-lo
+func geminiSupportsThinkingBudget(model string, budget int32) bool {
+	if !strings.Contains(model, "gemini-2.5") && !strings.Contains(model, "gemini-3") {
+		return false
+	}
+	return budget != 0 || !strings.Contains(model, "pro")
+}
+
+// GenerateContentStream gera conteúdo de texto em streaming
+// Cada item do iterador é o texto acumulado até o momento (já formatado para o WhatsApp),
+// entregue assim que o Gemini produz um novo trecho
+func (g *GeminiClient) GenerateContentStream(ctx context.Context, prompt string) iter.Seq2[string, error] {
+	return g.GenerateContentStreamWithTools(ctx, prompt, nil, nil)
+}
+
+// GenerateContentStreamWithTools gera conteúdo em streaming permitindo que o Gemini chame ferramentas
+// Quando o modelo pede uma função, ela é executada e o resultado é devolvido em uma nova rodada,
+// até o modelo responder só com texto ou atingir maxToolIterations
+func (g *GeminiClient) GenerateContentStreamWithTools(ctx context.Context, prompt string, tools *ToolRegistry, toolCtx *ToolContext) iter.Seq2[string, error] {
 	contents := []*genai.Content{
 		{
+			Role: genai.RoleUser,
 			Parts: []*genai.Part{
 				{Text: prompt},
 			},
 		},
 	}
 
-	// Gerar conteúdo
-	response, err := g.client.Models.GenerateContent(ctx, g.model, contents, nil)
-	if err != nil {
-		return "", fmt.Errorf("erro ao gerar conteúdo: %w", err)
+	model := modelFromContext(ctx, g.GetModel())
+	config := g.generateConfig(ctx, model)
+	if tools != nil {
+		config.Tools = []*genai.Tool{
+			{FunctionDeclarations: tools.Declarations()},
+		}
+		// Chamadas de função não funcionam com resposta em JSON
+		config.ResponseMIMEType = ""
 	}
 
-	// Extrair texto da resposta
-	if len(response.Candidates) > 0 && len(response.Candidates[0].Content.Parts) > 0 {
-		return response.Candidates[0].Content.Parts[0].Text, nil
+	stripEmojis := emojisForbidden(ctx)
+
+	return func(yield func(string, error) bool) {
+		// Uso das rodadas concluídas e da rodada atual (cada trecho traz o uso acumulado da rodada)
+		var usage, roundUsage LLMUsage
+		start := time.Now()
+		defer func() {
+			recordUsage(ctx, model, usage.Add(roundUsage), time.Since(start))
+		}()
+
+		var full strings.Builder
+		for iteration := 0; iteration < maxToolIterations; iteration++ {
+			// Partes da resposta do modelo nesta rodada (texto e chamadas de função)
+			var modelParts []*genai.Part
+			var calls []*genai.FunctionCall
+
+			for response, err := range g.client.Models.GenerateContentStream(ctx, model, contents, config) {
+				if err != nil {
+					yield("", fmt.Errorf("erro ao gerar conteúdo em streaming: %w", err))
+					return
+				}
+				if response != nil && response.UsageMetadata != nil {
+					roundUsage = geminiUsage(response.UsageMetadata)
+				}
+				if err := geminiBlockError(response); err != nil {
+					yield("", err)
+					return
+				}
+
+				for _, part := range streamResponseParts(response) {
+					if part == nil {
+						continue
+					}
+					if part.FunctionCall != nil {
+						calls = append(calls, part.FunctionCall)
+					}
+					modelParts = append(modelParts, part)
+				}
+
+				text := streamResponseText(response)
+				if text == "" {
+					continue
+				}
+				full.WriteString(text)
+				if !yield(formatResponse(full.String(), stripEmojis), nil) {
+					return
+				}
+			}
+
+			usage, roundUsage = usage.Add(roundUsage), LLMUsage{}
+			if len(calls) == 0 || tools == nil {
+				return
+			}
+
+			// Executar as funções e devolver os resultados ao modelo
+			responses := make([]*genai.Part, 0, len(calls))
+			for _, call := range calls {
+				part := genai.NewPartFromFunctionResponse(call.Name, tools.Execute(ctx, toolCtx, call))
+				part.FunctionResponse.ID = call.ID
+				responses = append(responses, part)
+			}
+			contents = append(contents,
+				genai.NewContentFromParts(modelParts, genai.RoleModel),
+				genai.NewContentFromParts(responses, genai.RoleUser),
+			)
+
+			// Separar o texto de rodadas diferentes
+			if full.Len() > 0 {
+				full.WriteString("\n\n")
+			}
+		}
+
+		log.Warn().Int("maxIterations", maxToolIterations).Msg("Limite de chamadas de ferramentas atingido")
+		if strings.TrimSpace(full.String()) == "" {
+			yield("", fmt.Errorf("limite de %d rodadas de ferramentas atingido sem resposta", maxToolIterations))
+		}
 	}
+}
 
-	return "", fmt.Errorf("resposta vazia do Gemini")
+// geminiResult converte a resposta do Gemini no resultado estruturado
+// Junta todas as partes de texto (ignorando pensamentos) e trata bloqueios e respostas vazias
+func geminiResult(response *genai.GenerateContentResponse, model string, stripEmojis bool) (*LLMResult, error) {
+	if err := geminiBlockError(response); err != nil {
+		return nil, err
+	}
+	if response == nil || len(response.Candidates) == 0 || response.Candidates[0] == nil {
+		return nil, fmt.Errorf("resposta vazia do Gemini")
+	}
+
+	candidate := response.Candidates[0]
+	result := &LLMResult{
+		FinishReason:  string(candidate.FinishReason),
+		SafetyRatings: geminiSafetyRatings(candidate.SafetyRatings),
+		Usage:         geminiUsage(response.UsageMetadata),
+		Model:         model,
+	}
+
+	var text strings.Builder
+	if candidate.Content != nil {
+		for _, part := range candidate.Content.Parts {
+			if part == nil || part.Thought || part.Text == "" {
+				continue
+			}
+			text.WriteString(part.Text)
+			result.Parts++
+		}
+	}
+
+	if strings.TrimSpace(text.String()) == "" {
+		if result.Truncated() {
+			return nil, fmt.Errorf("resposta vazia do Gemini: limite de tokens de saída atingido")
+		}
+		return nil, fmt.Errorf("resposta vazia do Gemini (motivo de término: %s)", result.FinishReason)
+	}
+	if result.Truncated() {
+		log.Warn().
+			Str("model", model).
+			Int("candidateTokens", result.Usage.CandidateTokens).
+			Msg("Resposta do Gemini cortada pelo limite de tokens de saída")
+	}
+
+	result.Text = formatResponse(text.String(), stripEmojis)
+	return result, nil
+}
+
+// geminiBlockError retorna um *BlockedError quando o prompt ou a resposta foram bloqueados pelos filtros
+func geminiBlockError(response *genai.GenerateContentResponse) error {
+	if response == nil {
+		return nil
+	}
+	if response.PromptFeedback != nil && response.PromptFeedback.BlockReason != "" {
+		return &BlockedError{
+			Prompt:        true,
+			Reason:        string(response.PromptFeedback.BlockReason),
+			SafetyRatings: geminiSafetyRatings(response.PromptFeedback.SafetyRatings),
+		}
+	}
+	if len(response.Candidates) > 0 && response.Candidates[0] != nil {
+		candidate := response.Candidates[0]
+		switch candidate.FinishReason {
+		case genai.FinishReasonSafety, genai.FinishReasonBlocklist, genai.FinishReasonProhibitedContent,
+			genai.FinishReasonSPII, genai.FinishReasonRecitation:
+			return &BlockedError{
+				Reason:        string(candidate.FinishReason),
+				SafetyRatings: geminiSafetyRatings(candidate.SafetyRatings),
+			}
+		}
+	}
+	return nil
+}
+
+// geminiSafetyRatings converte as avaliações de segurança do Gemini
+func geminiSafetyRatings(ratings []*genai.SafetyRating) []SafetyRating {
+	converted := make([]SafetyRating, 0, len(ratings))
+	for _, rating := range ratings {
+		if rating == nil {
+			continue
+		}
+		converted = append(converted, SafetyRating{
+			Category:    string(rating.Category),
+			Probability: string(rating.Probability),
+			Blocked:     rating.Blocked,
+		})
+	}
+	return converted
+}
+
+// geminiUsage converte os metadados de uso de tokens do Gemini
+func geminiUsage(metadata *genai.GenerateContentResponseUsageMetadata) LLMUsage {
+	if metadata == nil {
+		return LLMUsage{}
+	}
+	return LLMUsage{
+		PromptTokens:    int(metadata.PromptTokenCount),
+		CandidateTokens: int(metadata.CandidatesTokenCount),
+		ThinkingTokens:  int(metadata.ThoughtsTokenCount),
+		TotalTokens:     int(metadata.TotalTokenCount),
+	}
+}
+
+// streamResponseParts retorna as partes do primeiro candidato de um trecho da resposta
+func streamResponseParts(response *genai.GenerateContentResponse) []*genai.Part {
+	if response == nil || len(response.Candidates) == 0 || response.Candidates[0] == nil || response.Candidates[0].Content == nil {
+		return nil
+	}
+	return response.Candidates[0].Content.Parts
+}
+
+// streamResponseText extrai o texto de um trecho da resposta em streaming (ignorando pensamentos)
+func streamResponseText(response *genai.GenerateContentResponse) string {
+	var text strings.Builder
+	for _, part := range streamResponseParts(response) {
+		if part == nil || part.Thought {
+			continue
+		}
+		text.WriteString(part.Text)
+	}
+	return text.String()
+}
+
+// geminiContents converte o histórico da conversa para o formato do Gemini e adiciona o novo prompt
+func geminiContents(prompt string, history []ChatMessage) []*genai.Content {
+	contents := make([]*genai.Content, 0, len(history)+1)
+	for _, message := range history {
+		role := genai.RoleUser
+		if message.MessageType == "assistant" {
+			role = genai.RoleModel
+		}
+		contents = append(contents, genai.NewContentFromText(message.MessageText, genai.Role(role)))
+	}
+	return append(contents, genai.NewContentFromText(prompt, genai.RoleUser))
 }
 
 // GenerateContentWithHistory gera conteúdo com histórico de conversa
-func (g *GeminiClient) GenerateContentWithHistory(ctx context.Context, prompt string, history []*genai.Content) (string, error) {
-	// Adicionar o novo prompt ao histórico
-	newContent := &genai.Content{
-		Parts: []*genai.Part{
-			{Text: prompt},
+func (g *GeminiClient) GenerateContentWithHistory(ctx context.Context, prompt string, history []ChatMessage) (string, error) {
+	return resultText(g.Generate(ctx, prompt, history))
+}
+
+// CountTokens conta os tokens de um texto usando a API do Gemini
+func (g *GeminiClient) CountTokens(ctx context.Context, text string) (int, error) {
+	contents := []*genai.Content{genai.NewContentFromText(text, genai.RoleUser)}
+
+	response, err := g.client.Models.CountTokens(ctx, modelFromContext(ctx, g.GetModel()), contents, nil)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao contar tokens: %w", err)
+	}
+
+	return int(response.TotalTokens), nil
+}
+
+// GenerateImage gera uma imagem a partir de uma descrição usando o modelo de imagens
+// Retorna os bytes da imagem e o mimetype informado pelo Gemini
+func (g *GeminiClient) GenerateImage(ctx context.Context, prompt string) ([]byte, string, error) {
+	contents := []*genai.Content{
+		{
+			Parts: []*genai.Part{
+				{Text: prompt},
+			},
 		},
 	}
 
-	contents := append(history, newContent)
+	// Solicitar resposta com imagem (o modelo pode devolver texto junto)
+	config := &genai.GenerateContentConfig{
+		ResponseModalities: []string{"TEXT", "IMAGE"},
+	}
 
-	// Gerar conteúdo
-	response, err := g.client.Models.GenerateContent(ctx, g.model, contents, nil)
+	start := time.Now()
+	response, err := g.client.Models.GenerateContent(ctx, g.imageModel, contents, config)
 	if err != nil {
-		return "", fmt.Errorf("erro ao gerar conteúdo: %w", err)
+		return nil, "", fmt.Errorf("erro ao gerar imagem: %w", err)
 	}
+	recordUsage(ctx, g.imageModel, geminiUsage(response.UsageMetadata), time.Since(start))
+
+	return geminiImage(response)
+}
 
-	// Extrair texto da resposta
-	if len(response.Candidates) > 0 && len(response.Candidates[0].Content.Parts) > 0 {
-		return response.Candidates[0].Content.Parts[0].Text, nil
+// geminiImage procura a primeira parte com dados binários de imagem na resposta
+// Candidatos e partes nulos são ignorados, como em geminiResult
+func geminiImage(response *genai.GenerateContentResponse) ([]byte, string, error) {
+	for _, candidate := range response.Candidates {
+		if candidate == nil || candidate.Content == nil {
+			continue
+		}
+		for _, part := range candidate.Content.Parts {
+			if part != nil && part.InlineData != nil && len(part.InlineData.Data) > 0 {
+				return part.InlineData.Data, part.InlineData.MIMEType, nil
+			}
+		}
 	}
 
-	return "", fmt.Errorf("resposta vazia do Gemini")
+	return nil, "", fmt.Errorf("resposta do Gemini não contém imagem")
 }
 
-// ListAvailableModels retorna uma lista de modelos disponíveis
-func (g *GeminiClient) ListAvailableModels() []string {
-	return []string{
-		"gemini-2.5-flash",
-		"gemini-2.0-flash-exp",
-		"gemini-1.5-pro",
-		"gemini-1.5-flash",
-		"gemini-1.5-flash-8b",
+// ListModels retorna os modelos de texto disponíveis para a API key, consultando a API do Gemini
+// Ficam de fora os modelos que não geram conteúdo (embeddings) e os especializados em imagem e áudio
+func (g *GeminiClient) ListModels(ctx context.Context) ([]string, error) {
+	var models []string
+	for model, err := range g.client.Models.All(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("erro ao listar modelos: %w", err)
+		}
+		if !slices.Contains(model.SupportedActions, "generateContent") {
+			continue
+		}
+
+		name := strings.TrimPrefix(model.Name, "models/")
+		if strings.Contains(name, "image") || strings.Contains(name, "tts") || strings.Contains(name, "audio") {
+			continue
+		}
+		models = append(models, name)
 	}
+
+	sort.Strings(models)
+	return models, nil
 }
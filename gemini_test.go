@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestGeminiImage(t *testing.T) {
+	image := &genai.Part{InlineData: &genai.Blob{Data: []byte("png"), MIMEType: "image/png"}}
+
+	tests := []struct {
+		name       string
+		candidates []*genai.Candidate
+		wantErr    bool
+	}{
+		{name: "sem candidatos", wantErr: true},
+		{name: "candidato nulo", candidates: []*genai.Candidate{nil}, wantErr: true},
+		{name: "candidato sem conteúdo", candidates: []*genai.Candidate{{}}, wantErr: true},
+		{name: "parte nula antes da imagem", candidates: []*genai.Candidate{nil, {Content: &genai.Content{Parts: []*genai.Part{nil, {Text: "aqui está"}, image}}}}},
+		{name: "só texto", candidates: []*genai.Candidate{{Content: &genai.Content{Parts: []*genai.Part{{Text: "não consigo"}}}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, mimeType, err := geminiImage(&genai.GenerateContentResponse{Candidates: tt.candidates})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("geminiImage() = %q, esperava erro", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("geminiImage() erro inesperado: %v", err)
+			}
+			if string(data) != "png" || mimeType != "image/png" {
+				t.Errorf("geminiImage() = %q (%s), esperava a imagem", data, mimeType)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tenorDefaultBaseURL é o endereço padrão da API v2 do Tenor
+const tenorDefaultBaseURL = "https://tenor.googleapis.com/v2"
+
+// tenorCacheDir é o diretório onde os MP4 baixados do Tenor são guardados
+const tenorCacheDir = "cache/tenor"
+
+// tenorMaxDownloadSize limita o tamanho dos MP4 baixados do Tenor (8 MB)
+const tenorMaxDownloadSize = 8 << 20
+
+// GIFProvider fornece o caminho de um MP4 local para um comando de ação
+type GIFProvider interface {
+	// Name retorna o nome do provedor (usado nos logs)
+	Name() string
+	// FindGIF retorna o caminho de um arquivo MP4 pronto para envio
+	FindGIF(ctx context.Context, action *ActionDefinition) (string, error)
+}
+
+// LocalGIFProvider busca GIFs nas pastas locais de static/gif
+type LocalGIFProvider struct {
+	baseDir   string
+	converter *GIFConverter
+}
+
+// NewLocalGIFProvider cria um provedor de GIFs a partir das pastas locais
+func NewLocalGIFProvider(baseDir string, converter *GIFConverter) *LocalGIFProvider {
+	return &LocalGIFProvider{
+		baseDir:   baseDir,
+		converter: converter,
+	}
+}
+
+// Name retorna o nome do provedor
+func (p *LocalGIFProvider) Name() string {
+	return "local"
+}
+
+// FindGIF busca um GIF aleatório na pasta da ação
+func (p *LocalGIFProvider) FindGIF(ctx context.Context, action *ActionDefinition) (string, error) {
+	return p.findInFolder(action.Folder)
+}
+
+// findInFolder busca um GIF aleatório em uma pasta específica
+// Aceita .mp4, .gif e .webp; arquivos que não são MP4 são convertidos (com cache) antes do envio
+func (p *LocalGIFProvider) findInFolder(folder string) (string, error) {
+	// Caminho para a pasta de GIFs
+	gifDir := filepath.Join(p.baseDir, folder)
+
+	if _, err := os.Stat(gifDir); err != nil {
+		return "", fmt.Errorf("erro ao ler diretório de GIFs: %w", err)
+	}
+
+	// Listar arquivos suportados na pasta
+	var gifFiles []string
+	for _, file := range listGIFFiles(gifDir) {
+		// Sem conversor, apenas arquivos .mp4 podem ser enviados
+		if !p.converter.Available() && !strings.EqualFold(filepath.Ext(file), ".mp4") {
+			continue
+		}
+		gifFiles = append(gifFiles, file)
+	}
+
+	// Verificar se há GIFs disponíveis
+	if len(gifFiles) == 0 {
+		return "", fmt.Errorf("nenhum arquivo GIF encontrado na pasta %s", gifDir)
+	}
+
+	// Selecionar GIF aleatório
+	selectedGIF := gifFiles[rand.Intn(len(gifFiles))]
+
+	// Converter para MP4 se necessário (usa o cache quando já convertido)
+	return p.converter.ToMP4(selectedGIF)
+}
+
+// TenorGIFProvider busca GIFs na API do Tenor e guarda os MP4 baixados em disco
+// Sem conexão, reaproveita os MP4 já baixados para a mesma ação
+type TenorGIFProvider struct {
+	apiKey     string
+	baseURL    string
+	cacheDir   string
+	httpClient *http.Client
+	limit      int
+}
+
+// tenorSearchResponse representa a resposta do endpoint /search do Tenor
+type tenorSearchResponse struct {
+	Results []struct {
+		ID           string `json:"id"`
+		MediaFormats map[string]struct {
+			URL  string  `json:"url"`
+			Dims []int   `json:"dims"`
+			Size int64   `json:"size"`
+			Dur  float64 `json:"duration"`
+		} `json:"media_formats"`
+	} `json:"results"`
+}
+
+// NewTenorGIFProvider cria um provedor de GIFs do Tenor
+// baseURL pode apontar para um servidor local que simule a API (vazio usa o endereço oficial)
+func NewTenorGIFProvider(apiKey, baseURL, cacheDir string) *TenorGIFProvider {
+	if baseURL == "" {
+		baseURL = tenorDefaultBaseURL
+	}
+
+	return &TenorGIFProvider{
+		apiKey:     apiKey,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		cacheDir:   cacheDir,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		limit:      20,
+	}
+}
+
+// Name retorna o nome do provedor
+func (p *TenorGIFProvider) Name() string {
+	return "tenor"
+}
+
+// FindGIF busca um GIF no Tenor pela palavra-chave da ação e retorna o MP4 baixado
+func (p *TenorGIFProvider) FindGIF(ctx context.Context, action *ActionDefinition) (string, error) {
+	path, err := p.searchAndDownload(ctx, action)
+	if err == nil {
+		return path, nil
+	}
+
+	// Sem conexão ou erro na API: tentar um MP4 baixado anteriormente para esta ação
+	cached := listGIFFiles(p.actionCacheDir(action))
+	if len(cached) > 0 {
+		log.Warn().Err(err).Str("command", action.Command).Msg("Tenor indisponível, usando GIF do cache")
+		return cached[rand.Intn(len(cached))], nil
+	}
+
+	return "", err
+}
+
+// searchAndDownload consulta a API do Tenor e baixa o MP4 de um resultado aleatório
+func (p *TenorGIFProvider) searchAndDownload(ctx context.Context, action *ActionDefinition) (string, error) {
+	query := action.Search
+	if query == "" {
+		query = action.Command
+	}
+
+	// Ações NSFW usam filtro de conteúdo mais permissivo
+	contentFilter := "medium"
+	if action.NSFW {
+		contentFilter = "low"
+	}
+
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("key", p.apiKey)
+	params.Set("limit", fmt.Sprintf("%d", p.limit))
+	params.Set("media_filter", "mp4")
+	params.Set("contentfilter", contentFilter)
+	params.Set("locale", "pt_BR")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("erro ao criar requisição do Tenor: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		// Não incluir a URL no erro para não expor a API key nos logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", fmt.Errorf("erro ao consultar Tenor: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Tenor retornou status %d", resp.StatusCode)
+	}
+
+	var result tenorSearchResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return "", fmt.Errorf("erro ao interpretar resposta do Tenor: %w", err)
+	}
+
+	// Manter apenas resultados com variante MP4
+	type candidate struct {
+		id  string
+		url string
+	}
+	var candidates []candidate
+	for _, r := range result.Results {
+		if mp4, ok := r.MediaFormats["mp4"]; ok && mp4.URL != "" && r.ID != "" {
+			candidates = append(candidates, candidate{id: r.ID, url: mp4.URL})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("nenhum GIF do Tenor encontrado para %q", query)
+	}
+
+	selected := candidates[rand.Intn(len(candidates))]
+	return p.download(ctx, action, selected.id, selected.url)
+}
+
+// download baixa um MP4 do Tenor para o cache em disco (ou reaproveita se já existir)
+func (p *TenorGIFProvider) download(ctx context.Context, action *ActionDefinition, id, mediaURL string) (string, error) {
+	dir := p.actionCacheDir(action)
+	output := filepath.Join(dir, sanitizeFileName(id)+".mp4")
+	if _, err := os.Stat(output); err == nil {
+		return output, nil
+	}
+
+	err := os.MkdirAll(dir, 0751)
+	if err != nil {
+		return "", fmt.Errorf("erro ao criar diretório de cache do Tenor: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("erro ao criar requisição de download: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("erro ao baixar GIF do Tenor: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download do Tenor retornou status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, tenorMaxDownloadSize+1))
+	if err != nil {
+		return "", fmt.Errorf("erro ao ler GIF do Tenor: %w", err)
+	}
+	if len(data) > tenorMaxDownloadSize {
+		return "", fmt.Errorf("GIF do Tenor excede o tamanho máximo")
+	}
+
+	// Gravar em arquivo temporário e renomear para não deixar downloads incompletos no cache
+	tmpOutput := output + ".tmp"
+	err = os.WriteFile(tmpOutput, data, 0644)
+	if err != nil {
+		return "", fmt.Errorf("erro ao salvar GIF do Tenor: %w", err)
+	}
+	err = os.Rename(tmpOutput, output)
+	if err != nil {
+		os.Remove(tmpOutput)
+		return "", fmt.Errorf("erro ao salvar GIF do Tenor: %w", err)
+	}
+
+	log.Info().
+		Str("command", action.Command).
+		Str("id", id).
+		Int("size", len(data)).
+		Msg("GIF do Tenor baixado e armazenado em cache")
+
+	return output, nil
+}
+
+// actionCacheDir retorna o diretório de cache do Tenor para uma ação
+func (p *TenorGIFProvider) actionCacheDir(action *ActionDefinition) string {
+	return filepath.Join(p.cacheDir, sanitizeFileName(action.Command))
+}
+
+// FallbackGIFProvider tenta vários provedores em ordem até um deles encontrar um GIF
+type FallbackGIFProvider struct {
+	providers []GIFProvider
+}
+
+// NewFallbackGIFProvider cria um provedor que tenta os provedores na ordem informada
+func NewFallbackGIFProvider(providers ...GIFProvider) *FallbackGIFProvider {
+	return &FallbackGIFProvider{providers: providers}
+}
+
+// Name retorna os nomes dos provedores encadeados
+func (p *FallbackGIFProvider) Name() string {
+	names := make([]string, 0, len(p.providers))
+	for _, provider := range p.providers {
+		names = append(names, provider.Name())
+	}
+	return strings.Join(names, "+")
+}
+
+// FindGIF retorna o GIF do primeiro provedor que não falhar
+func (p *FallbackGIFProvider) FindGIF(ctx context.Context, action *ActionDefinition) (string, error) {
+	var errs []error
+	for _, provider := range p.providers {
+		path, err := provider.FindGIF(ctx, action)
+		if err == nil {
+			return path, nil
+		}
+		log.Warn().Err(err).Str("provider", provider.Name()).Str("command", action.Command).Msg("Provedor de GIF falhou, tentando o próximo")
+		errs = append(errs, err)
+	}
+	return "", errors.Join(errs...)
+}
+
+// sanitizeFileName remove caracteres que não podem ser usados em nomes de arquivo
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == '.' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+}
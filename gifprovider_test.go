@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// tenorStandIn simula a API do Tenor: /search responde com um resultado e /media/<id>.mp4 entrega o vídeo
+type tenorStandIn struct {
+	server       *httptest.Server
+	searchStatus atomic.Int32
+	searches     atomic.Int32
+	downloads    atomic.Int32
+	lastQuery    atomic.Value
+}
+
+func newTenorStandIn(t *testing.T) *tenorStandIn {
+	t.Helper()
+	stand := &tenorStandIn{}
+	stand.searchStatus.Store(http.StatusOK)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		stand.searches.Add(1)
+		stand.lastQuery.Store(r.URL.Query())
+		if status := int(stand.searchStatus.Load()); status != http.StatusOK {
+			http.Error(w, "falha simulada", status)
+			return
+		}
+		fmt.Fprintf(w, `{"results":[{"id":"abc123","media_formats":{"mp4":{"url":"%s/media/abc123.mp4"}}}]}`, stand.server.URL)
+	})
+	mux.HandleFunc("/media/", func(w http.ResponseWriter, r *http.Request) {
+		stand.downloads.Add(1)
+		w.Write([]byte("mp4-" + strings.TrimPrefix(r.URL.Path, "/media/")))
+	})
+
+	stand.server = httptest.NewServer(mux)
+	t.Cleanup(stand.server.Close)
+	return stand
+}
+
+func TestTenorGIFProvider(t *testing.T) {
+	action := &ActionDefinition{Command: "tapa", Search: "anime slap", Folder: "slap", Verb: "deu um tapa em"}
+
+	tests := []struct {
+		name          string
+		searchStatus  int
+		cached        bool // Já existe um MP4 baixado para a ação
+		wantErr       bool
+		wantSearches  int32
+		wantDownloads int32
+	}{
+		{name: "busca e baixa", searchStatus: http.StatusOK, wantSearches: 1, wantDownloads: 1},
+		{name: "reaproveita o MP4 em cache", searchStatus: http.StatusOK, cached: true, wantSearches: 1, wantDownloads: 0},
+		{name: "status de erro usa o cache", searchStatus: http.StatusInternalServerError, cached: true, wantSearches: 1, wantDownloads: 0},
+		{name: "status de erro sem cache falha", searchStatus: http.StatusTooManyRequests, wantErr: true, wantSearches: 1, wantDownloads: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stand := newTenorStandIn(t)
+			stand.searchStatus.Store(int32(tt.searchStatus))
+
+			cacheDir := t.TempDir()
+			provider := NewTenorGIFProvider("chave-teste", stand.server.URL+"/", cacheDir)
+			cachedPath := filepath.Join(cacheDir, "tapa", "abc123.mp4")
+			if tt.cached {
+				if err := os.MkdirAll(filepath.Dir(cachedPath), 0751); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(cachedPath, []byte("mp4-em-cache"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			path, err := provider.FindGIF(context.Background(), action)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("FindGIF() = %q, esperava erro", path)
+				}
+				if strings.Contains(err.Error(), "chave-teste") {
+					t.Errorf("erro expõe a API key: %v", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("FindGIF() erro inesperado: %v", err)
+				}
+				if path != cachedPath {
+					t.Errorf("FindGIF() = %q, esperava %q", path, cachedPath)
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := "mp4-abc123.mp4"
+				if tt.cached {
+					want = "mp4-em-cache"
+				}
+				if string(data) != want {
+					t.Errorf("conteúdo = %q, esperava %q", data, want)
+				}
+			}
+
+			if got := stand.searches.Load(); got != tt.wantSearches {
+				t.Errorf("buscas = %d, esperava %d", got, tt.wantSearches)
+			}
+			if got := stand.downloads.Load(); got != tt.wantDownloads {
+				t.Errorf("downloads = %d, esperava %d", got, tt.wantDownloads)
+			}
+			if query, ok := stand.lastQuery.Load().(url.Values); ok {
+				if q := query.Get("q"); q != "anime slap" {
+					t.Errorf("termo de busca = %q, esperava anime slap", q)
+				}
+			}
+		})
+	}
+}
+
+func TestFallbackGIFProviderUsesNextProvider(t *testing.T) {
+	stand := newTenorStandIn(t)
+	stand.searchStatus.Store(http.StatusServiceUnavailable)
+
+	localDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(localDir, "slap"), 0751); err != nil {
+		t.Fatal(err)
+	}
+	localFile := filepath.Join(localDir, "slap", "local.mp4")
+	if err := os.WriteFile(localFile, []byte("mp4-local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := NewFallbackGIFProvider(
+		NewTenorGIFProvider("chave-teste", stand.server.URL, t.TempDir()),
+		NewLocalGIFProvider(localDir, &GIFConverter{}),
+	)
+
+	path, err := provider.FindGIF(context.Background(), &ActionDefinition{Command: "tapa", Folder: "slap"})
+	if err != nil {
+		t.Fatalf("FindGIF() erro inesperado: %v", err)
+	}
+	if path != localFile {
+		t.Errorf("FindGIF() = %q, esperava o arquivo local %q", path, localFile)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// jokeSimilarityThreshold é a similaridade a partir da qual uma piada é considerada repetida
+const jokeSimilarityThreshold = 0.6
+
+// jokeMaxAttempts é o número de tentativas para gerar uma piada inédita na conversa
+const jokeMaxAttempts = 3
+
+// JokeCategory descreve uma categoria de piadas disponível no !piada
+type JokeCategory struct {
+	Key     string   // Nome usado no comando (ex: "tiozao")
+	Aliases []string // Nomes alternativos aceitos
+	Name    string   // Nome exibido nas mensagens
+	Prompt  string   // Instrução enviada à IA
+}
+
+// jokeCategories são as categorias aceitas em "!piada <categoria>"
+var jokeCategories = []JokeCategory{
+	{Key: "tiozao", Aliases: []string{"tiozão", "tio"}, Name: "tiozão", Prompt: "Conte uma piada de tiozão (pavê ou pa cumê, trocadilho bem bobo, daquelas de almoço de domingo)"},
+	{Key: "programacao", Aliases: []string{"programação", "dev", "ti"}, Name: "programação", Prompt: "Conte uma piada sobre programação, desenvolvedores ou tecnologia"},
+	{Key: "trocadilho", Aliases: []string{"trocadilhos"}, Name: "trocadilho", Prompt: "Conte uma piada baseada em um trocadilho com palavras em português"},
+	{Key: "animais", Aliases: []string{"bicho", "bichos"}, Name: "animais", Prompt: "Conte uma piada sobre animais"},
+	{Key: "escola", Aliases: []string{"joaozinho", "joãozinho"}, Name: "escola", Prompt: "Conte uma piada de escola (professora e Joãozinho, provas, recreio)"},
+}
+
+// findJokeCategory procura uma categoria pelo nome ou alias
+func findJokeCategory(name string) (JokeCategory, bool) {
+	name = strings.ToLower(name)
+	for _, category := range jokeCategories {
+		if category.Key == name {
+			return category, true
+		}
+		for _, alias := range category.Aliases {
+			if alias == name {
+				return category, true
+			}
+		}
+	}
+	return JokeCategory{}, false
+}
+
+// jokeCategoryKeys retorna os nomes das categorias no formato "a, b, c"
+func jokeCategoryKeys() string {
+	keys := make([]string, len(jokeCategories))
+	for i, category := range jokeCategories {
+		keys[i] = category.Key
+	}
+	return strings.Join(keys, ", ")
+}
+
+// accentReplacer remove acentos comuns do português para comparar textos
+var accentReplacer = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n",
+)
+
+// normalizeJokeText deixa o texto em minúsculas, sem acentos, pontuação e espaços repetidos
+func normalizeJokeText(text string) string {
+	text = accentReplacer.Replace(strings.ToLower(text))
+
+	var result strings.Builder
+	lastSpace := true
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			result.WriteRune(r)
+			lastSpace = false
+			continue
+		}
+		if !lastSpace {
+			result.WriteRune(' ')
+			lastSpace = true
+		}
+	}
+
+	return strings.TrimSpace(result.String())
+}
+
+// trigrams retorna o conjunto de trigramas de caracteres de um texto normalizado
+func trigrams(text string) map[string]bool {
+	runes := []rune(" " + text + " ")
+	set := make(map[string]bool)
+	for i := 0; i+3 <= len(runes); i++ {
+		set[string(runes[i:i+3])] = true
+	}
+	return set
+}
+
+// jokeSimilarity calcula a similaridade entre duas piadas (coeficiente de Dice sobre trigramas)
+// Retorna um valor entre 0 (nada em comum) e 1 (mesmo texto)
+func jokeSimilarity(a, b string) float64 {
+	setA := trigrams(normalizeJokeText(a))
+	setB := trigrams(normalizeJokeText(b))
+	if len(setA) == 0 || len(setB) == 0 {
+		return 0
+	}
+
+	common := 0
+	for gram := range setA {
+		if setB[gram] {
+			common++
+		}
+	}
+
+	return 2 * float64(common) / float64(len(setA)+len(setB))
+}
+
+// findSimilarJoke retorna a piada do histórico mais parecida com a nova, se passar do limite
+func findSimilarJoke(joke string, history []string) (string, float64, bool) {
+	var (
+		best      string
+		bestScore float64
+	)
+	for _, previous := range history {
+		score := jokeSimilarity(joke, previous)
+		if score > bestScore {
+			best, bestScore = previous, score
+		}
+	}
+	return best, bestScore, bestScore >= jokeSimilarityThreshold
+}
+
+// reactionRating converte uma reação em avaliação: 1 (gostou), -1 (não gostou) ou 0 (ignorada/removida)
+func reactionRating(emoji string) int {
+	switch strings.TrimSuffix(emoji, "\uFE0F") {
+	case "😂", "🤣", "😆", "😄", "😁", "😹", "👍", "👏", "❤", "🔥", "💯":
+		return 1
+	case "👎", "😐", "😑", "🙄", "🥱", "💩":
+		return -1
+	default:
+		return 0
+	}
+}
+
+// handleJokeReaction registra a avaliação de uma piada a partir de uma reação à mensagem do bot
+func (bot *BotClient) handleJokeReaction(ctx context.Context, evt *events.Message, reaction *waProto.ReactionMessage) {
+	if bot.chatContext == nil || reaction.GetKey() == nil {
+		return
+	}
+
+	jokeID, err := bot.chatContext.FindJokeByMessage(ctx, reaction.GetKey().GetID())
+	if err != nil {
+		log.Warn().Err(err).Msg("Erro ao buscar piada da reação")
+		return
+	}
+	if jokeID == 0 {
+		// Reação a uma mensagem que não é piada
+		return
+	}
+
+	// Reação removida (texto vazio) ou emoji neutro remove a avaliação anterior
+	rating := reactionRating(reaction.GetText())
+	rater := evt.Info.Sender.ToNonAD().String()
+
+	err = bot.chatContext.RateJoke(ctx, jokeID, rater, rating)
+	if err != nil {
+		log.Warn().Err(err).Msg("Erro ao salvar avaliação da piada")
+		return
+	}
+
+	log.Info().
+		Int64("jokeID", jokeID).
+		Str("rater", rater).
+		Str("reaction", reaction.GetText()).
+		Int("rating", rating).
+		Msg("Avaliação de piada registrada")
+}
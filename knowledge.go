@@ -0,0 +1,839 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Parâmetros da base de conhecimento (FAQ)
+const (
+	knowledgeMaxChunkChars = 1200 // Seções maiores são divididas por parágrafos
+	localEmbeddingDims     = 512  // Dimensões do embedding local (hash de palavras e trigramas)
+	localEmbeddingModel    = "local-hash-512"
+
+	// Similaridade mínima para um trecho entrar no prompt: os embeddings do Gemini dão notas
+	// altas até para textos pouco relacionados, o local só pontua palavras em comum
+	remoteKnowledgeMinScore = 0.6
+	localKnowledgeMinScore  = 0.2
+
+	// Prazos das chamadas de embedding ao provedor: a pergunta atrasa a resposta da conversa,
+	// então desiste cedo e usa o embedding local; a indexação roda em segundo plano
+	knowledgeQueryTimeout = 5 * time.Second
+	knowledgeIndexTimeout = 2 * time.Minute
+)
+
+// knowledgeStopwords são palavras comuns demais para ajudar na busca local
+var knowledgeStopwords = map[string]bool{
+	"a": true, "o": true, "as": true, "os": true, "um": true, "uma": true, "uns": true, "umas": true,
+	"de": true, "do": true, "da": true, "dos": true, "das": true, "em": true, "no": true, "na": true,
+	"nos": true, "nas": true, "por": true, "para": true, "pra": true, "com": true, "e": true, "ou": true,
+	"que": true, "se": true, "me": true, "te": true, "eu": true, "voce": true, "voces": true, "tu": true,
+	"ele": true, "ela": true, "isso": true, "esse": true, "essa": true, "ao": true, "aos": true,
+	"vcs": true, "vc": true, "ja": true, "mais": true, "muito": true, "tem": true, "ter": true,
+	"sao": true, "ser": true, "qual": true, "quais": true, "como": true,
+}
+
+// KnowledgeChunk é um trecho de um documento da base de conhecimento
+type KnowledgeChunk struct {
+	ID      int64
+	Path    string // Arquivo de origem, relativo à pasta da base
+	Title   string // Título do documento e da seção
+	Content string
+}
+
+// KnowledgeMatch é um trecho encontrado na busca, com a similaridade com a pergunta
+type KnowledgeMatch struct {
+	KnowledgeChunk
+	Score float64
+}
+
+// KnowledgeSyncStats resume uma sincronização da pasta da base com o banco
+type KnowledgeSyncStats struct {
+	Documents int // Documentos na pasta
+	Updated   int // Documentos novos ou alterados (reindexados)
+	Removed   int // Documentos que saíram da pasta
+	Chunks    int // Trechos na base
+}
+
+// initKnowledgeTables cria as tabelas da base de conhecimento
+func (c *ChatContext) initKnowledgeTables() error {
+	createDocumentsTableQuery := `
+		CREATE TABLE IF NOT EXISTS kb_documents (
+			path TEXT PRIMARY KEY,
+			content_hash TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+	`
+
+	_, err := c.db.Exec(createDocumentsTableQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar tabela kb_documents: %w", err)
+	}
+
+	createChunksTableQuery := `
+		CREATE TABLE IF NOT EXISTS kb_chunks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			path TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			content TEXT NOT NULL
+		);
+	`
+
+	_, err = c.db.Exec(createChunksTableQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar tabela kb_chunks: %w", err)
+	}
+
+	createChunksIndexQuery := `
+		CREATE INDEX IF NOT EXISTS idx_kb_chunks_path
+		ON kb_chunks (path);
+	`
+
+	_, err = c.db.Exec(createChunksIndexQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar índice kb_chunks: %w", err)
+	}
+
+	// vector guarda os float32 do embedding em little-endian; cada trecho tem um vetor por modelo
+	createEmbeddingsTableQuery := `
+		CREATE TABLE IF NOT EXISTS kb_embeddings (
+			chunk_id INTEGER NOT NULL,
+			model TEXT NOT NULL,
+			vector BLOB NOT NULL,
+			PRIMARY KEY (chunk_id, model)
+		);
+	`
+
+	_, err = c.db.Exec(createEmbeddingsTableQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar tabela kb_embeddings: %w", err)
+	}
+
+	return nil
+}
+
+// KnowledgeDocumentHashes retorna o hash do conteúdo de cada documento indexado
+func (c *ChatContext) KnowledgeDocumentHashes(ctx context.Context) (map[string]string, error) {
+	rows, err := c.db.QueryContext(ctx, `SELECT path, content_hash FROM kb_documents`)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar documentos da base: %w", err)
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]string)
+	for rows.Next() {
+		var path, hash string
+		if err := rows.Scan(&path, &hash); err != nil {
+			return nil, fmt.Errorf("erro ao ler documento da base: %w", err)
+		}
+		hashes[path] = hash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao percorrer documentos da base: %w", err)
+	}
+
+	return hashes, nil
+}
+
+// ReplaceKnowledgeDocument troca os trechos de um documento (os embeddings antigos são apagados)
+func (c *ChatContext) ReplaceKnowledgeDocument(ctx context.Context, path, hash string, chunks []KnowledgeChunk) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = deleteKnowledgeDocument(ctx, tx, path)
+	if err != nil {
+		return err
+	}
+
+	for position, chunk := range chunks {
+		_, err = tx.ExecContext(ctx, `INSERT INTO kb_chunks (path, position, title, content) VALUES (?, ?, ?, ?)`,
+			path, position, chunk.Title, chunk.Content)
+		if err != nil {
+			return fmt.Errorf("erro ao salvar trecho da base: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO kb_documents (path, content_hash, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)`, path, hash)
+	if err != nil {
+		return fmt.Errorf("erro ao salvar documento da base: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("erro ao confirmar documento da base: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveKnowledgeDocument apaga um documento, seus trechos e embeddings
+func (c *ChatContext) RemoveKnowledgeDocument(ctx context.Context, path string) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = deleteKnowledgeDocument(ctx, tx, path)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("erro ao confirmar remoção do documento da base: %w", err)
+	}
+
+	return nil
+}
+
+// deleteKnowledgeDocument apaga um documento dentro de uma transação
+func deleteKnowledgeDocument(ctx context.Context, tx *sql.Tx, path string) error {
+	queries := []string{
+		`DELETE FROM kb_embeddings WHERE chunk_id IN (SELECT id FROM kb_chunks WHERE path = ?)`,
+		`DELETE FROM kb_chunks WHERE path = ?`,
+		`DELETE FROM kb_documents WHERE path = ?`,
+	}
+	for _, query := range queries {
+		_, err := tx.ExecContext(ctx, query, path)
+		if err != nil {
+			return fmt.Errorf("erro ao apagar documento da base: %w", err)
+		}
+	}
+	return nil
+}
+
+// KnowledgeChunksWithoutEmbedding retorna os trechos que ainda não têm embedding do modelo
+func (c *ChatContext) KnowledgeChunksWithoutEmbedding(ctx context.Context, model string) ([]KnowledgeChunk, error) {
+	query := `
+		SELECT id, path, title, content
+		FROM kb_chunks
+		WHERE id NOT IN (SELECT chunk_id FROM kb_embeddings WHERE model = ?)
+		ORDER BY path, position
+	`
+
+	rows, err := c.db.QueryContext(ctx, query, model)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar trechos sem embedding: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []KnowledgeChunk
+	for rows.Next() {
+		var chunk KnowledgeChunk
+		if err := rows.Scan(&chunk.ID, &chunk.Path, &chunk.Title, &chunk.Content); err != nil {
+			return nil, fmt.Errorf("erro ao ler trecho da base: %w", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao percorrer trechos da base: %w", err)
+	}
+
+	return chunks, nil
+}
+
+// SaveKnowledgeEmbeddings guarda os embeddings dos trechos gerados por um modelo
+func (c *ChatContext) SaveKnowledgeEmbeddings(ctx context.Context, model string, chunks []KnowledgeChunk, vectors [][]float32) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, chunk := range chunks {
+		_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO kb_embeddings (chunk_id, model, vector) VALUES (?, ?, ?)`,
+			chunk.ID, model, encodeVector(vectors[i]))
+		if err != nil {
+			return fmt.Errorf("erro ao salvar embedding da base: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("erro ao confirmar embeddings da base: %w", err)
+	}
+
+	return nil
+}
+
+// SearchKnowledge compara o vetor da pergunta com todos os trechos indexados pelo modelo
+// e retorna os k mais parecidos (a base é pequena: a busca é exata, sem índice vetorial)
+func (c *ChatContext) SearchKnowledge(ctx context.Context, model string, query []float32, k int) ([]KnowledgeMatch, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT c.id, c.path, c.title, c.content, e.vector
+		FROM kb_chunks c
+		JOIN kb_embeddings e ON e.chunk_id = c.id
+		WHERE e.model = ?
+	`, model)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar na base de conhecimento: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []KnowledgeMatch
+	for rows.Next() {
+		var match KnowledgeMatch
+		var vector []byte
+		err := rows.Scan(&match.ID, &match.Path, &match.Title, &match.Content, &vector)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler trecho da base: %w", err)
+		}
+		match.Score = cosineSimilarity(query, decodeVector(vector))
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao percorrer trechos da base: %w", err)
+	}
+
+	slices.SortFunc(matches, func(a, b KnowledgeMatch) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return matches[:min(len(matches), k)], nil
+}
+
+// KnowledgeEmbeddingCounts conta os trechos indexados por modelo de embedding
+func (c *ChatContext) KnowledgeEmbeddingCounts(ctx context.Context) (map[string]int, error) {
+	rows, err := c.db.QueryContext(ctx, `SELECT model, COUNT(*) FROM kb_embeddings GROUP BY model`)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao contar embeddings da base: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var model string
+		var count int
+		if err := rows.Scan(&model, &count); err != nil {
+			return nil, fmt.Errorf("erro ao ler contagem de embeddings: %w", err)
+		}
+		counts[model] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao percorrer contagem de embeddings: %w", err)
+	}
+
+	return counts, nil
+}
+
+// CountKnowledgeChunks conta os trechos da base
+func (c *ChatContext) CountKnowledgeChunks(ctx context.Context) (int, error) {
+	var count int
+	err := c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM kb_chunks`).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao contar trechos da base: %w", err)
+	}
+
+	return count, nil
+}
+
+// encodeVector serializa um vetor de float32 para guardar no SQLite
+func encodeVector(vector []float32) []byte {
+	data := make([]byte, 4*len(vector))
+	for i, value := range vector {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(value))
+	}
+	return data
+}
+
+// decodeVector lê um vetor de float32 guardado por encodeVector
+func decodeVector(data []byte) []float32 {
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vector
+}
+
+// cosineSimilarity calcula a similaridade de cosseno entre dois vetores (0 se tiverem tamanhos diferentes)
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// LocalEmbedder gera embeddings sem rede, pelo hash das palavras e dos trigramas de caracteres
+// Não entende sinônimos como os embeddings do Gemini, mas acha os trechos com as mesmas palavras
+// (os trigramas toleram plurais e erros de digitação)
+type LocalEmbedder struct{}
+
+// EmbeddingModel identifica os vetores gerados no banco
+func (LocalEmbedder) EmbeddingModel() string {
+	return localEmbeddingModel
+}
+
+// Embed gera os vetores dos textos (pergunta e documentos usam o mesmo cálculo)
+func (LocalEmbedder) Embed(ctx context.Context, texts []string, query bool) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = localEmbedding(text)
+	}
+	return vectors, nil
+}
+
+// localEmbedding calcula o vetor de um texto com feature hashing
+func localEmbedding(text string) []float32 {
+	vector := make([]float32, localEmbeddingDims)
+	add := func(feature string, weight float32) {
+		hash := fnv.New32a()
+		hash.Write([]byte(feature))
+		sum := hash.Sum32()
+		// Um bit do hash define o sinal para que colisões tendam a se anular
+		if sum&(1<<31) != 0 {
+			weight = -weight
+		}
+		vector[sum%localEmbeddingDims] += weight
+	}
+
+	for _, word := range strings.Fields(normalizeJokeText(text)) {
+		if len(word) < 2 || knowledgeStopwords[word] {
+			continue
+		}
+		add("w:"+word, 1)
+		for gram := range trigrams(word) {
+			add("t:"+gram, 0.3)
+		}
+	}
+
+	var norm float64
+	for _, value := range vector {
+		norm += float64(value) * float64(value)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+	return vector
+}
+
+// splitKnowledgeDocument divide um documento Markdown em trechos: uma seção "## " por trecho,
+// com seções longas divididas por parágrafos. O título "# " (ou o nome do arquivo) prefixa cada trecho
+func splitKnowledgeDocument(name, text string) []KnowledgeChunk {
+	docTitle := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+
+	type section struct {
+		heading string
+		body    []string
+	}
+	var sections []section
+	current := section{}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "# "):
+			docTitle = strings.TrimSpace(strings.TrimPrefix(trimmed, "# "))
+		case strings.HasPrefix(trimmed, "## "):
+			sections = append(sections, current)
+			current = section{heading: strings.TrimSpace(strings.TrimPrefix(trimmed, "## "))}
+		default:
+			current.body = append(current.body, line)
+		}
+	}
+	sections = append(sections, current)
+
+	var chunks []KnowledgeChunk
+	for _, sec := range sections {
+		body := strings.TrimSpace(strings.Join(sec.body, "\n"))
+		if body == "" {
+			continue
+		}
+		title := docTitle
+		if sec.heading != "" {
+			title = docTitle + " › " + sec.heading
+		}
+
+		// Juntar parágrafos até o limite de tamanho do trecho
+		var part strings.Builder
+		for _, paragraph := range strings.Split(body, "\n\n") {
+			paragraph = strings.TrimSpace(paragraph)
+			if paragraph == "" {
+				continue
+			}
+			if part.Len() > 0 && part.Len()+len(paragraph) > knowledgeMaxChunkChars {
+				chunks = append(chunks, KnowledgeChunk{Path: name, Title: title, Content: part.String()})
+				part.Reset()
+			}
+			if part.Len() > 0 {
+				part.WriteString("\n\n")
+			}
+			part.WriteString(paragraph)
+		}
+		if part.Len() > 0 {
+			chunks = append(chunks, KnowledgeChunk{Path: name, Title: title, Content: part.String()})
+		}
+	}
+	return chunks
+}
+
+// KnowledgeBase é a base de conhecimento (FAQ) usada nas conversas privadas
+// Os documentos Markdown/texto da pasta são divididos em trechos, indexados no SQLite com
+// embeddings do provedor (quando ele gera embeddings) e sempre com o embedding local, que serve
+// de fallback quando o provedor não está disponível
+type KnowledgeBase struct {
+	chatContext *ChatContext
+	dir         string
+	topK        int
+	remote      Embedder // nil quando o provedor não gera embeddings
+	local       Embedder
+
+	mu sync.Mutex // Uma sincronização por vez
+}
+
+// NewKnowledgeBase cria a base de conhecimento da pasta informada
+// topK é quantos trechos entram no prompt (0 desliga a base)
+func NewKnowledgeBase(chatContext *ChatContext, dir string, topK int, provider LLMProvider) *KnowledgeBase {
+	kb := &KnowledgeBase{
+		chatContext: chatContext,
+		dir:         dir,
+		topK:        topK,
+		local:       LocalEmbedder{},
+	}
+	if provider != nil {
+		if embedder, ok := embedderOf(provider); ok {
+			kb.remote = embedder
+		}
+	}
+	return kb
+}
+
+// Enabled informa se a base de conhecimento está ligada
+func (kb *KnowledgeBase) Enabled() bool {
+	return kb != nil && kb.topK > 0
+}
+
+// Sync lê a pasta da base e atualiza o banco: documentos novos ou alterados são reindexados,
+// documentos apagados saem da base e trechos sem embedding recebem um
+// Se o provedor falhar ao gerar embeddings, a base segue só com o embedding local
+func (kb *KnowledgeBase) Sync(ctx context.Context) (KnowledgeSyncStats, error) {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+
+	var stats KnowledgeSyncStats
+
+	files, err := kb.documentFiles()
+	if err != nil {
+		return stats, err
+	}
+	stats.Documents = len(files)
+
+	indexed, err := kb.chatContext.KnowledgeDocumentHashes(ctx)
+	if err != nil {
+		return stats, err
+	}
+
+	for name, text := range files {
+		sum := sha256.Sum256([]byte(text))
+		hash := hex.EncodeToString(sum[:])
+		if indexed[name] == hash {
+			continue
+		}
+
+		err := kb.chatContext.ReplaceKnowledgeDocument(ctx, name, hash, splitKnowledgeDocument(name, text))
+		if err != nil {
+			return stats, err
+		}
+		stats.Updated++
+	}
+
+	for name := range indexed {
+		if _, ok := files[name]; ok {
+			continue
+		}
+		err := kb.chatContext.RemoveKnowledgeDocument(ctx, name)
+		if err != nil {
+			return stats, err
+		}
+		stats.Removed++
+	}
+
+	err = kb.embedMissing(ctx, kb.local)
+	if err != nil {
+		return stats, err
+	}
+	if kb.remote != nil {
+		err = kb.embedMissing(ctx, kb.remote)
+		if err != nil {
+			log.Warn().Err(err).Str("model", kb.remote.EmbeddingModel()).Msg("Erro ao gerar embeddings da base de conhecimento, usando só o embedding local")
+		}
+	}
+
+	stats.Chunks, err = kb.chatContext.CountKnowledgeChunks(ctx)
+	if err != nil {
+		return stats, err
+	}
+
+	log.Info().
+		Int("documents", stats.Documents).
+		Int("updated", stats.Updated).
+		Int("removed", stats.Removed).
+		Int("chunks", stats.Chunks).
+		Msg("Base de conhecimento sincronizada")
+	return stats, nil
+}
+
+// documentFiles lê os documentos .md e .txt da pasta da base (pasta inexistente = base vazia)
+func (kb *KnowledgeBase) documentFiles() (map[string]string, error) {
+	entries, err := os.ReadDir(kb.dir)
+	if os.IsNotExist(err) {
+		log.Info().Str("dir", kb.dir).Msg("Pasta da base de conhecimento não encontrada, base vazia")
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler pasta da base de conhecimento: %w", err)
+	}
+
+	files := make(map[string]string)
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".md" && ext != ".txt") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(kb.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler documento %s da base: %w", entry.Name(), err)
+		}
+		files[entry.Name()] = string(data)
+	}
+	return files, nil
+}
+
+// embedMissing gera os embeddings dos trechos que ainda não têm vetor do modelo
+func (kb *KnowledgeBase) embedMissing(ctx context.Context, embedder Embedder) error {
+	chunks, err := kb.chatContext.KnowledgeChunksWithoutEmbedding(ctx, embedder.EmbeddingModel())
+	if err != nil || len(chunks) == 0 {
+		return err
+	}
+
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Title + "\n" + chunk.Content
+	}
+	embedCtx, cancel := context.WithTimeout(ctx, knowledgeIndexTimeout)
+	defer cancel()
+	vectors, err := embedder.Embed(embedCtx, texts, false)
+	if err != nil {
+		return err
+	}
+	if len(vectors) != len(chunks) {
+		return fmt.Errorf("embeddings recebidos (%d) não correspondem aos trechos (%d)", len(vectors), len(chunks))
+	}
+
+	return kb.chatContext.SaveKnowledgeEmbeddings(ctx, embedder.EmbeddingModel(), chunks, vectors)
+}
+
+// Search retorna os trechos mais parecidos com a pergunta, acima da similaridade mínima
+// Usa os embeddings do provedor quando toda a base foi indexada com eles; se o provedor falhar
+// ou faltar o vetor de algum trecho, cai para o embedding local
+func (kb *KnowledgeBase) Search(ctx context.Context, question string, k int) ([]KnowledgeMatch, string, error) {
+	if kb.remote != nil {
+		matches, err := kb.search(ctx, kb.remote, question, k, remoteKnowledgeMinScore)
+		if err == nil {
+			return matches, kb.remote.EmbeddingModel(), nil
+		}
+		log.Warn().Err(err).Msg("Erro na busca semântica da base de conhecimento, usando o embedding local")
+	}
+
+	matches, err := kb.search(ctx, kb.local, question, k, localKnowledgeMinScore)
+	return matches, kb.local.EmbeddingModel(), err
+}
+
+// search busca com um embedder, exigindo que todos os trechos da base tenham sido indexados por ele
+// Uma indexação incompleta (ex: o provedor falhou no meio da sincronização) deixaria trechos fora da busca
+func (kb *KnowledgeBase) search(ctx context.Context, embedder Embedder, question string, k int, minScore float64) ([]KnowledgeMatch, error) {
+	counts, err := kb.chatContext.KnowledgeEmbeddingCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	total, err := kb.chatContext.CountKnowledgeChunks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if indexed := counts[embedder.EmbeddingModel()]; indexed == 0 || indexed < total {
+		return nil, fmt.Errorf("base de conhecimento com %d de %d trechos indexados pelo modelo %s", indexed, total, embedder.EmbeddingModel())
+	}
+
+	embedCtx, cancel := context.WithTimeout(ctx, knowledgeQueryTimeout)
+	defer cancel()
+	vectors, err := embedder.Embed(embedCtx, []string{question}, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embedding da pergunta não recebido")
+	}
+
+	matches, err := kb.chatContext.SearchKnowledge(ctx, embedder.EmbeddingModel(), vectors[0], k)
+	if err != nil {
+		return nil, err
+	}
+
+	relevant := matches[:0]
+	for _, match := range matches {
+		if match.Score >= minScore {
+			relevant = append(relevant, match)
+		}
+	}
+	return relevant, nil
+}
+
+// PromptContext monta a seção do prompt com os trechos da base relevantes para a pergunta
+// Retorna vazio quando a base está desligada ou nada relevante foi encontrado
+func (kb *KnowledgeBase) PromptContext(ctx context.Context, question string) string {
+	if !kb.Enabled() || strings.TrimSpace(question) == "" {
+		return ""
+	}
+
+	matches, model, err := kb.Search(ctx, question, kb.topK)
+	if err != nil {
+		log.Warn().Err(err).Msg("Erro ao buscar na base de conhecimento")
+		return ""
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+
+	titles := make([]string, len(matches))
+	var text strings.Builder
+	text.WriteString("## Base de conhecimento\n\nInformações oficiais relacionadas à mensagem do usuário:\n")
+	for i, match := range matches {
+		titles[i] = match.Title
+		fmt.Fprintf(&text, "\n### %s\n%s\n", match.Title, match.Content)
+	}
+
+	log.Debug().Str("model", model).Strs("chunks", titles).Msg("Trechos da base de conhecimento incluídos no prompt")
+	return text.String()
+}
+
+// knowledgeQuery monta a pergunta usada na busca: a mensagem atual e, para dar contexto
+// a perguntas de continuação ("e no sábado?"), a mensagem anterior do usuário
+func knowledgeQuery(history []ChatMessage, msgText string) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].MessageType == "user" {
+			return history[i].MessageText + "\n" + msgText
+		}
+	}
+	return msgText
+}
+
+// handleFaqCommand mostra, busca e recarrega a base de conhecimento (só administradores)
+// Uso: !faq | !faq buscar <pergunta> | !faq recarregar
+func (ch *CommandHandler) handleFaqCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
+	if !bot.requireAdmin(ctx, evt) {
+		return nil
+	}
+
+	if !bot.knowledge.Enabled() {
+		return ch.sendReplyMessage(ctx, "ℹ️ A base de conhecimento está desligada (-faqtopk=0).", nil, evt, bot)
+	}
+
+	subcommand := ""
+	if len(args) > 0 {
+		subcommand = strings.ToLower(args[0])
+	}
+
+	switch subcommand {
+	case "":
+		chunks, err := bot.chatContext.CountKnowledgeChunks(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Erro ao contar trechos da base")
+			return ch.sendReplyMessage(ctx, "❌ Erro ao consultar a base de conhecimento.", nil, evt, bot)
+		}
+		hashes, err := bot.chatContext.KnowledgeDocumentHashes(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Erro ao listar documentos da base")
+			return ch.sendReplyMessage(ctx, "❌ Erro ao consultar a base de conhecimento.", nil, evt, bot)
+		}
+		counts, err := bot.chatContext.KnowledgeEmbeddingCounts(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Erro ao contar embeddings da base")
+		}
+
+		documents := make([]string, 0, len(hashes))
+		for name := range hashes {
+			documents = append(documents, name)
+		}
+		slices.Sort(documents)
+
+		var text strings.Builder
+		fmt.Fprintf(&text, "📚 *Base de conhecimento* (pasta %s)\n\n", bot.knowledge.dir)
+		fmt.Fprintf(&text, "Documentos: %d · Trechos: %d · Trechos por pergunta: %d\n", len(documents), chunks, bot.knowledge.topK)
+		for _, name := range documents {
+			fmt.Fprintf(&text, "• %s\n", name)
+		}
+		text.WriteString("\n*Embeddings*\n")
+		for _, embedder := range []Embedder{bot.knowledge.remote, bot.knowledge.local} {
+			if embedder != nil {
+				fmt.Fprintf(&text, "• %s: %d de %d trechos\n", embedder.EmbeddingModel(), counts[embedder.EmbeddingModel()], chunks)
+			}
+		}
+		text.WriteString("\n!faq buscar <pergunta> - testar a busca\n!faq recarregar - reler a pasta")
+		return ch.sendReplyMessage(ctx, text.String(), nil, evt, bot)
+
+	case "recarregar", "atualizar":
+		stats, err := bot.knowledge.Sync(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Erro ao sincronizar base de conhecimento")
+			return ch.sendReplyMessage(ctx, "❌ Erro ao recarregar a base de conhecimento.", nil, evt, bot)
+		}
+		return ch.sendReplyMessage(ctx, fmt.Sprintf("✅ Base recarregada: %d documento(s), %d atualizado(s), %d removido(s), %d trecho(s).",
+			stats.Documents, stats.Updated, stats.Removed, stats.Chunks), nil, evt, bot)
+
+	case "buscar", "busca":
+		question := strings.Join(args[1:], " ")
+		if strings.TrimSpace(question) == "" {
+			return ch.sendReplyMessage(ctx, "❌ Use: !faq buscar <pergunta>", nil, evt, bot)
+		}
+
+		matches, model, err := bot.knowledge.Search(bot.usageContext(ctx, evt, "faq"), question, bot.knowledge.topK)
+		if err != nil {
+			log.Error().Err(err).Msg("Erro ao buscar na base de conhecimento")
+			return ch.sendReplyMessage(ctx, "❌ Erro ao buscar na base de conhecimento.", nil, evt, bot)
+		}
+		if len(matches) == 0 {
+			return ch.sendReplyMessage(ctx, fmt.Sprintf("🔍 Nenhum trecho relevante encontrado (%s).", model), nil, evt, bot)
+		}
+
+		var text strings.Builder
+		fmt.Fprintf(&text, "🔍 *Trechos encontrados* (%s)\n", model)
+		for _, match := range matches {
+			fmt.Fprintf(&text, "\n*%s* (%.2f)\n%s\n", match.Title, match.Score, match.Content)
+		}
+		_, err = bot.chunker.Send(ctx, evt.Info.Chat, evt.Info.Sender, strings.TrimSpace(text.String()), nil)
+		return err
+
+	default:
+		return ch.sendReplyMessage(ctx, "❌ Use: !faq, !faq buscar <pergunta> ou !faq recarregar", nil, evt, bot)
+	}
+}
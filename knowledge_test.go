@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// stubEmbedder imita o embedder do provedor usando os vetores locais; fail simula o provedor fora do ar
+type stubEmbedder struct {
+	fail  bool
+	calls int
+}
+
+func (s *stubEmbedder) EmbeddingModel() string {
+	return "remoto-teste"
+}
+
+func (s *stubEmbedder) Embed(ctx context.Context, texts []string, query bool) ([][]float32, error) {
+	s.calls++
+	if s.fail {
+		return nil, errors.New("provedor fora do ar")
+	}
+	return LocalEmbedder{}.Embed(ctx, texts, query)
+}
+
+func TestKnowledgeSearchRequiresFullRemoteIndex(t *testing.T) {
+	tests := []struct {
+		name      string
+		failLater bool // O provedor falha ao indexar o segundo documento
+		wantModel string
+	}{
+		{name: "base toda indexada usa o provedor", wantModel: "remoto-teste"},
+		{name: "trecho sem vetor remoto usa o embedding local", failLater: true, wantModel: localEmbeddingModel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			remote := &stubEmbedder{}
+			kb := &KnowledgeBase{chatContext: newTestChatContext(t), dir: dir, topK: 3, remote: remote, local: LocalEmbedder{}}
+			ctx := context.Background()
+
+			write := func(name, text string) {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := kb.Sync(ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+			write("horarios.md", "# Horário de funcionamento\nAbrimos de segunda a sexta, das 8h às 18h.")
+			remote.fail = tt.failLater
+			write("entrega.md", "# Entrega\nFazemos entrega de pizza no bairro inteiro, com taxa de entrega grátis.")
+			remote.fail = false
+
+			matches, model, err := kb.Search(ctx, "taxa de entrega de pizza", 3)
+			if err != nil {
+				t.Fatalf("Search() erro inesperado: %v", err)
+			}
+			if model != tt.wantModel {
+				t.Errorf("Search() usou %s, esperava %s", model, tt.wantModel)
+			}
+			if len(matches) == 0 || matches[0].Title != "Entrega" {
+				t.Errorf("Search() = %+v, esperava o trecho de entrega primeiro", matches)
+			}
+		})
+	}
+}
+
+// embeddingFakeProvider é um provedor falso que também gera embeddings
+type embeddingFakeProvider struct {
+	*FakeProvider
+	*stubEmbedder
+}
+
+func TestEmbedderOfWrappers(t *testing.T) {
+	stub := &stubEmbedder{}
+	tests := []struct {
+		name      string
+		inner     LLMProvider
+		wantModel string // Vazio quando o provedor não gera embeddings
+	}{
+		{name: "provedor sem embeddings", inner: NewFakeProvider()},
+		{name: "provedor com embeddings", inner: embeddingFakeProvider{NewFakeProvider(), stub}, wantModel: "remoto-teste"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := NewCircuitBreaker(1, time.Hour)
+			provider := NewQuotaProvider(NewBreakerProvider(NewResilientProvider(tt.inner, nil, 1), breaker))
+
+			embedder, ok := embedderOf(provider)
+			if tt.wantModel == "" {
+				if ok {
+					t.Fatalf("embedderOf() = %s, esperava nenhum", embedder.EmbeddingModel())
+				}
+				return
+			}
+			if !ok || embedder.EmbeddingModel() != tt.wantModel {
+				t.Fatalf("embedderOf() = %v, %v, esperava %s", embedder, ok, tt.wantModel)
+			}
+
+			// A falha do embedding abre o circuito: a chamada seguinte nem chega ao provedor
+			stub.fail = true
+			if _, err := embedder.Embed(context.Background(), []string{"oi"}, true); err == nil {
+				t.Fatal("Embed() esperava erro do provedor")
+			}
+			if breaker.State() != breakerOpen {
+				t.Errorf("circuito = %s, esperava %s", breaker.State(), breakerOpen)
+			}
+			if _, err := embedder.Embed(context.Background(), []string{"oi"}, true); !errors.Is(err, ErrLLMUnavailable) {
+				t.Errorf("Embed() com o circuito aberto = %v, esperava %v", err, ErrLLMUnavailable)
+			}
+			if stub.calls != 1 {
+				t.Errorf("chamadas ao provedor = %d, esperava 1", stub.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// LLMProvider é a interface dos modelos de linguagem usados pelo bot
+// Implementações: GeminiClient (Google Gemini), OpenAIProvider (APIs compatíveis com OpenAI,
+// como Ollama e llama.cpp) e FakeProvider (respostas determinísticas, sem rede)
+// As respostas já vêm formatadas para o WhatsApp
+type LLMProvider interface {
+	// Name identifica o provedor nos logs ("gemini", "openai", "fake")
+	Name() string
+	// GetModel retorna o modelo de texto em uso
+	GetModel() string
+	// SetModel troca o modelo de texto
+	SetModel(model string)
+	// GenerateContent gera uma resposta para o prompt
+	GenerateContent(ctx context.Context, prompt string) (string, error)
+	// GenerateContentWithHistory gera uma resposta considerando as mensagens anteriores da conversa
+	GenerateContentWithHistory(ctx context.Context, prompt string, history []ChatMessage) (string, error)
+	// Generate gera uma resposta completa (texto, motivo de término, avaliações de segurança e uso de tokens)
+	// Bloqueios dos filtros retornam *BlockedError
+	Generate(ctx context.Context, prompt string, history []ChatMessage) (*LLMResult, error)
+	// GenerateContentStream gera a resposta em streaming; cada item é o texto acumulado até o momento
+	GenerateContentStream(ctx context.Context, prompt string) iter.Seq2[string, error]
+	// CountTokens conta (ou estima) os tokens de um texto para o modelo em uso
+	CountTokens(ctx context.Context, text string) (int, error)
+}
+
+// ToolStreamer é implementado pelos provedores que suportam chamadas de ferramentas em streaming
+type ToolStreamer interface {
+	GenerateContentStreamWithTools(ctx context.Context, prompt string, tools *ToolRegistry, toolCtx *ToolContext) iter.Seq2[string, error]
+}
+
+// ImageGenerator é implementado pelos provedores que geram imagens
+type ImageGenerator interface {
+	GetImageModel() string
+	GenerateImage(ctx context.Context, prompt string) ([]byte, string, error)
+}
+
+// ModelLister é implementado pelos provedores que listam os modelos de texto disponíveis na API
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// Embedder é implementado pelos provedores que geram embeddings (usados na base de conhecimento)
+type Embedder interface {
+	EmbeddingModel() string
+	// Embed gera um vetor por texto; query indica se os textos são perguntas ou documentos
+	Embed(ctx context.Context, texts []string, query bool) ([][]float32, error)
+}
+
+// providerWrapper é implementado pelos provedores que envolvem outro (ex: ResilientProvider)
+type providerWrapper interface {
+	Unwrap() LLMProvider
+}
+
+// providerAs procura um recurso opcional (ImageGenerator, ModelLister...) no provedor ou dentro dos wrappers
+func providerAs[T any](provider LLMProvider) (T, bool) {
+	for provider != nil {
+		if feature, ok := provider.(T); ok {
+			return feature, true
+		}
+		wrapper, ok := provider.(providerWrapper)
+		if !ok {
+			break
+		}
+		provider = wrapper.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// imageGeneratorOf retorna o gerador de imagens do provedor, procurando dentro dos wrappers
+func imageGeneratorOf(provider LLMProvider) (ImageGenerator, bool) {
+	return providerAs[ImageGenerator](provider)
+}
+
+// embedderOf retorna o gerador de embeddings do provedor, procurando dentro dos wrappers
+// Os wrappers (circuit breaker, cota) repassam os embeddings e informam modelo vazio quando
+// o provedor envolvido não gera embeddings
+func embedderOf(provider LLMProvider) (Embedder, bool) {
+	embedder, ok := providerAs[Embedder](provider)
+	if !ok || embedder.EmbeddingModel() == "" {
+		return nil, false
+	}
+	return embedder, true
+}
+
+// streamWithTools gera a resposta em streaming usando as ferramentas quando o provedor as suporta
+// Provedores sem suporte respondem apenas com texto
+func streamWithTools(ctx context.Context, provider LLMProvider, prompt string, tools *ToolRegistry, toolCtx *ToolContext) iter.Seq2[string, error] {
+	if streamer, ok := provider.(ToolStreamer); ok && tools != nil {
+		return streamer.GenerateContentStreamWithTools(ctx, prompt, tools, toolCtx)
+	}
+	return provider.GenerateContentStream(ctx, prompt)
+}
+
+// estimateTokens estima os tokens de um texto (~4 caracteres por token)
+// Usado pelos provedores que não têm um endpoint de contagem
+func estimateTokens(text string) int {
+	runes := utf8.RuneCountInString(text)
+	if runes == 0 {
+		return 0
+	}
+	return (runes + 3) / 4
+}
+
+// newLLMProvider cria o provedor de IA escolhido na flag -llm
+// Retorna nil (sem erro) quando o provedor não foi configurado, desabilitando as funções de IA
+func newLLMProvider(name string) (LLMProvider, error) {
+	switch strings.ToLower(name) {
+	case "", "gemini":
+		// A API key pode vir de flag (-geminikey) ou variável de ambiente (GEMINI_API_KEY)
+		if *geminiAPIKey == "" && os.Getenv("GEMINI_API_KEY") == "" {
+			log.Warn().Msg("Gemini API key não fornecida. Funcionalidade de IA desabilitada.")
+			return nil, nil
+		}
+
+		client, err := NewGeminiClient(*geminiAPIKey)
+		if err != nil {
+			return nil, err
+		}
+
+		// Configurar modelos se especificados via flag
+		if *geminiModel != "" {
+			client.SetModel(*geminiModel)
+		}
+		if *geminiImageModel != "" {
+			client.SetImageModel(*geminiImageModel)
+		}
+		if *geminiEmbeddingModel != "" {
+			client.SetEmbeddingModel(*geminiEmbeddingModel)
+		}
+		return client, nil
+
+	case "openai":
+		provider, err := NewOpenAIProvider(*openAIBaseURL, *openAIAPIKey, *openAIModel)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+
+	case "fake":
+		return NewFakeProvider(), nil
+
+	default:
+		return nil, fmt.Errorf("provedor de IA desconhecido: %s (use gemini, openai ou fake)", name)
+	}
+}
+
+// newResilientProvider envolve o provedor principal com novas tentativas e os modelos de fallback
+// Cada modelo de fallback usa uma instância própria do mesmo provedor
+func newResilientProvider(primary LLMProvider, name, fallbackModels string, attempts int) (*ResilientProvider, error) {
+	var fallbacks []LLMProvider
+	for _, model := range strings.Split(fallbackModels, ",") {
+		model = strings.TrimSpace(model)
+		if model == "" || model == primary.GetModel() {
+			continue
+		}
+
+		provider, err := newLLMProvider(name)
+		if err != nil {
+			return nil, err
+		}
+		if provider == nil {
+			return nil, fmt.Errorf("não foi possível criar o provedor para o modelo de fallback %s", model)
+		}
+		provider.SetModel(model)
+		fallbacks = append(fallbacks, provider)
+	}
+
+	return NewResilientProvider(primary, fallbacks, attempts), nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+func TestHistoryConversion(t *testing.T) {
+	tests := []struct {
+		name        string
+		history     []ChatMessage
+		wantOpenAI  []string // Papéis esperados na API compatível com OpenAI, incluindo o prompt
+		wantGemini  []string // Papéis esperados no Gemini, incluindo o prompt
+		wantHistory []string // Linhas esperadas no histórico formatado do prompt
+	}{
+		{
+			name:        "sem histórico",
+			wantOpenAI:  []string{"user"},
+			wantGemini:  []string{genai.RoleUser},
+			wantHistory: []string{"Nenhuma conversa anterior."},
+		},
+		{
+			name: "conversa privada",
+			history: []ChatMessage{
+				{MessageType: "user", MessageText: "oi"},
+				{MessageType: "assistant", MessageText: "Olá! Como posso ajudar?"},
+			},
+			wantOpenAI:  []string{"user", "assistant", "user"},
+			wantGemini:  []string{genai.RoleUser, genai.RoleModel, genai.RoleUser},
+			wantHistory: []string{"Usuário: oi", "DuckerIA: Olá! Como posso ajudar?"},
+		},
+		{
+			name: "grupo com várias pessoas",
+			history: []ChatMessage{
+				{MessageType: "user", MessageText: "Ana: bom dia"},
+				{MessageType: "user", MessageText: "João: alguém vai no churrasco?"},
+				{MessageType: "assistant", MessageText: "Bom dia, pessoal"},
+			},
+			wantOpenAI:  []string{"user", "user", "assistant", "user"},
+			wantGemini:  []string{genai.RoleUser, genai.RoleUser, genai.RoleModel, genai.RoleUser},
+			wantHistory: []string{"Usuário: Ana: bom dia", "Usuário: João: alguém vai no churrasco?", "DuckerIA: Bom dia, pessoal"},
+		},
+	}
+
+	const prompt = "Mensagem atual"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := openAIMessages(prompt, tt.history)
+			var roles []string
+			for _, message := range messages {
+				roles = append(roles, message.Role)
+			}
+			if !slices.Equal(roles, tt.wantOpenAI) {
+				t.Errorf("papéis OpenAI = %v, esperava %v", roles, tt.wantOpenAI)
+			}
+			if last := messages[len(messages)-1].Content; last != prompt {
+				t.Errorf("última mensagem OpenAI = %q, esperava o prompt", last)
+			}
+
+			contents := geminiContents(prompt, tt.history)
+			roles = nil
+			for i, content := range contents {
+				roles = append(roles, content.Role)
+				want := prompt
+				if i < len(tt.history) {
+					want = tt.history[i].MessageText
+				}
+				if got := content.Parts[0].Text; got != want {
+					t.Errorf("conteúdo Gemini %d = %q, esperava %q", i, got, want)
+				}
+			}
+			if !slices.Equal(roles, tt.wantGemini) {
+				t.Errorf("papéis Gemini = %v, esperava %v", roles, tt.wantGemini)
+			}
+
+			formatted := FormatConversationHistory(tt.history)
+			for _, line := range tt.wantHistory {
+				if !strings.Contains(formatted, line) {
+					t.Errorf("histórico formatado sem %q:\n%s", line, formatted)
+				}
+			}
+		})
+	}
+}
+
+func TestGroupPromptThroughFakeProvider(t *testing.T) {
+	history := []ChatMessage{
+		{MessageType: "user", MessageText: "Ana: bom dia", Timestamp: time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)},
+		{MessageType: "assistant", MessageText: "Bom dia, Ana", Timestamp: time.Date(2026, 10, 18, 9, 1, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		name            string
+		customPrompt    string
+		message         string
+		wantInstruction string
+		wantNoEmojis    bool
+	}{
+		{
+			name:            "prompt padrão proíbe emojis",
+			message:         "qual a boa de hoje?",
+			wantInstruction: "Você é o DuckerIA",
+			wantNoEmojis:    true,
+		},
+		{
+			name:            "prompt personalizado libera emojis",
+			customPrompt:    "Você é um pirata animado.",
+			message:         "qual a boa de hoje?",
+			wantInstruction: "Você é um pirata animado.",
+		},
+		{
+			name:            "usuário pedindo sem emojis não muda a instrução",
+			customPrompt:    "Você é um pirata animado.",
+			message:         "responde sem emojis por favor",
+			wantInstruction: "Você é um pirata animado.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gmp := &GroupMessageProcessor{}
+			rules := &GroupRules{CustomPrompt: tt.customPrompt}
+			prompt := gmp.createGroupPrompt(rules, history, tt.message, "João")
+
+			fake := NewFakeProvider("Resposta do grupo")
+			ctx := withInstruction(context.Background(), gmp.groupSystemPrompt(rules))
+			result, err := fake.Generate(ctx, prompt, nil)
+			if err != nil {
+				t.Fatalf("Generate() erro inesperado: %v", err)
+			}
+			if result.Text != "Resposta do grupo" || result.Model != "fake" {
+				t.Errorf("resultado = %q (%s), esperava a resposta do provedor falso", result.Text, result.Model)
+			}
+
+			prompts := fake.Prompts()
+			if len(prompts) != 1 {
+				t.Fatalf("prompts recebidos = %d, esperava 1", len(prompts))
+			}
+			for _, want := range []string{tt.wantInstruction, "[09:00] Usuário: Ana: bom dia", "[09:01] DuckerIA: Bom dia, Ana", "**João:** " + tt.message} {
+				if !strings.Contains(prompts[0], want) {
+					t.Errorf("prompt sem %q:\n%s", want, prompts[0])
+				}
+			}
+			if got := emojisForbidden(ctx); got != tt.wantNoEmojis {
+				t.Errorf("emojisForbidden() = %v, esperava %v", got, tt.wantNoEmojis)
+			}
+		})
+	}
+}
+
+func TestNewLLMProvider(t *testing.T) {
+	t.Setenv("GEMINI_API_KEY", "")
+
+	tests := []struct {
+		name     string
+		provider string
+		wantName string // Vazio quando a IA fica desabilitada
+		wantErr  bool
+	}{
+		{name: "fake", provider: "fake", wantName: "fake"},
+		{name: "nome em maiúsculas", provider: "FAKE", wantName: "fake"},
+		{name: "openai com a URL padrão", provider: "openai", wantName: "openai"},
+		{name: "gemini sem API key desabilita a IA", provider: "gemini"},
+		{name: "padrão é o gemini", provider: ""},
+		{name: "provedor desconhecido", provider: "claude", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := newLLMProvider(tt.provider)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("newLLMProvider(%q) esperava erro", tt.provider)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newLLMProvider(%q) erro inesperado: %v", tt.provider, err)
+			}
+			if tt.wantName == "" {
+				if provider != nil {
+					t.Errorf("newLLMProvider(%q) = %s, esperava IA desabilitada", tt.provider, provider.Name())
+				}
+				return
+			}
+			if provider == nil || provider.Name() != tt.wantName {
+				t.Fatalf("newLLMProvider(%q) = %v, esperava o provedor %s", tt.provider, provider, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestResilientProviderWithFakeProvider(t *testing.T) {
+	resilient, err := newResilientProvider(NewFakeProvider(), "fake", "fake, reserva-1, ,reserva-2", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resilient.FallbackModels(); !slices.Equal(got, []string{"reserva-1", "reserva-2"}) {
+		t.Errorf("FallbackModels() = %v, esperava [reserva-1 reserva-2]", got)
+	}
+
+	tests := []struct {
+		name          string
+		primaryErr    error
+		wantErr       bool
+		wantText      string
+		wantModel     string
+		wantFallbacks int // Chamadas esperadas no modelo de fallback
+	}{
+		{name: "principal responde", wantText: "principal", wantModel: "fake"},
+		{name: "modelo indisponível usa o fallback", primaryErr: &OpenAIStatusError{StatusCode: http.StatusNotFound}, wantText: "reserva", wantModel: "reserva", wantFallbacks: 1},
+		{name: "bloqueio não tenta o fallback", primaryErr: ErrLLMBlocked, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := NewFakeProvider("principal")
+			primary.SetError(tt.primaryErr)
+			fallback := NewFakeProvider("reserva")
+			fallback.SetModel("reserva")
+
+			provider := NewResilientProvider(primary, []LLMProvider{fallback}, 1)
+			result, err := provider.Generate(context.Background(), "conte uma piada", nil)
+			if tt.wantErr {
+				if !errors.Is(err, tt.primaryErr) {
+					t.Errorf("Generate() erro = %v, esperava %v", err, tt.primaryErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Generate() erro inesperado: %v", err)
+				}
+				if result.Text != tt.wantText || result.Model != tt.wantModel {
+					t.Errorf("Generate() = %q (%s), esperava %q (%s)", result.Text, result.Model, tt.wantText, tt.wantModel)
+				}
+			}
+			if got := len(fallback.Prompts()); got != tt.wantFallbacks {
+				t.Errorf("chamadas no fallback = %d, esperava %d", got, tt.wantFallbacks)
+			}
+		})
+	}
+}
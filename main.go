@@ -1,5 +1,5 @@
-// BotIA - Bot WhatsApp com integração Gemini AI
-// Este bot conecta ao WhatsApp via whatsmeow e processa mensagens privadas usando a API do Google Gemini
+// BotIA - Bot WhatsApp com integração de IA (Gemini, APIs compatíveis com OpenAI)
+// Este bot conecta ao WhatsApp via whatsmeow e processa mensagens privadas usando IA (por padrão, o Google Gemini)
 package main
 
 import (
@@ -34,27 +34,79 @@ var (
 	// logType define o formato de saída do log (console ou json)
 	logType = flag.String("logtype", "console", "Type of log output (console or json)")
 
+	// llmProviderName define o provedor de IA (gemini, openai ou fake)
+	llmProviderName = flag.String("llm", "gemini", "Provedor de IA: gemini, openai (API compatível, ex: Ollama/llama.cpp) ou fake (respostas de teste)")
+
 	// geminiAPIKey é a chave da API do Gemini (pode ser fornecida via flag ou variável de ambiente)
 	geminiAPIKey = flag.String("geminikey", "", "Gemini API Key (opcional, pode usar GEMINI_API_KEY env var)")
 
 	// geminiModel define qual modelo do Gemini será usado
 	geminiModel = flag.String("geminimodel", "gemini-2.5-flash", "Modelo Gemini a usar")
 
+	// geminiImageModel define qual modelo do Gemini será usado para gerar imagens
+	geminiImageModel = flag.String("geminiimagemodel", "gemini-2.5-flash-image", "Modelo Gemini para geração de imagens")
+
+	// geminiEmbeddingModel define qual modelo do Gemini gera os embeddings da base de conhecimento
+	geminiEmbeddingModel = flag.String("geminiembeddingmodel", "gemini-embedding-001", "Modelo Gemini para os embeddings da base de conhecimento (FAQ)")
+
+	// openAIBaseURL é a URL base da API compatível com OpenAI
+	openAIBaseURL = flag.String("openaiurl", "http://localhost:11434/v1", "URL base da API compatível com OpenAI (padrão: Ollama local)")
+
+	// openAIAPIKey é a chave da API compatível com OpenAI (opcional para servidores locais)
+	openAIAPIKey = flag.String("openaikey", "", "API Key da API compatível com OpenAI (opcional, pode usar OPENAI_API_KEY env var)")
+
+	// openAIModel define o modelo usado na API compatível com OpenAI
+	openAIModel = flag.String("openaimodel", "llama3.2", "Modelo a usar na API compatível com OpenAI")
+
+	// fallbackModels define os modelos tentados, em ordem, quando o modelo principal falha
+	fallbackModels = flag.String("fallbackmodels", "", "Modelos de fallback separados por vírgula (ex: gemini-1.5-flash,gemini-1.5-flash-8b)")
+
+	// llmRetries define quantas tentativas são feitas em cada modelo antes do fallback
+	llmRetries = flag.Int("llmretries", 3, "Tentativas por modelo em erros transitórios (429, 5xx, timeout)")
+
+	// breakerThreshold define quantas falhas seguidas da IA abrem o circuit breaker
+	breakerThreshold = flag.Int("breakerthreshold", 5, "Falhas seguidas da IA para suspender as chamadas (circuit breaker)")
+
+	// breakerCooldown define por quanto tempo as chamadas à IA ficam suspensas
+	breakerCooldown = flag.Duration("breakercooldown", time.Minute, "Tempo com as chamadas à IA suspensas antes de testar de novo")
+
+	// groupQuota define a cota mensal padrão de tokens de IA de cada grupo
+	groupQuota = flag.Int64("groupquota", 0, "Cota mensal padrão de tokens de IA por grupo (0 = sem limite; ajustável por grupo com !uso cota)")
+
+	// cacheTTL define por quanto tempo as respostas dos comandos determinísticos ficam no cache
+	cacheTTL = flag.Duration("cachettl", 24*time.Hour, "Validade das respostas da IA guardadas em cache (!explique, !resumo); 0 desliga o cache")
+
+	// historyRetention define por quanto tempo o histórico das conversas fica no banco
+	historyRetention = flag.Duration("historyretention", 30*24*time.Hour, "Tempo que as mensagens ficam no histórico (contexto da IA e !resumo); 0 guarda para sempre")
+
+	// faqDir define a pasta com os documentos da base de conhecimento (FAQ)
+	faqDir = flag.String("faqdir", "faq", "Pasta com os documentos da base de conhecimento (.md ou .txt) usados nas conversas privadas")
+
+	// faqTopK define quantos trechos da base de conhecimento entram no prompt
+	faqTopK = flag.Int("faqtopk", 3, "Trechos da base de conhecimento incluídos no prompt de cada mensagem privada (0 desliga a base)")
+
+	// botAdmins são os números com acesso aos comandos de administração (!persona, !modelo...)
+	botAdmins = flag.String("admins", "", "Números dos administradores do bot separados por vírgula (ex: 5598999999999)")
+
 	// tenorAPIKey é a chave da API do Tenor para GIFs
 	tenorAPIKey = flag.String("tenorkey", "", "Tenor API Key para comandos de GIF (opcional)")
 
+	// tenorURL é o endereço base da API do Tenor (permite usar um servidor local que simule a API)
+	tenorURL = flag.String("tenorurl", tenorDefaultBaseURL, "URL base da API v2 do Tenor")
+
+	// maxMessageChars define o tamanho máximo (em caracteres) de cada mensagem enviada
+	maxMessageChars = flag.Int("maxchars", 1500, "Tamanho máximo de cada mensagem; respostas maiores são divididas em partes")
+
+	// maxMessageParts define quantas partes de uma resposta são enviadas antes de exigir !mais
+	maxMessageParts = flag.Int("maxparts", 3, "Partes enviadas por resposta antes de exigir !mais")
+
 	// log é o logger zerolog configurado
 	log zerolog.Logger
-
-	// geminiClient é a instância do cliente Gemini (nil se não configurado)
-	geminiClient *GeminiClient
 )
 
-// init inicializa o logger baseado nas flags de configuração
-func init() {
-	// Parse das flags de linha de comando
-	flag.Parse()
-
+// setupLogger inicializa o logger baseado nas flags de configuração
+// Chamado no início do main (e não em init) para que os testes possam registrar as próprias flags
+func setupLogger() {
 	// Configurar logger baseado no tipo escolhido
 	if *logType == "json" {
 		// Formato JSON para logs estruturados
@@ -86,9 +138,16 @@ type ChatContext struct {
 type BotClient struct {
 	WAClient       *whatsmeow.Client      // Cliente WhatsApp principal
 	eventHandlerID uint32                 // ID do handler de eventos registrado
-	geminiClient   *GeminiClient          // Cliente Gemini para processar mensagens (pode ser nil)
+	llm            LLMProvider            // Provedor de IA para processar mensagens (pode ser nil)
 	chatContext    *ChatContext           // Gerenciador de contexto de conversa
 	groupProcessor *GroupMessageProcessor // Processador de mensagens de grupo
+	mediaService   *MediaService          // Serviço de envio de mídias com cache de uploads
+	chunker        *MessageChunker        // Divisor de respostas longas em várias mensagens
+	tools          *ToolRegistry          // Ferramentas que a IA pode chamar (data/hora, lembretes, comandos...)
+	aiNotices      *aiNoticeLimiter       // Controle dos avisos de IA indisponível por chat
+	usage          *UsageTracker          // Registro de tokens consumidos e cotas mensais dos grupos
+	cache          *ResponseCache         // Cache das respostas dos comandos determinísticos (!explique...)
+	knowledge      *KnowledgeBase         // Base de conhecimento (FAQ) usada nas conversas privadas
 }
 
 // NewChatContext cria uma nova instância do gerenciador de contexto
@@ -150,6 +209,16 @@ func (c *ChatContext) initTable() error {
 		return fmt.Errorf("erro ao criar tabela jokes_history: %w", err)
 	}
 
+	// Migrar tabelas antigas: piadas passam a ser separadas por conversa e categoria
+	err = c.ensureColumn("jokes_history", "chat_jid", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	err = c.ensureColumn("jokes_history", "category", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+
 	// Criar índice para piadas
 	createJokesIndexQuery := `
 		CREATE INDEX IF NOT EXISTS idx_jokes_timestamp
@@ -161,22 +230,168 @@ func (c *ChatContext) initTable() error {
 		return fmt.Errorf("erro ao criar índice jokes_history: %w", err)
 	}
 
+	createJokesChatIndexQuery := `
+		CREATE INDEX IF NOT EXISTS idx_jokes_chat_timestamp
+		ON jokes_history (chat_jid, timestamp);
+	`
+
+	_, err = c.db.Exec(createJokesChatIndexQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar índice jokes_history: %w", err)
+	}
+
+	// Criar tabela que liga as mensagens enviadas às piadas (para avaliação por reação)
+	createJokeMessagesTableQuery := `
+		CREATE TABLE IF NOT EXISTS joke_messages (
+			message_id TEXT PRIMARY KEY,
+			joke_id INTEGER NOT NULL
+		);
+	`
+
+	_, err = c.db.Exec(createJokeMessagesTableQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar tabela joke_messages: %w", err)
+	}
+
+	// Criar tabela de avaliações de piadas (uma avaliação por pessoa)
+	createJokeRatingsTableQuery := `
+		CREATE TABLE IF NOT EXISTS joke_ratings (
+			joke_id INTEGER NOT NULL,
+			rater_jid TEXT NOT NULL,
+			rating INTEGER NOT NULL,
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (joke_id, rater_jid)
+		);
+	`
+
+	_, err = c.db.Exec(createJokeRatingsTableQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar tabela joke_ratings: %w", err)
+	}
+
+	// Criar tabela de histórico de cantadas
+	createCantadasTableQuery := `
+		CREATE TABLE IF NOT EXISTS cantadas_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_jid TEXT NOT NULL DEFAULT '',
+			target_jid TEXT NOT NULL DEFAULT '',
+			theme TEXT NOT NULL DEFAULT '',
+			cantada_text TEXT NOT NULL,
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+	`
+
+	_, err = c.db.Exec(createCantadasTableQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar tabela cantadas_history: %w", err)
+	}
+
+	// Criar índice para cantadas
+	createCantadasIndexQuery := `
+		CREATE INDEX IF NOT EXISTS idx_cantadas_chat_timestamp
+		ON cantadas_history (chat_jid, timestamp);
+	`
+
+	_, err = c.db.Exec(createCantadasIndexQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar índice cantadas_history: %w", err)
+	}
+
+	// Criar tabelas das histórias colaborativas
+	err = c.initStoryTables()
+	if err != nil {
+		return err
+	}
+
+	// Criar tabela dos lembretes agendados
+	err = c.initReminderTables()
+	if err != nil {
+		return err
+	}
+
+	// Criar tabela dos modelos de IA escolhidos por chat
+	err = c.initModelTables()
+	if err != nil {
+		return err
+	}
+
+	// Criar tabelas do uso de tokens da IA e das cotas dos grupos
+	err = c.initUsageTables()
+	if err != nil {
+		return err
+	}
+
+	// Criar tabela do cache de respostas da IA
+	err = c.initCacheTables()
+	if err != nil {
+		return err
+	}
+
+	// Criar tabelas da base de conhecimento (FAQ)
+	err = c.initKnowledgeTables()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
-// SaveMessage salva uma mensagem no histórico
-func (c *ChatContext) SaveMessage(ctx context.Context, userJID, messageType, messageText string) error {
+// ensureColumn adiciona uma coluna a uma tabela existente caso ela ainda não exista
+// Usado para migrar bancos criados por versões anteriores do bot
+func (c *ChatContext) ensureColumn(table, column, definition string) error {
+	rows, err := c.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("erro ao consultar colunas de %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &primaryKey)
+		if err != nil {
+			return fmt.Errorf("erro ao ler colunas de %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	_, err = c.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("erro ao adicionar coluna %s em %s: %w", column, table, err)
+	}
+
+	log.Info().Str("table", table).Str("column", column).Msg("Coluna adicionada ao banco de dados")
+
+	return nil
+}
+
+// SaveMessage salva uma mensagem no histórico e retorna o ID dela
+func (c *ChatContext) SaveMessage(ctx context.Context, userJID, messageType, messageText string) (int64, error) {
 	query := `
 		INSERT INTO chat_history (user_jid, message_type, message_text, timestamp)
 		VALUES (?, ?, ?, ?)
 	`
 
-	_, err := c.db.ExecContext(ctx, query, userJID, messageType, messageText, time.Now())
+	result, err := c.db.ExecContext(ctx, query, userJID, messageType, messageText, time.Now())
 	if err != nil {
-		return fmt.Errorf("erro ao salvar mensagem: %w", err)
+		return 0, fmt.Errorf("erro ao salvar mensagem: %w", err)
 	}
 
-	return nil
+	messageID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("erro ao obter ID da mensagem: %w", err)
+	}
+
+	return messageID, nil
 }
 
 // LoadMessages recupera as últimas mensagens de um usuário
@@ -253,19 +468,58 @@ func (c *ChatContext) LoadGroupMessages(ctx context.Context, groupJID string, ma
 	return messages, nil
 }
 
+// LoadGroupMessagesBefore recupera as últimas mensagens de um grupo gravadas antes da mensagem beforeID
+// beforeID 0 (mensagem não gravada) carrega as mais recentes
+func (c *ChatContext) LoadGroupMessagesBefore(ctx context.Context, groupJID string, beforeID int64, maxMessages int) ([]ChatMessage, error) {
+	if beforeID <= 0 {
+		return c.LoadGroupMessages(ctx, groupJID, maxMessages)
+	}
+
+	query := `
+		SELECT id, user_jid, message_type, message_text, timestamp
+		FROM chat_history
+		WHERE user_jid = ? AND id < ?
+		ORDER BY id DESC
+		LIMIT ?
+	`
+
+	rows, err := c.db.QueryContext(ctx, query, groupJID, beforeID, maxMessages)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar mensagens do grupo: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []ChatMessage
+	for rows.Next() {
+		var msg ChatMessage
+		err := rows.Scan(&msg.ID, &msg.UserJID, &msg.MessageType, &msg.MessageText, &msg.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler mensagem do grupo: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	// Inverter para ordem cronológica (mais antiga primeiro)
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	return messages, nil
+}
+
 // GetContextSize retorna o número de mensagens no contexto
 func (c *ChatContext) GetContextSize() int {
 	return c.maxMessages
 }
 
-// CleanOldMessages remove mensagens antigas (mais de 30 dias) para manter o banco limpo
-func (c *ChatContext) CleanOldMessages(ctx context.Context) error {
+// CleanOldMessages remove as mensagens mais antigas que retention para manter o banco limpo
+func (c *ChatContext) CleanOldMessages(ctx context.Context, retention time.Duration) error {
 	query := `
 		DELETE FROM chat_history
-		WHERE timestamp < datetime('now', '-30 days')
+		WHERE timestamp < ?
 	`
 
-	result, err := c.db.ExecContext(ctx, query)
+	result, err := c.db.ExecContext(ctx, query, time.Now().Add(-retention))
 	if err != nil {
 		return fmt.Errorf("erro ao limpar mensagens antigas: %w", err)
 	}
@@ -278,31 +532,37 @@ func (c *ChatContext) CleanOldMessages(ctx context.Context) error {
 	return nil
 }
 
-// SaveJoke salva uma piada no histórico
-func (c *ChatContext) SaveJoke(ctx context.Context, jokeText string) error {
+// SaveJoke salva uma piada no histórico da conversa e retorna o ID da piada
+func (c *ChatContext) SaveJoke(ctx context.Context, chatJID, category, jokeText string) (int64, error) {
 	query := `
-		INSERT INTO jokes_history (joke_text, timestamp)
-		VALUES (?, ?)
+		INSERT INTO jokes_history (chat_jid, category, joke_text, timestamp)
+		VALUES (?, ?, ?, ?)
 	`
 
-	_, err := c.db.ExecContext(ctx, query, jokeText, time.Now())
+	result, err := c.db.ExecContext(ctx, query, chatJID, category, jokeText, time.Now())
 	if err != nil {
-		return fmt.Errorf("erro ao salvar piada: %w", err)
+		return 0, fmt.Errorf("erro ao salvar piada: %w", err)
 	}
 
-	return nil
+	jokeID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("erro ao obter ID da piada: %w", err)
+	}
+
+	return jokeID, nil
 }
 
-// LoadJokesHistory carrega as últimas piadas do histórico
-func (c *ChatContext) LoadJokesHistory(ctx context.Context, maxJokes int) ([]string, error) {
+// LoadJokesHistory carrega as últimas piadas contadas em uma conversa
+func (c *ChatContext) LoadJokesHistory(ctx context.Context, chatJID string, maxJokes int) ([]string, error) {
 	query := `
 		SELECT joke_text
 		FROM jokes_history
+		WHERE chat_jid = ?
 		ORDER BY timestamp DESC
 		LIMIT ?
 	`
 
-	rows, err := c.db.QueryContext(ctx, query, maxJokes)
+	rows, err := c.db.QueryContext(ctx, query, chatJID, maxJokes)
 	if err != nil {
 		return nil, fmt.Errorf("erro ao consultar histórico de piadas: %w", err)
 	}
@@ -326,6 +586,104 @@ func (c *ChatContext) LoadJokesHistory(ctx context.Context, maxJokes int) ([]str
 	return jokes, nil
 }
 
+// LinkJokeMessage associa uma mensagem enviada pelo bot a uma piada
+func (c *ChatContext) LinkJokeMessage(ctx context.Context, messageID string, jokeID int64) error {
+	query := `
+		INSERT OR REPLACE INTO joke_messages (message_id, joke_id)
+		VALUES (?, ?)
+	`
+
+	_, err := c.db.ExecContext(ctx, query, messageID, jokeID)
+	if err != nil {
+		return fmt.Errorf("erro ao associar mensagem à piada: %w", err)
+	}
+
+	return nil
+}
+
+// FindJokeByMessage retorna o ID da piada enviada em uma mensagem (0 se não for uma piada)
+func (c *ChatContext) FindJokeByMessage(ctx context.Context, messageID string) (int64, error) {
+	query := `
+		SELECT joke_id
+		FROM joke_messages
+		WHERE message_id = ?
+	`
+
+	var jokeID int64
+	err := c.db.QueryRowContext(ctx, query, messageID).Scan(&jokeID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("erro ao consultar mensagem da piada: %w", err)
+	}
+
+	return jokeID, nil
+}
+
+// RateJoke registra a avaliação de uma pessoa para uma piada (rating 0 remove a avaliação)
+func (c *ChatContext) RateJoke(ctx context.Context, jokeID int64, raterJID string, rating int) error {
+	if rating == 0 {
+		_, err := c.db.ExecContext(ctx, `DELETE FROM joke_ratings WHERE joke_id = ? AND rater_jid = ?`, jokeID, raterJID)
+		if err != nil {
+			return fmt.Errorf("erro ao remover avaliação da piada: %w", err)
+		}
+		return nil
+	}
+
+	query := `
+		INSERT OR REPLACE INTO joke_ratings (joke_id, rater_jid, rating, timestamp)
+		VALUES (?, ?, ?, ?)
+	`
+
+	_, err := c.db.ExecContext(ctx, query, jokeID, raterJID, rating, time.Now())
+	if err != nil {
+		return fmt.Errorf("erro ao salvar avaliação da piada: %w", err)
+	}
+
+	return nil
+}
+
+// RatedJoke representa uma piada com a soma das avaliações recebidas
+type RatedJoke struct {
+	ID       int64
+	Category string
+	Text     string
+	Score    int
+}
+
+// LoadTopJokes carrega as piadas mais bem avaliadas de uma conversa (apenas com saldo positivo)
+func (c *ChatContext) LoadTopJokes(ctx context.Context, chatJID string, limit int) ([]RatedJoke, error) {
+	query := `
+		SELECT j.id, j.category, j.joke_text, SUM(r.rating) AS score
+		FROM jokes_history j
+		JOIN joke_ratings r ON r.joke_id = j.id
+		WHERE j.chat_jid = ?
+		GROUP BY j.id
+		HAVING score > 0
+		ORDER BY score DESC, j.timestamp DESC
+		LIMIT ?
+	`
+
+	rows, err := c.db.QueryContext(ctx, query, chatJID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar piadas mais bem avaliadas: %w", err)
+	}
+	defer rows.Close()
+
+	var jokes []RatedJoke
+	for rows.Next() {
+		var joke RatedJoke
+		err := rows.Scan(&joke.ID, &joke.Category, &joke.Text, &joke.Score)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler piada avaliada: %w", err)
+		}
+		jokes = append(jokes, joke)
+	}
+
+	return jokes, nil
+}
+
 // FormatJokesHistory formata o histórico de piadas para o prompt
 func FormatJokesHistory(jokes []string) string {
 	if len(jokes) == 0 {
@@ -344,6 +702,73 @@ func FormatJokesHistory(jokes []string) string {
 	return history.String()
 }
 
+// SaveCantada salva uma cantada no histórico da conversa
+func (c *ChatContext) SaveCantada(ctx context.Context, chatJID, targetJID, theme, cantadaText string) error {
+	query := `
+		INSERT INTO cantadas_history (chat_jid, target_jid, theme, cantada_text, timestamp)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	_, err := c.db.ExecContext(ctx, query, chatJID, targetJID, theme, cantadaText, time.Now())
+	if err != nil {
+		return fmt.Errorf("erro ao salvar cantada: %w", err)
+	}
+
+	return nil
+}
+
+// LoadCantadasHistory carrega as últimas cantadas enviadas em uma conversa
+func (c *ChatContext) LoadCantadasHistory(ctx context.Context, chatJID string, maxCantadas int) ([]string, error) {
+	query := `
+		SELECT cantada_text
+		FROM cantadas_history
+		WHERE chat_jid = ?
+		ORDER BY timestamp DESC
+		LIMIT ?
+	`
+
+	rows, err := c.db.QueryContext(ctx, query, chatJID, maxCantadas)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar histórico de cantadas: %w", err)
+	}
+	defer rows.Close()
+
+	var cantadas []string
+	for rows.Next() {
+		var cantada string
+		err := rows.Scan(&cantada)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler cantada: %w", err)
+		}
+		cantadas = append(cantadas, cantada)
+	}
+
+	// Inverter para ordem cronológica (mais antiga primeiro)
+	for i, j := 0, len(cantadas)-1; i < j; i, j = i+1, j-1 {
+		cantadas[i], cantadas[j] = cantadas[j], cantadas[i]
+	}
+
+	return cantadas, nil
+}
+
+// FormatCantadasHistory formata o histórico de cantadas para o prompt
+func FormatCantadasHistory(cantadas []string) string {
+	if len(cantadas) == 0 {
+		return ""
+	}
+
+	var history strings.Builder
+	history.WriteString("\n\nIMPORTANTE: As seguintes cantadas já foram usadas anteriormente. NÃO repita nenhuma delas:\n\n")
+
+	for i, cantada := range cantadas {
+		history.WriteString(fmt.Sprintf("%d. %s\n", i+1, cantada))
+	}
+
+	history.WriteString("\nGere uma cantada NOVA e DIFERENTE das listadas acima.")
+
+	return history.String()
+}
+
 // FormatConversationHistory formata o histórico de conversa para o prompt da IA
 func FormatConversationHistory(messages []ChatMessage) string {
 	if len(messages) == 0 {
@@ -398,6 +823,12 @@ func (bot *BotClient) eventHandler(rawEvt interface{}) {
 			return
 		}
 
+		// Reações às piadas do bot funcionam como avaliação (usadas pelo !piada top)
+		if reaction := evt.Message.GetReactionMessage(); reaction != nil {
+			bot.handleJokeReaction(context.Background(), evt, reaction)
+			return
+		}
+
 		// Tentar diferentes métodos para extrair o texto da mensagem
 		msgText := evt.Message.GetConversation()
 
@@ -524,7 +955,7 @@ func (bot *BotClient) eventHandler(rawEvt interface{}) {
 			log.Error().Err(errRead).Msg("Erro ao marcar mensagem como lida")
 		}
 
-		// Processar mensagem privada com Gemini AI
+		// Processar mensagem privada com a IA
 		go bot.processPrivateMessage(context.Background(), evt, msgText)
 
 	case *events.Receipt:
@@ -566,7 +997,7 @@ func (bot *BotClient) eventHandler(rawEvt interface{}) {
 	}
 }
 
-// processPrivateMessage processa mensagens privadas usando a API do Gemini
+// processPrivateMessage processa mensagens privadas usando o provedor de IA
 // Esta função é executada em uma goroutine separada para não bloquear outros eventos
 //
 // Parâmetros:
@@ -574,12 +1005,30 @@ func (bot *BotClient) eventHandler(rawEvt interface{}) {
 //   - evt: Evento da mensagem recebida
 //   - msgText: Texto da mensagem a ser processada
 func (bot *BotClient) processPrivateMessage(ctx context.Context, evt *events.Message, msgText string) {
-	// Verificar se o cliente Gemini está configurado
-	if bot.geminiClient == nil {
-		log.Warn().Msg("Gemini client não configurado, ignorando mensagem")
+	// !mais envia o restante da última resposta longa
+	if strings.EqualFold(strings.TrimSpace(msgText), "!mais") {
+		err := bot.handleMaisCommand(ctx, evt.Info.Sender, evt.Info.Sender)
+		if err != nil {
+			log.Error().Err(err).Msg("Erro ao enviar restante da resposta")
+		}
+		return
+	}
+
+	// Comandos de lembrete e de administração também funcionam no privado
+	if command, args, ok := parsePrivateCommand(msgText); ok {
+		err := bot.groupProcessor.commandHandler.ProcessCommand(ctx, command, args, evt, bot)
+		if err != nil {
+			log.Error().Err(err).Str("command", command).Msg("Erro ao processar comando no privado")
+		}
+		return
+	}
+
+	// Verificar se o provedor de IA está configurado
+	if bot.llm == nil {
+		log.Warn().Msg("Provedor de IA não configurado, ignorando mensagem")
 
-		// Informar ao usuário que o Gemini não está configurado
-		errorMsg := "⚠️ Gemini não configurado. Configure a API key para usar esta funcionalidade."
+		// Informar ao usuário que a IA não está configurada
+		errorMsg := "⚠️ IA não configurada. Configure um provedor (ex: API key do Gemini) para usar esta funcionalidade."
 		msg := &waProto.Message{
 			Conversation: &errorMsg,
 		}
@@ -592,7 +1041,7 @@ func (bot *BotClient) processPrivateMessage(ctx context.Context, evt *events.Mes
 
 	// Enviar feedback imediato ao usuário que a mensagem está sendo processada
 
-	log.Info().Str("message", msgText).Str("from", evt.Info.Sender.String()).Msg("Processando mensagem com Gemini")
+	log.Info().Str("message", msgText).Str("from", evt.Info.Sender.String()).Msg("Processando mensagem com IA")
 
 	// Enviar evento de "digitando"
 	errTyping := bot.WAClient.SendChatPresence(context.Background(), evt.Info.Sender, types.ChatPresenceComposing, types.ChatPresenceMediaText)
@@ -609,106 +1058,18 @@ func (bot *BotClient) processPrivateMessage(ctx context.Context, evt *events.Mes
 	}
 
 	// Salvar mensagem do usuário no histórico
-	err = bot.chatContext.SaveMessage(ctx, evt.Info.Sender.String(), "user", msgText)
+	_, err = bot.chatContext.SaveMessage(ctx, evt.Info.Sender.String(), "user", msgText)
 	if err != nil {
 		log.Error().Err(err).Str("jid", evt.Info.Sender.String()).Msg("Erro ao salvar mensagem do usuário")
 	}
 
-	// Criar prompt personalizado para o Gemini
-	systemPrompt := `
-Você é o DuckerIA, um assistente virtual da Hyper Ducker, empresa de tecnologia especializada em desenvolvimento de aplicativos web no Maranhão.
-
-## Sua Identidade e Propósito
-
-- Você se chama DuckerIA e representa a Hyper Ducker
-- Você é um agent de conversação, não um agent de vendas
-- Seu objetivo é conversar de forma descontraída com os clientes maranhenses
-- Você responde perguntas de forma automática e amigável
-
-## Informações da Empresa
-
-**Nome:** Hyper Ducker
-**Ramo:** Tecnologia - Desenvolvimento de aplicativos web
-**Público:** Jovens
-**Tipos de aplicativo:** Todos os tipos (e-commerce, sistemas internos, plataformas, etc.)
-**Horário de funcionamento:** 07h às 19h
-**Tempo de desenvolvimento:** Varia conforme o projeto
-
-**IMPORTANTE:** A empresa atualmente não está vendendo serviços. Você apenas conversa e tira dúvidas.
-
-## Tom e Estilo de Comunicação
-
-- **Amigável e profissional** com um toque descontraído
-- **Prestativo e direto** - responda de forma objetiva sem enrolação
-- **Respostas simples e claras** - vá direto ao ponto sem repetir informações desnecessárias
-- **Apresente-se apenas na primeira interação** - nas demais, seja natural e conversacional
-- **Levemente informal, mas respeitoso** - use "você" predominantemente
-- **Use expressões maranhenses com moderação:** visse, rapaz/moça (ocasionalmente), tranquilo, beleza (use de forma sutil e natural)
-- **NÃO use emojis em nenhuma circunstância**
-- **Seja profissional mas acessível** - equilibre cordialidade com objetividade
-
-## Restrições Importantes
-
-Você NÃO deve:
-- Fornecer dados sensíveis de clientes
-- Fazer promessas de desconto ou preços
-- Realizar alterações de pedidos
-- Transferir para atendimento humano (não há essa opção)
-- Usar emojis
-
-## Como Lidar com Situações Específicas
-
-**Quando não souber uma informação:**
-Seja honesto e direto. Exemplo: "Não tenho essa informação. Posso ajudar com algo mais?"
-
-**Quando perguntarem sobre contratação/vendas:**
-Informe de forma simples que no momento não estão comercializando, mas você pode esclarecer dúvidas sobre aplicativos web.
-
-**Engajamento:**
-Mantenha perguntas simples e diretas para continuar a conversa quando apropriado, sem forçar.
-
-## Despedida
-
-Quando a conversa terminar naturalmente, despeça-se com:
-**"Team Hyper Ducker, agradecemos seu contato."**
-
-Pode adicionar uma frase antes dessa se quiser ser mais caloroso, mas sempre finalize com essa frase.
-
-## Exemplos de Interação
-
-**Primeira interação:**
-**Cliente:** "Olá"
-**DuckerIA:** "Olá, tudo bem? Sou o DuckerIA da Hyper Ducker. Como posso ajudar?"
-
-**Cumprimentos simples:**
-**Cliente:** "Bom dia"
-**DuckerIA:** "Bom dia! Tudo bem? Como posso ajudar?"
-
-**Cliente:** "Oi"
-**DuckerIA:** "Oi! Como posso ajudar?"
-
-**Perguntas diretas:**
-**Cliente:** "Vocês fazem aplicativo?"
-**DuckerIA:** "Sim, fazemos aplicativos web de todos os tipos. Você tem algum projeto em mente?"
-
-**Cliente:** "Quanto custa?"
-**DuckerIA:** "No momento não estamos comercializando, mas posso tirar dúvidas sobre desenvolvimento. O que você gostaria de saber?"
-
-**Cliente:** "Vocês têm Instagram?"
-**DuckerIA:** "Ainda não temos redes sociais. Posso ajudar com algo mais?"
-
-**Cliente:** "Quanto tempo demora?"
-**DuckerIA:** "O tempo varia conforme a complexidade do projeto. Depende das funcionalidades que você precisa."
-
-## Lembre-se
-
-- Apresente-se apenas no primeiro contato da conversa
-- Seja direto e objetivo nas respostas
-- Não repita informações que já foram dadas
-- Responda cumprimentos de forma simples e natural
-- Use o toque maranhense de forma sutil
-- Mantenha sempre o respeito e a simpatia
-- Ajude o cliente de forma clara e sem enrolação`
+	// Prompt base da persona (prompt.go ou prompt.txt) e os trechos da base de conhecimento
+	// relevantes para a mensagem (horários, serviços, redes sociais...)
+	// O embedding da pergunta entra no uso da conversa, como a resposta
+	systemPrompt := getSystemPrompt()
+	if knowledge := bot.knowledge.PromptContext(bot.usageContext(ctx, evt, "privado"), knowledgeQuery(history, msgText)); knowledge != "" {
+		systemPrompt += "\n\n" + knowledge
+	}
 
 	// Formatar histórico da conversa
 	conversationHistory := FormatConversationHistory(history)
@@ -717,44 +1078,28 @@ Pode adicionar uma frase antes dessa se quiser ser mais caloroso, mas sempre fin
 	fullPrompt := fmt.Sprintf("%s\n\n%s\n\nMensagem atual do usuário: %s",
 		systemPrompt, conversationHistory, msgText)
 
-	// Gerar resposta usando a API do Gemini
-	response, err := bot.geminiClient.GenerateContent(ctx, fullPrompt)
+	// Gerar resposta em streaming: a mensagem aparece logo e é atualizada conforme a IA escreve
+	// A IA pode chamar ferramentas (ex: criar um lembrete) antes de responder
+	stream := streamWithTools(withInstruction(bot.aiContext(ctx, evt, personaAtendimento, "privado"), systemPrompt), bot.llm, fullPrompt, bot.tools, &ToolContext{Bot: bot, Event: evt})
+	response, err := bot.chunker.SendStream(ctx, evt.Info.Sender, evt.Info.Sender, "🤖 ", stream)
 	if err != nil {
-		log.Error().Err(err).Msg("Erro ao gerar resposta com Gemini")
-
-		// Informar erro ao usuário
-		errorMsg := "❌ Erro ao processar sua solicitação. Tente novamente mais tarde."
-		msg := &waProto.Message{
-			Conversation: &errorMsg,
+		// Informar erro ao usuário (ou o aviso de IA indisponível)
+		err = bot.handleAIError(ctx, evt.Info.Sender, err, "Erro ao gerar resposta com IA", "❌ Erro ao processar sua solicitação. Tente novamente mais tarde.")
+		if err != nil {
+			log.Error().Err(err).Msg("Erro ao enviar mensagem de erro")
 		}
-		bot.WAClient.SendMessage(ctx, evt.Info.Sender, msg)
 		return
 	}
 
-	if len(response) > 4000 {
-		response = response[:4000] + "\n\n... (resposta truncada)"
-	}
+	log.Info().
+		Int("contextSize", len(history)).
+		Int("responseLength", len(response)).
+		Msg("Resposta da IA enviada ao usuário")
 
-	// Salvar resposta da IA no histórico antes de enviar
-	err = bot.chatContext.SaveMessage(ctx, evt.Info.Sender.String(), "assistant", response)
+	// Salvar resposta da IA no histórico
+	_, err = bot.chatContext.SaveMessage(ctx, evt.Info.Sender.String(), "assistant", response)
 	if err != nil {
 		log.Error().Err(err).Str("jid", evt.Info.Sender.String()).Msg("Erro ao salvar resposta da IA")
-		// Continuar mesmo com erro de salvamento
-	}
-
-	// Enviar resposta gerada pelo Gemini ao usuário
-	responseMsg := fmt.Sprintf("🤖 %s", response)
-	msg := &waProto.Message{
-		Conversation: &responseMsg,
-	}
-	_, err = bot.WAClient.SendMessage(ctx, evt.Info.Sender, msg)
-	if err != nil {
-		log.Error().Err(err).Msg("Erro ao enviar resposta")
-	} else {
-		log.Info().
-			Int("contextSize", len(history)).
-			Int("responseLength", len(response)).
-			Msg("Resposta do Gemini enviada ao usuário")
 	}
 
 	// Enviar evento de "pausado"
@@ -767,9 +1112,9 @@ Pode adicionar uma frase antes dessa se quiser ser mais caloroso, mas sempre fin
 
 // handleExpliqueCommand processa o comando !explique para explicar mensagens citadas
 func (bot *BotClient) handleExpliqueCommand(ctx context.Context, evt *events.Message, quotedMessageText string) {
-	// Verificar se o cliente Gemini está configurado
-	if bot.geminiClient == nil {
-		errorMsg := "❌ Gemini não está configurado. Configure a API key para usar este comando."
+	// Verificar se o provedor de IA está configurado
+	if bot.llm == nil {
+		errorMsg := "❌ IA não está configurada. Configure um provedor (ex: API key do Gemini) para usar este comando."
 		msg := &waProto.Message{
 			Conversation: &errorMsg,
 		}
@@ -787,7 +1132,7 @@ func (bot *BotClient) handleExpliqueCommand(ctx context.Context, evt *events.Mes
 	}
 
 	// Criar prompt para explicar a mensagem
-	prompt := fmt.Sprintf(`Você é um assistente que explica mensagens de forma simples e clara.
+	instruction := `Você é um assistente que explica mensagens de forma simples e clara.
 
 Sua tarefa é explicar o que a seguinte mensagem quis dizer, de forma:
 - Simples e direta
@@ -800,47 +1145,35 @@ Sua tarefa é explicar o que a seguinte mensagem quis dizer, de forma:
 Mensagem a ser explicada:
 "%s"
 
-Explique de forma simples o que essa mensagem quis dizer:`, quotedMessageText)
+Explique de forma simples o que essa mensagem quis dizer:`
+	prompt := fmt.Sprintf(instruction, quotedMessageText)
 
 	log.Info().
 		Str("quoted", quotedMessageText).
 		Str("from", evt.Info.Sender.String()).
 		Msg("Processando comando !explique")
 
-	// Gerar explicação usando a API do Gemini
-	explicacao, err := bot.geminiClient.GenerateContent(ctx, prompt)
+	// Gerar explicação usando o provedor de IA
+	// A mesma mensagem encaminhada é explicada em vários grupos: a resposta sai do cache quando possível
+	explicacao, err := bot.cache.Generate(withInstruction(bot.aiContext(ctx, evt, personaExplique, "explique"), instruction), bot.llm, "explique", prompt)
 	if err != nil {
-		log.Error().Err(err).Msg("Erro ao gerar explicação com Gemini")
-
 		// Encerrar status de digitando
 		bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
 
-		// Informar erro ao usuário
-		errorMsg := "❌ Erro ao gerar explicação. Tente novamente mais tarde."
-		msg := &waProto.Message{
-			Conversation: &errorMsg,
-		}
-		_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+		// Informar erro ao usuário (ou o aviso de IA indisponível)
+		err := bot.handleAIError(ctx, evt.Info.Chat, err, "Erro ao gerar explicação com IA", "❌ Erro ao gerar explicação. Tente novamente mais tarde.")
 		if err != nil {
 			log.Error().Err(err).Msg("Erro ao enviar mensagem de erro")
 		}
 		return
 	}
 
-	// Limitar tamanho da explicação
-	if len(explicacao) > 1000 {
-		explicacao = explicacao[:1000] + "..."
-	}
-
 	// Encerrar status de digitando
 	bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
 
 	// Enviar explicação gerada
 	explicacaoMsg := fmt.Sprintf("💡 *Explicação:*\n\n%s", explicacao)
-	msg := &waProto.Message{
-		Conversation: &explicacaoMsg,
-	}
-	_, err = bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+	_, err = bot.chunker.Send(ctx, evt.Info.Chat, evt.Info.Sender, explicacaoMsg, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("Erro ao enviar explicação")
 	} else {
@@ -854,33 +1187,56 @@ Explique de forma simples o que essa mensagem quis dizer:`, quotedMessageText)
 // main é a função principal do programa
 // Inicializa todos os componentes e mantém o bot rodando
 func main() {
+	// Parse das flags de linha de comando
+	flag.Parse()
+	setupLogger()
+
 	log.Info().Str("loglevel", *logLevel).Str("logtype", *logType).Msg("Iniciando BotIA")
 
-	// Inicializar cliente Gemini se API key fornecida
-	// A API key pode vir de flag (-geminikey) ou variável de ambiente (GEMINI_API_KEY)
-	if *geminiAPIKey != "" || os.Getenv("GEMINI_API_KEY") != "" {
-		var err error
-		geminiClient, err = NewGeminiClient(*geminiAPIKey)
+	// Inicializar o provedor de IA escolhido (-llm); nil desabilita as funções de IA
+	llm, err := newLLMProvider(*llmProviderName)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Erro ao inicializar provedor de IA")
+	}
+	if llm != nil {
+		// Envolver com novas tentativas (backoff) e a cadeia de modelos de fallback
+		resilient, err := newResilientProvider(llm, *llmProviderName, *fallbackModels, *llmRetries)
 		if err != nil {
-			log.Fatal().Err(err).Msg("Erro ao inicializar cliente Gemini")
+			log.Fatal().Err(err).Msg("Erro ao inicializar modelos de fallback")
 		}
 
-		// Configurar modelo do Gemini se especificado via flag
-		if *geminiModel != "" {
-			geminiClient.SetModel(*geminiModel)
+		// Conferir os modelos das flags na lista de modelos disponíveis da API
+		err = validateStartupModels(context.Background(), llm, resilient.FallbackModels())
+		if err != nil {
+			log.Fatal().Err(err).Msg("Modelo de IA inválido")
+		}
+		// Circuit breaker por fora: cada falha contada já passou pelas novas tentativas e fallbacks
+		llm = NewBreakerProvider(resilient, NewCircuitBreaker(*breakerThreshold, *breakerCooldown))
+		// Cota por fora de tudo: chamadas recusadas por cota não contam como falha no circuit breaker
+		llm = NewQuotaProvider(llm)
+
+		logEvent := log.Info().
+			Str("provider", llm.Name()).
+			Str("model", llm.GetModel()).
+			Strs("fallbackModels", resilient.FallbackModels()).
+			Int("retries", *llmRetries)
+		if images, ok := imageGeneratorOf(llm); ok {
+			logEvent = logEvent.Str("imageModel", images.GetImageModel())
 		}
+		logEvent.Msg("Provedor de IA inicializado")
+	}
 
-		log.Info().
-			Str("model", geminiClient.GetModel()).
-			Msg("Cliente Gemini inicializado")
-	} else {
-		log.Warn().Msg("Gemini API key não fornecida. Funcionalidade do Gemini desabilitada.")
+	// Filtros de segurança e parâmetros por persona (personas.json é opcional)
+	// Carregado mesmo sem IA: o !persona salva no arquivo e não pode sobrescrever o que já estava nele
+	err = personas.LoadFile(personasFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Erro ao carregar ajustes das personas")
 	}
 
 	// Criar diretório para banco de dados SQLite
 	// O banco armazena a sessão do WhatsApp para reconexão automática
 	dbDirectory := "auth"
-	_, err := os.Stat(dbDirectory)
+	_, err = os.Stat(dbDirectory)
 	if os.IsNotExist(err) {
 		// Criar diretório se não existir
 		errDir := os.MkdirAll(dbDirectory, 0751)
@@ -921,22 +1277,31 @@ func main() {
 	// Inicializar processador de mensagens de grupo
 	groupProcessor := NewGroupMessageProcessor(nil) // Será definido após criar o bot
 
-	// Limpar mensagens antigas (opcional, roda em background)
-	// go func() {
-	// 	ticker := time.NewTicker(24 * time.Hour) // A cada 24 horas
-	// 	defer ticker.Stop()
-
-	// 	for {
-	// 		select {
-	// 		case <-ticker.C:
-	// 			ctx := context.Background()
-	// 			err := chatContext.CleanOldMessages(ctx)
-	// 			if err != nil {
-	// 				log.Warn().Err(err).Msg("Erro ao limpar mensagens antigas")
-	// 			}
-	// 		}
-	// 	}
-	// }()
+	// Usar o Tenor para GIFs das ações se a API key foi fornecida
+	if *tenorAPIKey != "" {
+		groupProcessor.commandHandler.EnableTenor(*tenorAPIKey, *tenorURL)
+		log.Info().Msg("Provedor de GIFs do Tenor habilitado (pastas locais como fallback)")
+	}
+
+	// Converter GIFs/WebPs das ações para MP4 em background
+	go groupProcessor.commandHandler.PrepareGIFs()
+
+	// Limpar mensagens antigas do histórico em background: na partida e a cada 24 horas
+	// Grupos gravam toda mensagem (contexto da IA e !resumo), então sem limpeza a tabela só cresce
+	if *historyRetention > 0 {
+		go func() {
+			ticker := time.NewTicker(24 * time.Hour)
+			defer ticker.Stop()
+
+			for {
+				err := chatContext.CleanOldMessages(context.Background(), *historyRetention)
+				if err != nil {
+					log.Warn().Err(err).Msg("Erro ao limpar mensagens antigas")
+				}
+				<-ticker.C
+			}
+		}()
+	}
 
 	// Obter ou criar dispositivo WhatsApp
 	// O dispositivo representa a sessão do WhatsApp
@@ -971,14 +1336,31 @@ func main() {
 	// Criar instância do bot com suas dependências
 	bot := &BotClient{
 		WAClient:       client,
-		geminiClient:   geminiClient,
+		llm:            llm,
 		chatContext:    chatContext,
 		groupProcessor: groupProcessor,
+		mediaService:   NewMediaService(client),
+		chunker:        NewMessageChunker(client, *maxMessageChars, *maxMessageParts, time.Second),
+		tools:          NewDefaultToolRegistry(),
+		aiNotices:      &aiNoticeLimiter{},
+		usage:          NewUsageTracker(chatContext, *groupQuota),
+		cache:          NewResponseCache(chatContext, *cacheTTL),
+		knowledge:      NewKnowledgeBase(chatContext, *faqDir, *faqTopK, llm),
 	}
 
 	// Configurar referência do bot no processador de grupos
 	bot.groupProcessor.bot = bot
 
+	// Indexar a base de conhecimento em background (os embeddings do Gemini usam a rede)
+	if bot.knowledge.Enabled() {
+		go func() {
+			_, err := bot.knowledge.Sync(context.Background())
+			if err != nil {
+				log.Error().Err(err).Msg("Erro ao sincronizar base de conhecimento")
+			}
+		}()
+	}
+
 	// Registrar handler de eventos
 	// Todos os eventos do WhatsApp serão processados por eventHandler
 	bot.eventHandlerID = client.AddEventHandler(bot.eventHandler)
@@ -1036,6 +1418,10 @@ func main() {
 		}
 	}
 
+	// Iniciar o agendador de lembretes (os lembretes ficam no SQLite e sobrevivem a reinícios)
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	go bot.runReminderScheduler(schedulerCtx)
+
 	log.Info().Msg("BotIA está rodando! Pressione Ctrl+C para sair.")
 
 	// Aguardar sinal de interrupção (Ctrl+C ou SIGTERM)
@@ -1046,6 +1432,7 @@ func main() {
 
 	// Desconectar graciosamente ao receber sinal de interrupção
 	log.Info().Msg("Desconectando...")
+	stopScheduler()
 	client.Disconnect()
 	log.Info().Msg("BotIA finalizado")
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadGroupMessagesBefore(t *testing.T) {
+	chatContext := newTestChatContext(t)
+	ctx := context.Background()
+
+	var ids []int64
+	for _, text := range []string{"Ana: um", "João: dois", "Ana: três", "João: quatro"} {
+		id, err := chatContext.SaveMessage(ctx, "grupo-a", "user", text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := chatContext.SaveMessage(ctx, "grupo-b", "user", "Maria: outro grupo"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		beforeID int64
+		max      int
+		want     []string
+	}{
+		{name: "só as anteriores à mensagem atual", beforeID: ids[2], max: 10, want: []string{"Ana: um", "João: dois"}},
+		{name: "mensagens posteriores não entram", beforeID: ids[1], max: 10, want: []string{"Ana: um"}},
+		{name: "limite fica com as mais recentes", beforeID: ids[3], max: 2, want: []string{"João: dois", "Ana: três"}},
+		{name: "mensagem não gravada carrega as mais recentes", beforeID: 0, max: 2, want: []string{"Ana: três", "João: quatro"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, err := chatContext.LoadGroupMessagesBefore(ctx, "grupo-a", tt.beforeID, tt.max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, msg := range messages {
+				got = append(got, msg.MessageText)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("LoadGroupMessagesBefore() = %v, esperava %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanOldMessages(t *testing.T) {
+	chatContext := newTestChatContext(t)
+	ctx := context.Background()
+
+	_, err := chatContext.db.Exec(`INSERT INTO chat_history (user_jid, message_type, message_text, timestamp) VALUES (?, ?, ?, ?)`,
+		"grupo-a", "user", "Ana: mensagem antiga", time.Now().Add(-40*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chatContext.SaveMessage(ctx, "grupo-a", "user", "João: mensagem nova"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chatContext.CleanOldMessages(ctx, 30*24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := chatContext.LoadGroupMessages(ctx, "grupo-a", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].MessageText != "João: mensagem nova" {
+		t.Errorf("mensagens depois da limpeza = %+v, esperava só a nova", messages)
+	}
+}
+
+func TestLoadCantadasHistoryPerChat(t *testing.T) {
+	chatContext := newTestChatContext(t)
+	ctx := context.Background()
+
+	saved := []struct{ chat, text string }{
+		{"grupo-a", "cantada 1"},
+		{"grupo-b", "cantada do outro grupo"},
+		{"grupo-a", "cantada 2"},
+		{"grupo-a", "cantada 3"},
+	}
+	for _, cantada := range saved {
+		if err := chatContext.SaveCantada(ctx, cantada.chat, "alvo@s.whatsapp.net", "", cantada.text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		chat string
+		max  int
+		want []string
+	}{
+		{name: "só as cantadas da conversa", chat: "grupo-a", max: 10, want: []string{"cantada 1", "cantada 2", "cantada 3"}},
+		{name: "limite fica com as mais recentes", chat: "grupo-a", max: 2, want: []string{"cantada 2", "cantada 3"}},
+		{name: "outra conversa", chat: "grupo-b", max: 10, want: []string{"cantada do outro grupo"}},
+		{name: "conversa sem cantadas", chat: "privado", max: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := chatContext.LoadCantadasHistory(ctx, tt.chat, tt.max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("LoadCantadasHistory(%q) = %v, esperava %v", tt.chat, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,541 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// thumbnailMaxSize é o maior lado (em pixels) das miniaturas JPEG enviadas junto com as mídias
+const thumbnailMaxSize = 96
+
+// mediaUploadTTL é por quanto tempo um upload fica no cache; depois disso o arquivo é enviado de novo
+// (o servidor do WhatsApp não guarda as mídias para sempre, e o cache não cresce sem limite)
+const mediaUploadTTL = 7 * 24 * time.Hour
+
+// MediaService centraliza o envio de mídias (GIFs, imagens e documentos) para o WhatsApp
+// Mantém um cache de uploads indexado pelo SHA256 do arquivo para evitar reenvios (válido por mediaUploadTTL)
+type MediaService struct {
+	client *whatsmeow.Client
+
+	mu      sync.Mutex
+	files   map[string]*mediaFileInfo // Caminho do arquivo -> informações do arquivo em disco
+	uploads map[string]*mediaUpload   // SHA256 do arquivo -> resultado do upload
+}
+
+// mediaFileInfo guarda o SHA256 de um arquivo para evitar reler arquivos não modificados
+type mediaFileInfo struct {
+	modTime time.Time
+	size    int64
+	sha256  string
+}
+
+// mediaUpload guarda o resultado de um upload e os metadados da mídia
+type mediaUpload struct {
+	URL           string
+	DirectPath    string
+	MediaKey      []byte
+	FileEncSHA256 []byte
+	FileSHA256    []byte
+	FileLength    uint64
+	Width         uint32
+	Height        uint32
+	Seconds       uint32
+	Thumbnail     []byte
+	UploadedAt    time.Time
+}
+
+// NewMediaService cria um novo serviço de envio de mídias
+func NewMediaService(client *whatsmeow.Client) *MediaService {
+	return &MediaService{
+		client:  client,
+		files:   make(map[string]*mediaFileInfo),
+		uploads: make(map[string]*mediaUpload),
+	}
+}
+
+// SendGIF envia um arquivo MP4 local como GIF (VideoMessage com GifPlayback)
+// A legenda e o ContextInfo (menções e mensagem citada) vão dentro da própria mensagem de vídeo
+func (ms *MediaService) SendGIF(ctx context.Context, chat types.JID, path, caption string, contextInfo *waProto.ContextInfo) error {
+	upload, err := ms.uploadFile(ctx, path)
+	if err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("Erro ao preparar GIF, enviando apenas texto")
+		return ms.sendText(ctx, chat, fmt.Sprintf("%s\n\n[GIF indisponível]", caption), contextInfo)
+	}
+
+	msg := &waProto.Message{
+		VideoMessage: ms.buildVideoMessage(upload, caption, contextInfo),
+	}
+
+	filename := filepath.Base(path)
+	_, err = ms.client.SendMessage(ctx, chat, msg)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("gif", filename).
+			Str("directPath", upload.DirectPath).
+			Strs("mentioned", contextInfo.GetMentionedJID()).
+			Msg("Erro ao enviar GIF, enviando apenas texto")
+
+		// Um upload antigo pode ter expirado no servidor; descartar para forçar novo upload
+		ms.forgetUpload(upload)
+		return ms.sendText(ctx, chat, caption, contextInfo)
+	}
+
+	log.Info().
+		Str("gif", filename).
+		Uint32("width", upload.Width).
+		Uint32("height", upload.Height).
+		Uint32("seconds", upload.Seconds).
+		Strs("mentioned", contextInfo.GetMentionedJID()).
+		Msg("GIF enviado com sucesso")
+
+	return nil
+}
+
+// SendImage envia uma imagem em memória como ImageMessage com legenda
+func (ms *MediaService) SendImage(ctx context.Context, chat types.JID, data []byte, mimeType, caption string) error {
+	uploadResp, err := ms.client.Upload(ctx, data, whatsmeow.MediaImage)
+	if err != nil {
+		return fmt.Errorf("erro ao fazer upload da imagem: %w", err)
+	}
+
+	imageMsg := &waProto.ImageMessage{
+		URL:           proto.String(uploadResp.URL),
+		DirectPath:    proto.String(uploadResp.DirectPath),
+		Mimetype:      proto.String(mimeType),
+		FileLength:    proto.Uint64(uploadResp.FileLength),
+		MediaKey:      uploadResp.MediaKey,
+		FileEncSHA256: uploadResp.FileEncSHA256,
+		FileSHA256:    uploadResp.FileSHA256,
+	}
+	if caption != "" {
+		imageMsg.Caption = proto.String(caption)
+	}
+
+	// Informar dimensões e miniatura quando for possível decodificar a imagem
+	if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+		bounds := img.Bounds()
+		imageMsg.Width = proto.Uint32(uint32(bounds.Dx()))
+		imageMsg.Height = proto.Uint32(uint32(bounds.Dy()))
+		if thumbnail, err := encodeThumbnail(img); err == nil {
+			imageMsg.JPEGThumbnail = thumbnail
+		}
+	}
+
+	_, err = ms.client.SendMessage(ctx, chat, &waProto.Message{ImageMessage: imageMsg})
+	if err != nil {
+		return fmt.Errorf("erro ao enviar imagem: %w", err)
+	}
+
+	return nil
+}
+
+// SendDocument envia um arquivo gerado em memória como documento (ex: história exportada em .txt)
+func (ms *MediaService) SendDocument(ctx context.Context, chat types.JID, data []byte, mimeType, fileName, caption string) error {
+	uploadResp, err := ms.client.Upload(ctx, data, whatsmeow.MediaDocument)
+	if err != nil {
+		return fmt.Errorf("erro ao fazer upload do documento: %w", err)
+	}
+
+	documentMsg := &waProto.DocumentMessage{
+		URL:           proto.String(uploadResp.URL),
+		DirectPath:    proto.String(uploadResp.DirectPath),
+		Mimetype:      proto.String(mimeType),
+		Title:         proto.String(strings.TrimSuffix(fileName, filepath.Ext(fileName))),
+		FileName:      proto.String(fileName),
+		FileLength:    proto.Uint64(uploadResp.FileLength),
+		MediaKey:      uploadResp.MediaKey,
+		FileEncSHA256: uploadResp.FileEncSHA256,
+		FileSHA256:    uploadResp.FileSHA256,
+	}
+	if caption != "" {
+		documentMsg.Caption = proto.String(caption)
+	}
+
+	_, err = ms.client.SendMessage(ctx, chat, &waProto.Message{DocumentMessage: documentMsg})
+	if err != nil {
+		return fmt.Errorf("erro ao enviar documento: %w", err)
+	}
+
+	return nil
+}
+
+// buildVideoMessage monta a VideoMessage de GIF a partir de um upload em cache
+func (ms *MediaService) buildVideoMessage(upload *mediaUpload, caption string, contextInfo *waProto.ContextInfo) *waProto.VideoMessage {
+	videoMsg := &waProto.VideoMessage{
+		URL:           proto.String(upload.URL),
+		DirectPath:    proto.String(upload.DirectPath),
+		Mimetype:      proto.String("video/mp4"),
+		FileLength:    proto.Uint64(upload.FileLength),
+		MediaKey:      upload.MediaKey,
+		FileEncSHA256: upload.FileEncSHA256,
+		FileSHA256:    upload.FileSHA256,
+		GifPlayback:   proto.Bool(true),
+	}
+
+	if upload.Width > 0 && upload.Height > 0 {
+		videoMsg.Width = proto.Uint32(upload.Width)
+		videoMsg.Height = proto.Uint32(upload.Height)
+	}
+	if upload.Seconds > 0 {
+		videoMsg.Seconds = proto.Uint32(upload.Seconds)
+	}
+	if len(upload.Thumbnail) > 0 {
+		videoMsg.JPEGThumbnail = upload.Thumbnail
+	}
+	if caption != "" {
+		videoMsg.Caption = proto.String(caption)
+	}
+	if contextInfo != nil {
+		videoMsg.ContextInfo = contextInfo
+	}
+
+	return videoMsg
+}
+
+// sendText envia uma mensagem de texto simples ou com ContextInfo (fallback quando a mídia falha)
+func (ms *MediaService) sendText(ctx context.Context, chat types.JID, text string, contextInfo *waProto.ContextInfo) error {
+	msg := &waProto.Message{
+		Conversation: &text,
+	}
+	if contextInfo != nil {
+		msg = &waProto.Message{
+			ExtendedTextMessage: &waProto.ExtendedTextMessage{
+				Text:        &text,
+				ContextInfo: contextInfo,
+			},
+		}
+	}
+
+	_, err := ms.client.SendMessage(ctx, chat, msg)
+	return err
+}
+
+// uploadFile retorna o upload em cache de um arquivo ou faz o upload se necessário
+func (ms *MediaService) uploadFile(ctx context.Context, path string) (*mediaUpload, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao acessar arquivo: %w", err)
+	}
+
+	// Arquivo não modificado desde a última leitura: reaproveitar o upload sem ler o disco
+	ms.mu.Lock()
+	if info, ok := ms.files[path]; ok && info.modTime.Equal(stat.ModTime()) && info.size == stat.Size() {
+		if upload, ok := ms.cachedUpload(info.sha256); ok {
+			ms.mu.Unlock()
+			log.Debug().Str("path", path).Str("sha256", info.sha256).Msg("Upload de mídia reaproveitado do cache")
+			return upload, nil
+		}
+	}
+	ms.mu.Unlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler arquivo: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	ms.mu.Lock()
+	ms.files[path] = &mediaFileInfo{modTime: stat.ModTime(), size: stat.Size(), sha256: hash}
+	upload, ok := ms.cachedUpload(hash)
+	ms.mu.Unlock()
+	if ok {
+		// Mesmo conteúdo já enviado a partir de outro caminho
+		return upload, nil
+	}
+
+	log.Info().
+		Str("file", filepath.Base(path)).
+		Int("size", len(data)).
+		Msg("Iniciando upload de mídia")
+
+	uploadResp, err := ms.client.Upload(ctx, data, whatsmeow.MediaVideo)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer upload: %w", err)
+	}
+
+	upload = &mediaUpload{
+		URL:           uploadResp.URL,
+		DirectPath:    uploadResp.DirectPath,
+		MediaKey:      uploadResp.MediaKey,
+		FileEncSHA256: uploadResp.FileEncSHA256,
+		FileSHA256:    uploadResp.FileSHA256,
+		FileLength:    uploadResp.FileLength,
+		UploadedAt:    time.Now(),
+	}
+
+	// Metadados reais do vídeo (dimensões e duração)
+	if probe, err := probeMP4(data); err == nil {
+		upload.Width = probe.Width
+		upload.Height = probe.Height
+		upload.Seconds = probe.Seconds
+	} else {
+		log.Warn().Err(err).Str("path", path).Msg("Não foi possível ler metadados do MP4")
+	}
+
+	// Miniatura JPEG do primeiro quadro
+	if thumbnail, err := videoThumbnail(path); err == nil {
+		upload.Thumbnail = thumbnail
+	} else {
+		log.Debug().Err(err).Str("path", path).Msg("Miniatura do vídeo indisponível")
+	}
+
+	ms.mu.Lock()
+	ms.uploads[hash] = upload
+	ms.mu.Unlock()
+
+	log.Info().
+		Str("file", filepath.Base(path)).
+		Str("sha256", hash).
+		Uint64("fileLength", upload.FileLength).
+		Msg("Upload de mídia concluído e armazenado em cache")
+
+	return upload, nil
+}
+
+// cachedUpload retorna o upload em cache do conteúdo, descartando os que passaram de mediaUploadTTL
+// Chamado com o lock adquirido
+func (ms *MediaService) cachedUpload(hash string) (*mediaUpload, bool) {
+	upload, ok := ms.uploads[hash]
+	if !ok {
+		return nil, false
+	}
+	if time.Since(upload.UploadedAt) > mediaUploadTTL {
+		delete(ms.uploads, hash)
+		return nil, false
+	}
+	return upload, true
+}
+
+// forgetUpload remove um upload do cache (usado quando o envio com ele falha)
+func (ms *MediaService) forgetUpload(upload *mediaUpload) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for hash, cached := range ms.uploads {
+		if cached == upload {
+			delete(ms.uploads, hash)
+		}
+	}
+}
+
+// mp4Info contém os metadados extraídos de um arquivo MP4
+type mp4Info struct {
+	Width   uint32
+	Height  uint32
+	Seconds uint32
+}
+
+// probeMP4 lê as caixas moov/mvhd/tkhd de um MP4 para obter dimensões e duração
+func probeMP4(data []byte) (*mp4Info, error) {
+	moov := findMP4Box(data, "moov")
+	if moov == nil {
+		return nil, fmt.Errorf("caixa moov não encontrada")
+	}
+
+	info := &mp4Info{}
+
+	// mvhd: timescale e duração do filme
+	if mvhd := findMP4Box(moov, "mvhd"); len(mvhd) >= 4 {
+		version := mvhd[0]
+		var timescale, duration uint64
+		if version == 1 && len(mvhd) >= 32 {
+			timescale = uint64(binary.BigEndian.Uint32(mvhd[20:24]))
+			duration = binary.BigEndian.Uint64(mvhd[24:32])
+		} else if len(mvhd) >= 20 {
+			timescale = uint64(binary.BigEndian.Uint32(mvhd[12:16]))
+			duration = uint64(binary.BigEndian.Uint32(mvhd[16:20]))
+		}
+		if timescale > 0 {
+			// Arredondar para cima para GIFs curtos não aparecerem com 0 segundos
+			info.Seconds = uint32((duration + timescale - 1) / timescale)
+		}
+	}
+
+	// tkhd: largura e altura (ponto fixo 16.16) da primeira trilha de vídeo
+	for offset := 0; offset < len(moov); {
+		boxType, payload, size := readMP4Box(moov[offset:])
+		if size == 0 {
+			break
+		}
+		offset += size
+		if boxType != "trak" {
+			continue
+		}
+
+		tkhd := findMP4Box(payload, "tkhd")
+		if len(tkhd) < 4 {
+			continue
+		}
+		dimOffset := 76 // versão 0
+		if tkhd[0] == 1 {
+			dimOffset = 88
+		}
+		if len(tkhd) < dimOffset+8 {
+			continue
+		}
+		width := binary.BigEndian.Uint32(tkhd[dimOffset:dimOffset+4]) >> 16
+		height := binary.BigEndian.Uint32(tkhd[dimOffset+4:dimOffset+8]) >> 16
+		if width > 0 && height > 0 {
+			info.Width = width
+			info.Height = height
+			break
+		}
+	}
+
+	if info.Width == 0 || info.Height == 0 {
+		return info, fmt.Errorf("dimensões do vídeo não encontradas")
+	}
+
+	return info, nil
+}
+
+// findMP4Box procura uma caixa de nível imediato pelo tipo e retorna seu conteúdo
+func findMP4Box(data []byte, wanted string) []byte {
+	for offset := 0; offset < len(data); {
+		boxType, payload, size := readMP4Box(data[offset:])
+		if size == 0 {
+			return nil
+		}
+		if boxType == wanted {
+			return payload
+		}
+		offset += size
+	}
+	return nil
+}
+
+// readMP4Box lê o cabeçalho de uma caixa MP4 e retorna tipo, conteúdo e tamanho total
+// Retorna tamanho 0 quando a caixa é inválida
+func readMP4Box(data []byte) (string, []byte, int) {
+	if len(data) < 8 {
+		return "", nil, 0
+	}
+
+	size := uint64(binary.BigEndian.Uint32(data[0:4]))
+	boxType := string(data[4:8])
+	header := uint64(8)
+
+	switch size {
+	case 0:
+		// Caixa vai até o fim do arquivo
+		size = uint64(len(data))
+	case 1:
+		// Tamanho estendido de 64 bits
+		if len(data) < 16 {
+			return "", nil, 0
+		}
+		size = binary.BigEndian.Uint64(data[8:16])
+		header = 16
+	}
+
+	if size < header || size > uint64(len(data)) {
+		return "", nil, 0
+	}
+
+	return boxType, data[header:size], int(size)
+}
+
+// ffmpegThumbnailTimeout limita a extração da miniatura; ao estourar, usa o fallback do GIF original
+const ffmpegThumbnailTimeout = 15 * time.Second
+
+// videoThumbnail gera uma miniatura JPEG do primeiro quadro de um vídeo
+// Usa o ffmpeg quando disponível; caso contrário, tenta um .gif com o mesmo nome ao lado do vídeo
+func videoThumbnail(path string) ([]byte, error) {
+	if ffmpegPath, err := exec.LookPath("ffmpeg"); err == nil {
+		thumbCtx, cancel := context.WithTimeout(context.Background(), ffmpegThumbnailTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(thumbCtx, ffmpegPath,
+			"-loglevel", "error",
+			"-i", path,
+			"-vframes", "1",
+			"-f", "image2pipe",
+			"-vcodec", "png",
+			"-")
+		output, err := cmd.Output()
+		if err == nil {
+			if img, _, err := image.Decode(bytes.NewReader(output)); err == nil {
+				return encodeThumbnail(img)
+			}
+		}
+	}
+
+	// Fallback: primeiro quadro do GIF original, se existir
+	gifPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".gif"
+	file, err := os.Open(gifPath)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg indisponível e GIF original não encontrado")
+	}
+	defer file.Close()
+
+	img, err := gif.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao decodificar GIF original: %w", err)
+	}
+
+	return encodeThumbnail(img)
+}
+
+// encodeThumbnail reduz a imagem para o tamanho de miniatura e codifica em JPEG
+func encodeThumbnail(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("imagem vazia")
+	}
+
+	// Manter proporção, limitando o maior lado
+	thumbWidth, thumbHeight := width, height
+	if width > height && width > thumbnailMaxSize {
+		thumbWidth = thumbnailMaxSize
+		thumbHeight = height * thumbnailMaxSize / width
+	} else if height >= width && height > thumbnailMaxSize {
+		thumbHeight = thumbnailMaxSize
+		thumbWidth = width * thumbnailMaxSize / height
+	}
+	if thumbWidth < 1 {
+		thumbWidth = 1
+	}
+	if thumbHeight < 1 {
+		thumbHeight = 1
+	}
+
+	// Redimensionamento simples por vizinho mais próximo (suficiente para miniaturas)
+	thumb := image.NewRGBA(image.Rect(0, 0, thumbWidth, thumbHeight))
+	for y := 0; y < thumbHeight; y++ {
+		for x := 0; x < thumbWidth; x++ {
+			srcX := bounds.Min.X + x*width/thumbWidth
+			srcY := bounds.Min.Y + y*height/thumbHeight
+			thumb.Set(x, y, color.RGBAModel.Convert(img.At(srcX, srcY)))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 75}); err != nil {
+		return nil, fmt.Errorf("erro ao codificar miniatura: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
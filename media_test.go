@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+)
+
+// mp4Box monta uma caixa MP4 com cabeçalho de 32 bits
+func mp4Box(boxType string, payload ...[]byte) []byte {
+	size := 8
+	for _, part := range payload {
+		size += len(part)
+	}
+	box := binary.BigEndian.AppendUint32(nil, uint32(size))
+	box = append(box, boxType...)
+	for _, part := range payload {
+		box = append(box, part...)
+	}
+	return box
+}
+
+// testMP4 monta um MP4 mínimo (ftyp + moov com mvhd e uma trilha tkhd) versão 0
+func testMP4(width, height, timescale, duration uint32) []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:16], timescale)
+	binary.BigEndian.PutUint32(mvhd[16:20], duration)
+
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[76:80], width<<16)
+	binary.BigEndian.PutUint32(tkhd[80:84], height<<16)
+
+	return append(mp4Box("ftyp", []byte("isom")),
+		mp4Box("moov", mp4Box("mvhd", mvhd), mp4Box("trak", mp4Box("tkhd", tkhd)))...)
+}
+
+func TestReadMP4Box(t *testing.T) {
+	extended := binary.BigEndian.AppendUint32(nil, 1)
+	extended = append(extended, "free"...)
+	extended = binary.BigEndian.AppendUint64(extended, 20)
+	extended = append(extended, "abcd"...)
+
+	toEnd := append(binary.BigEndian.AppendUint32(nil, 0), "mdat"...)
+	toEnd = append(toEnd, "conteúdo"...)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		wantType    string
+		wantPayload string
+		wantSize    int // 0 quando a caixa é inválida
+	}{
+		{name: "caixa válida", data: mp4Box("free", []byte("abc")), wantType: "free", wantPayload: "abc", wantSize: 11},
+		{name: "lê só a primeira caixa", data: append(mp4Box("free", []byte("ab")), mp4Box("skip")...), wantType: "free", wantPayload: "ab", wantSize: 10},
+		{name: "caixa vazia", data: mp4Box("skip"), wantType: "skip", wantSize: 8},
+		{name: "tamanho 0 vai até o fim", data: toEnd, wantType: "mdat", wantPayload: "conteúdo", wantSize: len(toEnd)},
+		{name: "tamanho estendido", data: extended, wantType: "free", wantPayload: "abcd", wantSize: 20},
+		{name: "sem dados", data: nil},
+		{name: "cabeçalho truncado", data: []byte{0, 0, 0, 16, 'f', 'r'}},
+		{name: "tamanho estendido truncado", data: extended[:12]},
+		{name: "tamanho maior que os dados", data: mp4Box("free", []byte("abc"))[:10]},
+		{name: "tamanho estendido maior que os dados", data: extended[:18]},
+		{name: "tamanho menor que o cabeçalho", data: append(binary.BigEndian.AppendUint32(nil, 4), "free"...)},
+		{name: "tamanho estendido menor que o cabeçalho", data: append(binary.BigEndian.AppendUint32(nil, 1), "free\x00\x00\x00\x00\x00\x00\x00\x08"...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boxType, payload, size := readMP4Box(tt.data)
+			if size != tt.wantSize || boxType != tt.wantType || string(payload) != tt.wantPayload {
+				t.Errorf("readMP4Box() = %q, %q, %d; esperava %q, %q, %d", boxType, payload, size, tt.wantType, tt.wantPayload, tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestProbeMP4(t *testing.T) {
+	valid := testMP4(320, 240, 1000, 2500)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    mp4Info
+		wantErr bool
+	}{
+		{name: "mp4 válido", data: valid, want: mp4Info{Width: 320, Height: 240, Seconds: 3}},
+		{name: "duração exata", data: testMP4(640, 360, 600, 1200), want: mp4Info{Width: 640, Height: 360, Seconds: 2}},
+		{name: "sem dados", data: nil, wantErr: true},
+		{name: "sem moov", data: mp4Box("ftyp", []byte("isom")), wantErr: true},
+		{name: "moov truncado", data: valid[:len(valid)-10], wantErr: true},
+		{name: "tkhd curto demais", data: mp4Box("moov", mp4Box("trak", mp4Box("tkhd", make([]byte, 40)))), wantErr: true},
+		{name: "moov com tamanho maior que o arquivo", data: append(binary.BigEndian.AppendUint32(nil, 1<<20), "moov"...), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := probeMP4(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("probeMP4() = %+v, esperava erro", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("probeMP4() erro inesperado: %v", err)
+			}
+			if *info != tt.want {
+				t.Errorf("probeMP4() = %+v, esperava %+v", *info, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeMP4Fixture(t *testing.T) {
+	data, err := os.ReadFile("static/gif/hug/hug-anime.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := probeMP4(data)
+	if err != nil {
+		t.Fatalf("probeMP4() erro inesperado: %v", err)
+	}
+	if info.Width == 0 || info.Height == 0 || info.Seconds == 0 {
+		t.Errorf("probeMP4() = %+v, esperava dimensões e duração", *info)
+	}
+}
+
+func TestMediaServiceCachedUpload(t *testing.T) {
+	tests := []struct {
+		name       string
+		uploadedAt time.Time
+		wantHit    bool
+	}{
+		{name: "upload recente", uploadedAt: time.Now().Add(-time.Hour), wantHit: true},
+		{name: "upload vencido", uploadedAt: time.Now().Add(-mediaUploadTTL - time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := NewMediaService(nil)
+			ms.uploads["hash"] = &mediaUpload{URL: "https://mmg.whatsapp.net/x", UploadedAt: tt.uploadedAt}
+
+			_, ok := ms.cachedUpload("hash")
+			if ok != tt.wantHit {
+				t.Errorf("cachedUpload() = %v, esperava %v", ok, tt.wantHit)
+			}
+			if _, kept := ms.uploads["hash"]; kept != tt.wantHit {
+				t.Errorf("upload no cache = %v, esperava %v", kept, tt.wantHit)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// MentionTarget representa um alvo mencionado em um comando (ex: !tapa @fulano)
+type MentionTarget struct {
+	JID   string // JID mencionado (vazio quando o alvo foi informado apenas por nome)
+	Token string // Texto usado na mensagem, sem o "@"
+	Name  string // Nome de exibição resolvido pelos contatos (ou o próprio token)
+
+	Quoted bool // Alvo obtido do autor da mensagem citada (resposta)
+}
+
+// MentionText retorna o texto que deve aparecer na mensagem para a menção ser clicável
+// O WhatsApp só renderiza a menção quando o texto contém "@" seguido do usuário do JID
+func (t MentionTarget) MentionText() string {
+	if t.JID == "" {
+		return t.Name
+	}
+	if jid, err := types.ParseJID(t.JID); err == nil {
+		return "@" + jid.User
+	}
+	return "@" + t.Token
+}
+
+// messageContextInfo retorna o ContextInfo da mensagem (texto, imagem ou vídeo)
+func messageContextInfo(evt *events.Message) *waProto.ContextInfo {
+	if extended := evt.Message.GetExtendedTextMessage(); extended != nil && extended.ContextInfo != nil {
+		return extended.ContextInfo
+	}
+	if imageMsg := evt.Message.GetImageMessage(); imageMsg != nil && imageMsg.ContextInfo != nil {
+		return imageMsg.ContextInfo
+	}
+	if videoMsg := evt.Message.GetVideoMessage(); videoMsg != nil && videoMsg.ContextInfo != nil {
+		return videoMsg.ContextInfo
+	}
+	return nil
+}
+
+// resolveMentions mapeia cada token "@..." dos argumentos para o JID mencionado correspondente
+// A associação é feita primeiro pelo número (o telefone ou o LID do JID, convertendo um no outro pelo
+// mapeamento LID↔PN do store) e depois pela posição
+// entre os tokens que não casaram com nenhum número. Sem tokens "@", o autor da mensagem citada
+// vira o alvo; sem citação, o primeiro argumento é usado como nome simples (comportamento antigo).
+func (ch *CommandHandler) resolveMentions(ctx context.Context, args []string, evt *events.Message, bot *BotClient) []MentionTarget {
+	var mentionedJIDs []string
+	if contextInfo := messageContextInfo(evt); contextInfo != nil {
+		mentionedJIDs = contextInfo.GetMentionedJID()
+	}
+
+	// Coletar tokens de menção na ordem em que aparecem
+	var tokens []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "@") && len(arg) > 1 {
+			tokens = append(tokens, strings.TrimPrefix(arg, "@"))
+		}
+	}
+
+	if len(tokens) == 0 {
+		// Comando enviado como resposta: o autor da mensagem citada é o alvo implícito
+		if target := ch.quotedTarget(ctx, evt, bot); target != nil {
+			return []MentionTarget{*target}
+		}
+		if len(args) == 0 {
+			return nil
+		}
+		return []MentionTarget{{Token: args[0], Name: args[0]}}
+	}
+
+	targets := make([]MentionTarget, len(tokens))
+	used := make([]bool, len(mentionedJIDs))
+
+	// Números de cada JID mencionado: o próprio usuário e o equivalente PN/LID, quando conhecido
+	mentionedUsers := make([][]string, len(mentionedJIDs))
+	for j, mentioned := range mentionedJIDs {
+		if jid, err := types.ParseJID(mentioned); err == nil {
+			mentionedUsers[j] = jidUsers(ctx, jid, bot)
+		}
+	}
+
+	// 1ª passada: casar pelo número do token (ex: "@5598999999999" -> 5598999999999@s.whatsapp.net)
+	// Um número repetido ("@a @a") volta ao mesmo JID, já que o WhatsApp lista cada menção uma vez só
+	for i, token := range tokens {
+		targets[i] = MentionTarget{Token: token}
+		digits := onlyDigits(token)
+		if digits == "" {
+			continue
+		}
+		match := -1
+		for j := range mentionedJIDs {
+			if !slices.Contains(mentionedUsers[j], digits) {
+				continue
+			}
+			if !used[j] {
+				match = j
+				break
+			}
+			if match < 0 {
+				match = j
+			}
+		}
+		if match >= 0 {
+			targets[i].JID = mentionedJIDs[match]
+			used[match] = true
+		}
+	}
+
+	// 2ª passada: tokens restantes recebem os JIDs restantes pela ordem
+	next := 0
+	for i := range targets {
+		if targets[i].JID != "" {
+			continue
+		}
+		for next < len(mentionedJIDs) && used[next] {
+			next++
+		}
+		if next >= len(mentionedJIDs) {
+			break
+		}
+		targets[i].JID = mentionedJIDs[next]
+		used[next] = true
+	}
+
+	// Resolver nomes de exibição
+	for i := range targets {
+		targets[i].Name = targets[i].Token
+		if targets[i].JID == "" {
+			continue
+		}
+		jid, err := types.ParseJID(targets[i].JID)
+		if err != nil {
+			continue
+		}
+		if name := ch.getParticipantName(ctx, jid, bot); name != "" {
+			targets[i].Name = name
+		}
+	}
+
+	// Remover alvos repetidos (ex: "!abraco @a @a")
+	seen := make(map[string]bool)
+	unique := targets[:0]
+	for _, target := range targets {
+		key := target.JID
+		if key == "" {
+			key = "name:" + strings.ToLower(target.Token)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, target)
+	}
+
+	return unique
+}
+
+// jidUsers retorna os números que identificam um JID: o usuário do próprio JID e, quando o store conhece
+// o mapeamento, o equivalente do outro endereçamento (o telefone de um LID ou o LID de um telefone)
+func jidUsers(ctx context.Context, jid types.JID, bot *BotClient) []string {
+	users := []string{jid.User}
+	if bot == nil || bot.WAClient == nil || bot.WAClient.Store == nil || bot.WAClient.Store.LIDs == nil {
+		return users
+	}
+
+	var (
+		alt types.JID
+		err error
+	)
+	switch jid.Server {
+	case types.HiddenUserServer:
+		alt, err = bot.WAClient.Store.LIDs.GetPNForLID(ctx, jid)
+	case types.DefaultUserServer:
+		alt, err = bot.WAClient.Store.LIDs.GetLIDForPN(ctx, jid)
+	default:
+		return users
+	}
+	if err != nil {
+		log.Debug().Err(err).Str("jid", jid.String()).Msg("Erro ao converter JID entre LID e telefone")
+		return users
+	}
+	if !alt.IsEmpty() {
+		users = append(users, alt.User)
+	}
+	return users
+}
+
+// quotedTarget retorna o autor da mensagem citada como alvo, ou nil se não houver citação
+func (ch *CommandHandler) quotedTarget(ctx context.Context, evt *events.Message, bot *BotClient) *MentionTarget {
+	contextInfo := messageContextInfo(evt)
+	if contextInfo == nil || contextInfo.QuotedMessage == nil || contextInfo.GetParticipant() == "" {
+		return nil
+	}
+
+	jid, err := types.ParseJID(contextInfo.GetParticipant())
+	if err != nil {
+		return nil
+	}
+
+	target := &MentionTarget{
+		JID:    jid.ToNonAD().String(),
+		Token:  jid.User,
+		Name:   jid.User,
+		Quoted: true,
+	}
+	if name := ch.getParticipantName(ctx, jid, bot); name != "" {
+		target.Name = name
+	}
+	return target
+}
+
+// replyContextInfo monta o ContextInfo da resposta do bot com as menções informadas
+// Quando o alvo veio de uma citação, a resposta cita a mesma mensagem para ficar encadeada
+func replyContextInfo(evt *events.Message, targets []MentionTarget, mentions []string) *waProto.ContextInfo {
+	var quoted bool
+	for _, target := range targets {
+		if target.Quoted {
+			quoted = true
+			break
+		}
+	}
+
+	if !quoted && len(mentions) == 0 {
+		return nil
+	}
+
+	contextInfo := &waProto.ContextInfo{
+		MentionedJID: mentions,
+	}
+
+	if original := messageContextInfo(evt); quoted && original != nil {
+		contextInfo.StanzaID = original.StanzaID
+		contextInfo.Participant = original.Participant
+		contextInfo.QuotedMessage = original.QuotedMessage
+	}
+
+	return contextInfo
+}
+
+// mentionJIDs retorna os JIDs dos alvos que possuem menção clicável
+func mentionJIDs(targets []MentionTarget) []string {
+	var jids []string
+	for _, target := range targets {
+		if target.JID != "" {
+			jids = append(jids, target.JID)
+		}
+	}
+	return jids
+}
+
+// joinMentionTexts junta os textos dos alvos no formato "*a*, *b* e *c*"
+func joinMentionTexts(targets []MentionTarget) string {
+	parts := make([]string, len(targets))
+	for i, target := range targets {
+		parts[i] = fmt.Sprintf("*%s*", target.MentionText())
+	}
+
+	if len(parts) <= 1 {
+		return strings.Join(parts, "")
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " e " + parts[len(parts)-1]
+}
+
+// onlyDigits retorna apenas os dígitos de uma string (ex: "+55 98 9999-9999" -> "5598999999999")
+func onlyDigits(s string) string {
+	var result strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			result.WriteRune(r)
+		}
+	}
+	return result.String()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// testContacts é um store de contatos em memória (só GetContact é usado pelas menções)
+type testContacts struct {
+	store.ContactStore
+	names map[string]string // JID -> nome
+}
+
+func (c testContacts) GetContact(ctx context.Context, user types.JID) (types.ContactInfo, error) {
+	name, ok := c.names[user.String()]
+	return types.ContactInfo{Found: ok, PushName: name}, nil
+}
+
+// testLIDs é um mapeamento LID↔telefone em memória
+type testLIDs struct {
+	store.LIDStore
+	pnByLID map[string]string // usuário do LID -> usuário do telefone
+}
+
+func (l testLIDs) GetPNForLID(ctx context.Context, lid types.JID) (types.JID, error) {
+	if pn, ok := l.pnByLID[lid.User]; ok {
+		return types.NewJID(pn, types.DefaultUserServer), nil
+	}
+	return types.EmptyJID, nil
+}
+
+func (l testLIDs) GetLIDForPN(ctx context.Context, pn types.JID) (types.JID, error) {
+	for lid, user := range l.pnByLID {
+		if user == pn.User {
+			return types.NewJID(lid, types.HiddenUserServer), nil
+		}
+	}
+	return types.EmptyJID, fmt.Errorf("LID não encontrado para %s", pn)
+}
+
+// mentionEvent monta uma mensagem de texto com os JIDs mencionados
+func mentionEvent(mentioned ...string) *events.Message {
+	return &events.Message{Message: &waProto.Message{
+		ExtendedTextMessage: &waProto.ExtendedTextMessage{
+			ContextInfo: &waProto.ContextInfo{MentionedJID: mentioned},
+		},
+	}}
+}
+
+func TestResolveMentions(t *testing.T) {
+	const (
+		anaPN  = "5598999999999@s.whatsapp.net"
+		anaLID = "123456789@lid"
+		biaPN  = "5511988887777@s.whatsapp.net"
+	)
+
+	bot := &BotClient{WAClient: &whatsmeow.Client{Store: &store.Device{
+		Contacts: testContacts{names: map[string]string{anaPN: "Ana", biaPN: "Bia"}},
+		LIDs:     testLIDs{pnByLID: map[string]string{"123456789": "5598999999999"}},
+	}}}
+
+	type target struct{ JID, Name string }
+	tests := []struct {
+		name      string
+		args      []string
+		mentioned []string
+		want      []target
+	}{
+		{
+			name:      "menção escrita como telefone de um JID LID",
+			args:      []string{"@Bia", "@5598999999999"},
+			mentioned: []string{biaPN, anaLID},
+			want:      []target{{biaPN, "Bia"}, {anaLID, "Ana"}},
+		},
+		{
+			name:      "menção escrita como LID",
+			args:      []string{"@123456789"},
+			mentioned: []string{biaPN, anaLID},
+			want:      []target{{anaLID, "Ana"}},
+		},
+		{
+			name:      "menção escrita como LID de um JID telefone",
+			args:      []string{"@5511988887777", "@123456789"},
+			mentioned: []string{anaPN, biaPN},
+			want:      []target{{biaPN, "Bia"}, {anaPN, "Ana"}},
+		},
+		{
+			name:      "alvo repetido",
+			args:      []string{"@5598999999999", "@5598999999999"},
+			mentioned: []string{anaPN},
+			want:      []target{{anaPN, "Ana"}},
+		},
+		{
+			name:      "nome repetido sem número",
+			args:      []string{"@Ana", "@ana"},
+			mentioned: nil,
+			want:      []target{{"", "Ana"}},
+		},
+		{
+			name:      "mais tokens que JIDs mencionados",
+			args:      []string{"@5598999999999", "@Carlos", "@Duda"},
+			mentioned: []string{anaPN},
+			want:      []target{{anaPN, "Ana"}, {"", "Carlos"}, {"", "Duda"}},
+		},
+		{
+			name:      "tokens sem número casam pela posição",
+			args:      []string{"@Fulana", "texto", "@Ciclana"},
+			mentioned: []string{biaPN, anaPN},
+			want:      []target{{biaPN, "Bia"}, {anaPN, "Ana"}},
+		},
+		{
+			name: "sem menção usa o primeiro argumento como nome",
+			args: []string{"fulano", "de", "tal"},
+			want: []target{{"", "fulano"}},
+		},
+	}
+
+	ch := &CommandHandler{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets := ch.resolveMentions(context.Background(), tt.args, mentionEvent(tt.mentioned...), bot)
+			var got []target
+			for _, mention := range targets {
+				got = append(got, target{mention.JID, mention.Name})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("resolveMentions(%q) = %v, esperava %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// modelListTimeout limita a consulta dos modelos disponíveis na API
+const modelListTimeout = 15 * time.Second
+
+// initModelTables cria a tabela com o modelo escolhido para cada chat
+func (c *ChatContext) initModelTables() error {
+	createChatModelsTableQuery := `
+		CREATE TABLE IF NOT EXISTS chat_models (
+			chat_jid TEXT PRIMARY KEY,
+			model TEXT NOT NULL,
+			updated_by TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+	`
+
+	_, err := c.db.Exec(createChatModelsTableQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar tabela chat_models: %w", err)
+	}
+
+	return nil
+}
+
+// SetChatModel define o modelo usado nas chamadas à IA de um chat
+func (c *ChatContext) SetChatModel(ctx context.Context, chatJID, model, updatedBy string) error {
+	query := `
+		INSERT INTO chat_models (chat_jid, model, updated_by, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(chat_jid) DO UPDATE SET
+			model = excluded.model,
+			updated_by = excluded.updated_by,
+			updated_at = excluded.updated_at
+	`
+
+	_, err := c.db.ExecContext(ctx, query, chatJID, model, updatedBy)
+	if err != nil {
+		return fmt.Errorf("erro ao salvar modelo do chat: %w", err)
+	}
+
+	return nil
+}
+
+// ClearChatModel faz o chat voltar a usar o modelo global
+func (c *ChatContext) ClearChatModel(ctx context.Context, chatJID string) (bool, error) {
+	result, err := c.db.ExecContext(ctx, `DELETE FROM chat_models WHERE chat_jid = ?`, chatJID)
+	if err != nil {
+		return false, fmt.Errorf("erro ao remover modelo do chat: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("erro ao verificar remoção do modelo do chat: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// GetChatModel retorna o modelo escolhido para o chat (vazio se usa o modelo global)
+func (c *ChatContext) GetChatModel(ctx context.Context, chatJID string) (string, error) {
+	var model string
+	err := c.db.QueryRowContext(ctx, `SELECT model FROM chat_models WHERE chat_jid = ?`, chatJID).Scan(&model)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("erro ao buscar modelo do chat: %w", err)
+	}
+
+	return model, nil
+}
+
+// modelContextKey é a chave do modelo escolhido para o chat no contexto da chamada
+type modelContextKey struct{}
+
+// withModel faz a chamada à IA usar o modelo informado no lugar do modelo global
+// Modelo vazio volta ao modelo global
+func withModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelContextKey{}, model)
+}
+
+// modelFromContext retorna o modelo escolhido para a chamada ou, se nenhum, o padrão do provedor
+func modelFromContext(ctx context.Context, fallback string) string {
+	if model, _ := ctx.Value(modelContextKey{}).(string); model != "" {
+		return model
+	}
+	return fallback
+}
+
+// aiContext prepara o contexto de uma chamada à IA: a origem (para o uso e as cotas),
+// a persona e o modelo escolhido para o chat
+func (bot *BotClient) aiContext(ctx context.Context, evt *events.Message, persona Persona, command string) context.Context {
+	ctx = withPersona(bot.usageContext(ctx, evt, command), persona)
+	chat := evt.Info.Chat
+
+	model, err := bot.chatContext.GetChatModel(ctx, chat.String())
+	if err != nil {
+		log.Warn().Err(err).Str("chat", chat.String()).Msg("Erro ao buscar modelo do chat, usando o global")
+		return ctx
+	}
+	if model != "" {
+		ctx = withModel(ctx, model)
+	}
+	return ctx
+}
+
+// availableModels consulta os modelos disponíveis no provedor de IA
+func availableModels(ctx context.Context, provider LLMProvider) ([]string, error) {
+	lister, ok := providerAs[ModelLister](provider)
+	if !ok {
+		return nil, fmt.Errorf("o provedor %s não lista modelos", provider.Name())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, modelListTimeout)
+	defer cancel()
+	return lister.ListModels(ctx)
+}
+
+// validateStartupModels confere se os modelos configurados nas flags existem no provedor
+// O modelo principal inexistente impede o bot de iniciar; fallbacks inexistentes só geram aviso
+// Se a lista não puder ser consultada (ex: sem rede), a validação é pulada
+func validateStartupModels(ctx context.Context, provider LLMProvider, fallbacks []string) error {
+	models, err := availableModels(ctx, provider)
+	if err != nil {
+		log.Warn().Err(err).Msg("Não foi possível validar o modelo de IA configurado")
+		return nil
+	}
+
+	if !slices.Contains(models, provider.GetModel()) {
+		return fmt.Errorf("modelo %s não está disponível no provedor %s (disponíveis: %s)", provider.GetModel(), provider.Name(), strings.Join(models, ", "))
+	}
+	for _, fallback := range fallbacks {
+		if !slices.Contains(models, fallback) {
+			log.Warn().Str("model", fallback).Msg("Modelo de fallback não está disponível no provedor")
+		}
+	}
+
+	log.Info().Int("available", len(models)).Str("model", provider.GetModel()).Msg("Modelo de IA validado")
+	return nil
+}
+
+// handleModeloCommand mostra e troca o modelo de IA (só administradores)
+// Uso: !modelo | !modelo lista | !modelo <nome> | !modelo global <nome> | !modelo padrao
+func (ch *CommandHandler) handleModeloCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
+	if !bot.requireAdmin(ctx, evt) {
+		return nil
+	}
+
+	if bot.llm == nil {
+		return ch.sendReplyMessage(ctx, "❌ IA não está configurada. Configure um provedor (ex: API key do Gemini) para usar este comando.", nil, evt, bot)
+	}
+
+	chatJID := evt.Info.Chat.String()
+
+	// Sem argumentos: mostrar os modelos em uso
+	if len(args) == 0 {
+		chatModel, err := bot.chatContext.GetChatModel(ctx, chatJID)
+		if err != nil {
+			log.Error().Err(err).Msg("Erro ao buscar modelo do chat")
+		}
+
+		var text strings.Builder
+		text.WriteString("🧠 *Modelo de IA*\n\n")
+		fmt.Fprintf(&text, "Global: *%s* (%s)\n", bot.llm.GetModel(), bot.llm.Name())
+		if chatModel != "" {
+			fmt.Fprintf(&text, "Neste chat: *%s*\n", chatModel)
+		} else {
+			text.WriteString("Neste chat: usa o global\n")
+		}
+		if resilient, ok := providerAs[*ResilientProvider](bot.llm); ok && len(resilient.FallbackModels()) > 0 {
+			fmt.Fprintf(&text, "Fallbacks: %s\n", strings.Join(resilient.FallbackModels(), ", "))
+		}
+		text.WriteString("\n!modelo lista - modelos disponíveis\n!modelo <nome> - trocar neste chat\n!modelo global <nome> - trocar para todos\n!modelo padrao - voltar este chat ao global")
+		return ch.sendReplyMessage(ctx, text.String(), nil, evt, bot)
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "lista", "listar":
+		models, err := availableModels(ctx, bot.llm)
+		if err != nil {
+			log.Error().Err(err).Msg("Erro ao listar modelos")
+			return ch.sendReplyMessage(ctx, "❌ Não foi possível consultar os modelos disponíveis. Tente novamente mais tarde.", nil, evt, bot)
+		}
+
+		var text strings.Builder
+		fmt.Fprintf(&text, "🧠 *Modelos disponíveis* (%d)\n", len(models))
+		for _, model := range models {
+			marker := ""
+			if model == bot.llm.GetModel() {
+				marker = " ✅"
+			}
+			fmt.Fprintf(&text, "\n• %s%s", model, marker)
+		}
+		_, err = bot.chunker.Send(ctx, evt.Info.Chat, evt.Info.Sender, text.String(), nil)
+		return err
+
+	case "padrao", "padrão":
+		removed, err := bot.chatContext.ClearChatModel(ctx, chatJID)
+		if err != nil {
+			log.Error().Err(err).Msg("Erro ao remover modelo do chat")
+			return ch.sendReplyMessage(ctx, "❌ Erro ao voltar ao modelo global.", nil, evt, bot)
+		}
+		if !removed {
+			return ch.sendReplyMessage(ctx, fmt.Sprintf("ℹ️ Este chat já usa o modelo global (*%s*).", bot.llm.GetModel()), nil, evt, bot)
+		}
+		log.Info().Str("chat", chatJID).Str("by", evt.Info.Sender.String()).Msg("Chat voltou ao modelo global")
+		return ch.sendReplyMessage(ctx, fmt.Sprintf("✅ Este chat voltou ao modelo global (*%s*).", bot.llm.GetModel()), nil, evt, bot)
+
+	case "global":
+		if len(args) < 2 {
+			return ch.sendReplyMessage(ctx, "❌ Use: !modelo global <nome>\nVeja os nomes com !modelo lista", nil, evt, bot)
+		}
+		model := args[1]
+		if !ch.checkModelAvailable(ctx, model, evt, bot) {
+			return nil
+		}
+
+		previous := bot.llm.GetModel()
+		bot.llm.SetModel(model)
+		log.Info().Str("from", previous).Str("to", model).Str("by", evt.Info.Sender.String()).Msg("Modelo global de IA alterado")
+		return ch.sendReplyMessage(ctx, fmt.Sprintf("✅ Modelo global trocado de *%s* para *%s*.\n(Volta ao modelo da flag quando o bot reiniciar.)", previous, model), nil, evt, bot)
+
+	default:
+		model := args[0]
+		if !ch.checkModelAvailable(ctx, model, evt, bot) {
+			return nil
+		}
+
+		err := bot.chatContext.SetChatModel(ctx, chatJID, model, evt.Info.Sender.String())
+		if err != nil {
+			log.Error().Err(err).Msg("Erro ao salvar modelo do chat")
+			return ch.sendReplyMessage(ctx, "❌ Erro ao trocar o modelo deste chat.", nil, evt, bot)
+		}
+		log.Info().Str("chat", chatJID).Str("model", model).Str("by", evt.Info.Sender.String()).Msg("Modelo de IA do chat alterado")
+		return ch.sendReplyMessage(ctx, fmt.Sprintf("✅ Este chat agora usa o modelo *%s*.", model), nil, evt, bot)
+	}
+}
+
+// checkModelAvailable confere se o modelo existe no provedor e avisa no chat quando não existe
+func (ch *CommandHandler) checkModelAvailable(ctx context.Context, model string, evt *events.Message, bot *BotClient) bool {
+	models, err := availableModels(ctx, bot.llm)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao listar modelos")
+		ch.sendReplyMessage(ctx, "❌ Não foi possível consultar os modelos disponíveis. Tente novamente mais tarde.", nil, evt, bot)
+		return false
+	}
+
+	if !slices.Contains(models, model) {
+		ch.sendReplyMessage(ctx, fmt.Sprintf("❌ Modelo não disponível: %s\nVeja os nomes com !modelo lista", model), nil, evt, bot)
+		return false
+	}
+	return true
+}
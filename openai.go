@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// openAIRequestTimeout limita a duração de uma requisição sem streaming
+const openAIRequestTimeout = 2 * time.Minute
+
+// OpenAIProvider conversa com qualquer API compatível com o endpoint /chat/completions da OpenAI
+// Funciona com a própria OpenAI e com servidores locais como Ollama, llama.cpp e LM Studio
+type OpenAIProvider struct {
+	baseURL    string       // Ex: https://api.openai.com/v1 ou http://localhost:11434/v1
+	apiKey     string       // Opcional para servidores locais
+	mu         sync.RWMutex // Protege model: o !modelo global troca o modelo com o bot rodando
+	model      string
+	httpClient *http.Client
+}
+
+// openAIMessage é uma mensagem do chat no formato da API
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// openAIChatRequest é o corpo de uma requisição para /chat/completions
+type openAIChatRequest struct {
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	Stream         bool                  `json:"stream,omitempty"`
+	Temperature    *float32              `json:"temperature,omitempty"`
+	TopP           *float32              `json:"top_p,omitempty"`
+	MaxTokens      *int32                `json:"max_tokens,omitempty"`
+	Stop           []string              `json:"stop,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+	StreamOptions  *openAIStreamOptions  `json:"stream_options,omitempty"`
+}
+
+// openAIStreamOptions pede o uso de tokens no último trecho do streaming
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// openAIResponseFormat pede a resposta em JSON ({"type": "json_object"})
+type openAIResponseFormat struct {
+	Type string `json:"type"`
+}
+
+// openAIChatResponse é a resposta de /chat/completions (com ou sem streaming)
+type openAIChatResponse struct {
+	Choices []struct {
+		Message      openAIMessage `json:"message"`
+		Delta        openAIMessage `json:"delta"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// OpenAIStatusError é o erro de uma resposta da API com status HTTP diferente de 200
+type OpenAIStatusError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error implementa a interface error
+func (e *OpenAIStatusError) Error() string {
+	return fmt.Sprintf("API compatível com OpenAI retornou status %d: %s", e.StatusCode, e.Message)
+}
+
+// NewOpenAIProvider cria um provedor para uma API compatível com OpenAI
+// A API key pode vir do parâmetro ou da variável de ambiente OPENAI_API_KEY
+func NewOpenAIProvider(baseURL, apiKey, model string) (*OpenAIProvider, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("URL da API compatível com OpenAI não informada")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("modelo da API compatível com OpenAI não informado")
+	}
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+
+	return &OpenAIProvider{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		// Sem timeout no cliente: o streaming pode durar mais; as requisições usam o contexto
+		httpClient: &http.Client{},
+	}, nil
+}
+
+// Name identifica o provedor nos logs
+func (o *OpenAIProvider) Name() string {
+	return "openai"
+}
+
+// GetModel retorna o modelo atual
+func (o *OpenAIProvider) GetModel() string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.model
+}
+
+// SetModel define o modelo a ser usado
+func (o *OpenAIProvider) SetModel(model string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.model = model
+}
+
+// GenerateContent gera uma resposta para o prompt
+func (o *OpenAIProvider) GenerateContent(ctx context.Context, prompt string) (string, error) {
+	return o.GenerateContentWithHistory(ctx, prompt, nil)
+}
+
+// GenerateContentWithHistory gera uma resposta considerando o histórico da conversa
+func (o *OpenAIProvider) GenerateContentWithHistory(ctx context.Context, prompt string, history []ChatMessage) (string, error) {
+	return resultText(o.Generate(ctx, prompt, history))
+}
+
+// Generate gera uma resposta completa com motivo de término e uso de tokens
+func (o *OpenAIProvider) Generate(ctx context.Context, prompt string, history []ChatMessage) (*LLMResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, openAIRequestTimeout)
+	defer cancel()
+
+	model := modelFromContext(ctx, o.GetModel())
+	start := time.Now()
+	body, err := o.post(ctx, o.chatRequest(ctx, model, prompt, history, false))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var response openAIChatResponse
+	err = json.NewDecoder(body).Decode(&response)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao decodificar resposta: %w", err)
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("erro da API: %s", response.Error.Message)
+	}
+	if response.Usage != nil {
+		recordUsage(ctx, model, response.usage(), time.Since(start))
+	}
+	if len(response.Choices) == 0 {
+		return nil, fmt.Errorf("resposta vazia do modelo")
+	}
+
+	choice := response.Choices[0]
+	result := &LLMResult{
+		FinishReason: openAIFinishReason(choice.FinishReason),
+		Parts:        1,
+		Usage:        response.usage(),
+		Model:        model,
+	}
+	if result.FinishReason == "SAFETY" {
+		return nil, &BlockedError{Reason: "SAFETY"}
+	}
+	if strings.TrimSpace(choice.Message.Content) == "" {
+		return nil, fmt.Errorf("resposta vazia do modelo (motivo de término: %s)", choice.FinishReason)
+	}
+
+	result.Text = formatResponse(choice.Message.Content, emojisForbidden(ctx))
+	return result, nil
+}
+
+// usage converte o uso de tokens informado pela API (zerado quando o servidor não informa)
+func (r *openAIChatResponse) usage() LLMUsage {
+	if r.Usage == nil {
+		return LLMUsage{}
+	}
+	return LLMUsage{
+		PromptTokens:    r.Usage.PromptTokens,
+		CandidateTokens: r.Usage.CompletionTokens,
+		TotalTokens:     r.Usage.TotalTokens,
+	}
+}
+
+// openAIFinishReason converte o finish_reason da API para os nomes usados pelo Gemini
+func openAIFinishReason(reason string) string {
+	switch reason {
+	case "stop", "tool_calls":
+		return finishReasonStop
+	case "length":
+		return finishReasonMaxTokens
+	case "content_filter":
+		return "SAFETY"
+	default:
+		return strings.ToUpper(reason)
+	}
+}
+
+// GenerateContentStream gera a resposta em streaming (Server-Sent Events)
+// Cada item do iterador é o texto acumulado até o momento, já formatado para o WhatsApp
+func (o *OpenAIProvider) GenerateContentStream(ctx context.Context, prompt string) iter.Seq2[string, error] {
+	stripEmojis := emojisForbidden(ctx)
+
+	return func(yield func(string, error) bool) {
+		model := modelFromContext(ctx, o.GetModel())
+		start := time.Now()
+		body, err := o.post(ctx, o.chatRequest(ctx, model, prompt, nil, true))
+		if err != nil {
+			yield("", err)
+			return
+		}
+		defer body.Close()
+
+		var full strings.Builder
+		var usage LLMUsage
+		defer func() {
+			// Servidores que ignoram stream_options não informam o uso: estimar pelo texto
+			if usage.TotalTokens == 0 && full.Len() > 0 {
+				usage = LLMUsage{PromptTokens: estimateTokens(prompt), CandidateTokens: estimateTokens(full.String())}
+			}
+			recordUsage(ctx, model, usage, time.Since(start))
+		}()
+
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			data, ok := strings.CutPrefix(line, "data:")
+			if !ok {
+				continue
+			}
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				break
+			}
+
+			var chunk openAIChatResponse
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				log.Debug().Err(err).Str("data", data).Msg("Trecho de streaming ignorado")
+				continue
+			}
+			if chunk.Error != nil {
+				yield("", fmt.Errorf("erro da API: %s", chunk.Error.Message))
+				return
+			}
+			if chunk.Usage != nil {
+				usage = chunk.usage()
+			}
+			if len(chunk.Choices) > 0 && chunk.Choices[0].FinishReason == "content_filter" {
+				yield("", &BlockedError{Reason: "SAFETY"})
+				return
+			}
+			if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+				continue
+			}
+
+			full.WriteString(chunk.Choices[0].Delta.Content)
+			if !yield(formatResponse(full.String(), stripEmojis), nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield("", fmt.Errorf("erro ao ler streaming: %w", err))
+		}
+	}
+}
+
+// CountTokens estima os tokens do texto (a API compatível com OpenAI não tem endpoint de contagem)
+func (o *OpenAIProvider) CountTokens(ctx context.Context, text string) (int, error) {
+	return estimateTokens(text), nil
+}
+
+// ListModels retorna os modelos disponíveis no servidor (GET /models)
+func (o *OpenAIProvider) ListModels(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, openAIRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.baseURL+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar requisição: %w", err)
+	}
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao listar modelos: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &OpenAIStatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+
+	var response struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao decodificar lista de modelos: %w", err)
+	}
+
+	models := make([]string, 0, len(response.Data))
+	for _, model := range response.Data {
+		models = append(models, model.ID)
+	}
+	sort.Strings(models)
+	return models, nil
+}
+
+// chatRequest monta a requisição com os parâmetros de geração da persona marcada no contexto
+// O orçamento de raciocínio não tem equivalente na API e é ignorado
+func (o *OpenAIProvider) chatRequest(ctx context.Context, model, prompt string, history []ChatMessage, stream bool) openAIChatRequest {
+	generation := personas.Generation(personaFromContext(ctx))
+
+	request := openAIChatRequest{
+		Model:       model,
+		Messages:    openAIMessages(prompt, history),
+		Stream:      stream,
+		Temperature: generation.Temperature,
+		TopP:        generation.TopP,
+		MaxTokens:   generation.MaxOutputTokens,
+		Stop:        generation.StopSequences,
+	}
+	if stream {
+		request.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+	if generation.ResponseMIMEType == "application/json" {
+		request.ResponseFormat = &openAIResponseFormat{Type: "json_object"}
+	}
+	return request
+}
+
+// post envia uma requisição para /chat/completions e retorna o corpo da resposta
+// Respostas com status diferente de 200 viram erro com a mensagem da API
+func (o *OpenAIProvider) post(ctx context.Context, request openAIChatRequest) (io.ReadCloser, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao montar requisição: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar requisição: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao chamar API compatível com OpenAI: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &OpenAIStatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+
+	return resp.Body, nil
+}
+
+// openAIMessages converte o histórico da conversa e o prompt para mensagens da API
+func openAIMessages(prompt string, history []ChatMessage) []openAIMessage {
+	messages := make([]openAIMessage, 0, len(history)+1)
+	for _, message := range history {
+		role := "user"
+		if message.MessageType == "assistant" {
+			role = "assistant"
+		}
+		messages = append(messages, openAIMessage{Role: role, Content: message.MessageText})
+	}
+	return append(messages, openAIMessage{Role: "user", Content: prompt})
+}
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// personasFile é o arquivo opcional com os ajustes de cada persona
+// As mudanças feitas com !persona são salvas nele
+const personasFile = "personas.json"
+
+// Persona identifica o "papel" do bot em uma chamada à IA (atendimento, humor, histórias...)
+// Cada persona tem seus próprios filtros de segurança e parâmetros de geração
+type Persona string
+
+// Personas usadas pelos comandos e conversas
+const (
+	personaAtendimento Persona = "atendimento" // Conversa no privado com a persona da empresa
+	personaGrupo       Persona = "grupo"       // Respostas às menções em grupos
+	personaHumor       Persona = "humor"       // !piada e !cantada
+	personaHistoria    Persona = "historia"    // !historia e a história colaborativa
+	personaExplique    Persona = "explique"    // !explique (analisa mensagens encaminhadas, inclusive correntes)
+	personaResumo      Persona = "resumo"      // !resumo (resume a conversa do grupo)
+)
+
+// personaCommands descreve onde cada persona é usada (para o !persona)
+var personaCommands = map[Persona]string{
+	personaAtendimento: "conversa no privado",
+	personaGrupo:       "menções em grupos",
+	personaHumor:       "!piada, !cantada",
+	personaHistoria:    "!historia, !continuar",
+	personaExplique:    "!explique",
+	personaResumo:      "!resumo",
+}
+
+// Limites de bloqueio aceitos nos filtros de segurança (do mais ao menos restritivo)
+const (
+	safetyBlockLow    = "BLOCK_LOW_AND_ABOVE"
+	safetyBlockMedium = "BLOCK_MEDIUM_AND_ABOVE"
+	safetyBlockHigh   = "BLOCK_ONLY_HIGH"
+	safetyBlockNone   = "BLOCK_NONE"
+	safetyOff         = "OFF"
+)
+
+// Categorias de risco configuráveis
+const (
+	harmHarassment = "HARM_CATEGORY_HARASSMENT"
+	harmHate       = "HARM_CATEGORY_HATE_SPEECH"
+	harmSexual     = "HARM_CATEGORY_SEXUALLY_EXPLICIT"
+	harmDangerous  = "HARM_CATEGORY_DANGEROUS_CONTENT"
+	harmCivic      = "HARM_CATEGORY_CIVIC_INTEGRITY"
+)
+
+// PersonaSettings são os ajustes de uma persona
+type PersonaSettings struct {
+	// Safety mapeia a categoria de risco para o limite de bloqueio (ex: "HARM_CATEGORY_HARASSMENT": "BLOCK_ONLY_HIGH")
+	Safety map[string]string `json:"safety,omitempty"`
+	// Generation são os parâmetros de geração (temperatura, tamanho da resposta...)
+	Generation GenerationSettings `json:"generation,omitzero"`
+}
+
+// GenerationSettings são os parâmetros de geração de uma persona
+// Campos nulos usam o padrão do provedor
+type GenerationSettings struct {
+	Temperature      *float32 `json:"temperature,omitempty"`        // 0 a 2: mais alto, mais criativo
+	TopP             *float32 `json:"top_p,omitempty"`              // 0 a 1: amostragem nucleus
+	MaxOutputTokens  *int32   `json:"max_output_tokens,omitempty"`  // Limite de tokens da resposta
+	StopSequences    []string `json:"stop_sequences,omitempty"`     // A geração para ao encontrar uma delas
+	ThinkingBudget   *int32   `json:"thinking_budget,omitempty"`    // Tokens de raciocínio (0 desliga; só modelos com thinking)
+	ResponseMIMEType string   `json:"response_mime_type,omitempty"` // text/plain ou application/json
+}
+
+// SafetySetting é o limite de bloqueio de uma categoria de risco
+type SafetySetting struct {
+	Category  string
+	Threshold string
+}
+
+// defaultPersonas são os ajustes usados quando personas.json não existe ou não menciona a persona
+var defaultPersonas = map[Persona]PersonaSettings{
+	personaAtendimento: {
+		Safety: map[string]string{
+			harmHarassment: safetyBlockMedium,
+			harmHate:       safetyBlockMedium,
+			harmSexual:     safetyBlockMedium,
+			harmDangerous:  safetyBlockMedium,
+		},
+		Generation: GenerationSettings{
+			Temperature:     float32Ptr(0.8),
+			MaxOutputTokens: int32Ptr(2048),
+		},
+	},
+	personaGrupo: {
+		Safety: map[string]string{
+			harmHarassment: safetyBlockMedium,
+			harmHate:       safetyBlockMedium,
+			harmSexual:     safetyBlockMedium,
+			harmDangerous:  safetyBlockMedium,
+		},
+		Generation: GenerationSettings{
+			Temperature:     float32Ptr(0.9),
+			MaxOutputTokens: int32Ptr(2048),
+		},
+	},
+	// Piadas e cantadas provocam por natureza; só bloquear ofensa pesada
+	// Respostas curtas e criativas, sem raciocínio (que só atrasaria a resposta)
+	personaHumor: {
+		Safety: map[string]string{
+			harmHarassment: safetyBlockHigh,
+			harmHate:       safetyBlockMedium,
+			harmSexual:     safetyBlockMedium,
+			harmDangerous:  safetyBlockMedium,
+		},
+		Generation: GenerationSettings{
+			Temperature:     float32Ptr(1.2),
+			TopP:            float32Ptr(0.95),
+			MaxOutputTokens: int32Ptr(512),
+			ThinkingBudget:  int32Ptr(0),
+		},
+	},
+	// Histórias de aventura têm lutas e perigo; respostas longas são permitidas
+	personaHistoria: {
+		Safety: map[string]string{
+			harmHarassment: safetyBlockHigh,
+			harmHate:       safetyBlockMedium,
+			harmSexual:     safetyBlockMedium,
+			harmDangerous:  safetyBlockHigh,
+		},
+		Generation: GenerationSettings{
+			Temperature:     float32Ptr(1.0),
+			MaxOutputTokens: int32Ptr(8192),
+		},
+	},
+	// Correntes e golpes encaminhados trazem ódio e perigo que precisam ser analisados, não repetidos
+	// A explicação é curta e determinística: a mesma mensagem gera a mesma explicação
+	personaExplique: {
+		Safety: map[string]string{
+			harmHarassment: safetyBlockHigh,
+			harmHate:       safetyBlockHigh,
+			harmSexual:     safetyBlockMedium,
+			harmDangerous:  safetyBlockHigh,
+		},
+		Generation: GenerationSettings{
+			Temperature:     float32Ptr(0),
+			TopP:            float32Ptr(1),
+			MaxOutputTokens: int32Ptr(400),
+			ThinkingBudget:  int32Ptr(0),
+		},
+	},
+	// A conversa do grupo tem zoeira e palavrões que precisam ser resumidos, não bloqueados
+	// Resumo fiel e determinístico: as mesmas mensagens geram o mesmo resumo (e saem do cache)
+	personaResumo: {
+		Safety: map[string]string{
+			harmHarassment: safetyBlockHigh,
+			harmHate:       safetyBlockMedium,
+			harmSexual:     safetyBlockMedium,
+			harmDangerous:  safetyBlockHigh,
+		},
+		Generation: GenerationSettings{
+			Temperature:     float32Ptr(0),
+			MaxOutputTokens: int32Ptr(1024),
+			ThinkingBudget:  int32Ptr(0),
+		},
+	},
+}
+
+// validSafetyThresholds e validHarmCategories validam o personas.json
+var (
+	validSafetyThresholds = map[string]bool{safetyBlockLow: true, safetyBlockMedium: true, safetyBlockHigh: true, safetyBlockNone: true, safetyOff: true}
+	validHarmCategories   = map[string]bool{harmHarassment: true, harmHate: true, harmSexual: true, harmDangerous: true, harmCivic: true}
+	validResponseMIMEs    = map[string]bool{"text/plain": true, "application/json": true}
+)
+
+// PersonaRegistry guarda os ajustes de todas as personas
+// Guarda só o que difere do padrão, para que mudanças nos padrões do código continuem valendo
+type PersonaRegistry struct {
+	mu        sync.RWMutex
+	overrides map[Persona]PersonaSettings
+	path      string // Arquivo onde as mudanças feitas em tempo de execução são salvas
+}
+
+// personas são os ajustes usados por todos os provedores de IA
+var personas = NewPersonaRegistry()
+
+// NewPersonaRegistry cria o registro só com os ajustes padrão
+func NewPersonaRegistry() *PersonaRegistry {
+	return &PersonaRegistry{
+		overrides: make(map[Persona]PersonaSettings),
+	}
+}
+
+// Settings retorna os ajustes efetivos da persona (padrão + personalizações)
+func (r *PersonaRegistry) Settings(persona Persona) PersonaSettings {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	base := defaultPersonas[persona]
+	override := r.overrides[persona]
+
+	safety := copySafety(base.Safety)
+	for category, threshold := range override.Safety {
+		safety[category] = threshold
+	}
+
+	return PersonaSettings{
+		Safety:     safety,
+		Generation: mergeGeneration(base.Generation, override.Generation),
+	}
+}
+
+// SafetySettings retorna os filtros de segurança da persona, ordenados por categoria
+// Persona desconhecida (ou vazia) retorna nil: o provedor usa os próprios padrões
+func (r *PersonaRegistry) SafetySettings(persona Persona) []SafetySetting {
+	settings := r.Settings(persona)
+	if len(settings.Safety) == 0 {
+		return nil
+	}
+
+	safety := make([]SafetySetting, 0, len(settings.Safety))
+	for category, threshold := range settings.Safety {
+		safety = append(safety, SafetySetting{Category: category, Threshold: threshold})
+	}
+	sort.Slice(safety, func(i, j int) bool {
+		return safety[i].Category < safety[j].Category
+	})
+	return safety
+}
+
+// Generation retorna os parâmetros de geração da persona
+func (r *PersonaRegistry) Generation(persona Persona) GenerationSettings {
+	return r.Settings(persona).Generation
+}
+
+// LoadFile aplica os ajustes do arquivo sobre os padrões e passa a salvar as mudanças nele
+// Arquivo inexistente não é erro; o que não for mencionado mantém o valor padrão
+func (r *PersonaRegistry) LoadFile(path string) error {
+	r.mu.Lock()
+	r.path = path
+	r.mu.Unlock()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao ler %s: %w", path, err)
+	}
+
+	var loaded map[Persona]PersonaSettings
+	err = json.Unmarshal(data, &loaded)
+	if err != nil {
+		return fmt.Errorf("erro ao decodificar %s: %w", path, err)
+	}
+
+	for persona, settings := range loaded {
+		err = validatePersonaSettings(settings)
+		if err != nil {
+			return fmt.Errorf("ajustes inválidos na persona %s: %w", persona, err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for persona, settings := range loaded {
+		r.overrides[persona] = settings
+	}
+
+	log.Info().Str("file", path).Int("personas", len(loaded)).Msg("Ajustes das personas carregados")
+	return nil
+}
+
+// SetGeneration altera um parâmetro de geração da persona e salva no arquivo
+// value "padrao" volta o parâmetro ao valor padrão; só retorna erro para valores inválidos
+func (r *PersonaRegistry) SetGeneration(persona Persona, key, value string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	override := r.overrides[persona]
+	err := setGenerationField(&override.Generation, key, value)
+	if err != nil {
+		return err
+	}
+	r.overrides[persona] = override
+
+	r.save()
+	return nil
+}
+
+// ResetGeneration descarta os parâmetros de geração personalizados da persona e salva no arquivo
+// Os filtros de segurança do personas.json não são gerenciados pelo !persona e continuam valendo
+func (r *PersonaRegistry) ResetGeneration(persona Persona) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	override, ok := r.overrides[persona]
+	if !ok {
+		return
+	}
+	override.Generation = GenerationSettings{}
+	if len(override.Safety) == 0 {
+		delete(r.overrides, persona)
+	} else {
+		r.overrides[persona] = override
+	}
+	r.save()
+}
+
+// save grava as personalizações no arquivo (chamado com o lock adquirido)
+// Uma falha ao salvar só é registrada: a mudança continua valendo até o bot reiniciar
+func (r *PersonaRegistry) save() {
+	if r.path == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(r.overrides, "", "  ")
+	if err == nil {
+		err = os.WriteFile(r.path, data, 0644)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("file", r.path).Msg("Erro ao salvar ajustes das personas")
+	}
+}
+
+// validatePersonaSettings valida os ajustes lidos do arquivo
+func validatePersonaSettings(settings PersonaSettings) error {
+	for category, threshold := range settings.Safety {
+		if !validHarmCategories[category] {
+			return fmt.Errorf("categoria de risco inválida: %s", category)
+		}
+		if !validSafetyThresholds[threshold] {
+			return fmt.Errorf("limite de bloqueio inválido: %s", threshold)
+		}
+	}
+
+	generation := settings.Generation
+	if generation.Temperature != nil && (*generation.Temperature < 0 || *generation.Temperature > 2) {
+		return fmt.Errorf("temperatura deve ficar entre 0 e 2")
+	}
+	if generation.TopP != nil && (*generation.TopP < 0 || *generation.TopP > 1) {
+		return fmt.Errorf("top_p deve ficar entre 0 e 1")
+	}
+	if generation.MaxOutputTokens != nil && *generation.MaxOutputTokens < 1 {
+		return fmt.Errorf("max_output_tokens deve ser positivo")
+	}
+	if generation.ThinkingBudget != nil && *generation.ThinkingBudget < -1 {
+		return fmt.Errorf("thinking_budget deve ser -1 (automático), 0 (desligado) ou positivo")
+	}
+	if generation.ResponseMIMEType != "" && !validResponseMIMEs[generation.ResponseMIMEType] {
+		return fmt.Errorf("response_mime_type deve ser text/plain ou application/json")
+	}
+	return nil
+}
+
+// setGenerationField altera um campo dos parâmetros de geração a partir do nome usado no !persona
+func setGenerationField(generation *GenerationSettings, key, value string) error {
+	reset := value == "padrao" || value == "padrão"
+	changed := *generation
+
+	switch key {
+	case "temperatura", "temperature":
+		changed.Temperature = nil
+		if !reset {
+			number, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 32)
+			if err != nil {
+				return fmt.Errorf("temperatura inválida: %s", value)
+			}
+			changed.Temperature = float32Ptr(float32(number))
+		}
+	case "topp", "top_p":
+		changed.TopP = nil
+		if !reset {
+			number, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 32)
+			if err != nil {
+				return fmt.Errorf("top_p inválido: %s", value)
+			}
+			changed.TopP = float32Ptr(float32(number))
+		}
+	case "tokens", "max_output_tokens":
+		changed.MaxOutputTokens = nil
+		if !reset {
+			number, err := strconv.ParseInt(value, 10, 32)
+			if err != nil {
+				return fmt.Errorf("quantidade de tokens inválida: %s", value)
+			}
+			changed.MaxOutputTokens = int32Ptr(int32(number))
+		}
+	case "pensamento", "thinking_budget":
+		changed.ThinkingBudget = nil
+		if !reset {
+			number, err := strconv.ParseInt(value, 10, 32)
+			if err != nil {
+				return fmt.Errorf("orçamento de pensamento inválido: %s", value)
+			}
+			changed.ThinkingBudget = int32Ptr(int32(number))
+		}
+	case "parada", "stop_sequences":
+		changed.StopSequences = nil
+		if !reset && value != "" {
+			for _, stop := range strings.Split(value, "|") {
+				if stop = strings.TrimSpace(stop); stop != "" {
+					changed.StopSequences = append(changed.StopSequences, stop)
+				}
+			}
+		}
+	case "formato", "response_mime_type":
+		changed.ResponseMIMEType = ""
+		if !reset {
+			changed.ResponseMIMEType = value
+		}
+	default:
+		return fmt.Errorf("parâmetro desconhecido: %s", key)
+	}
+
+	err := validatePersonaSettings(PersonaSettings{Generation: changed})
+	if err != nil {
+		return err
+	}
+	*generation = changed
+	return nil
+}
+
+// mergeGeneration aplica os campos definidos em override sobre base
+func mergeGeneration(base, override GenerationSettings) GenerationSettings {
+	merged := base
+	if override.Temperature != nil {
+		merged.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		merged.TopP = override.TopP
+	}
+	if override.MaxOutputTokens != nil {
+		merged.MaxOutputTokens = override.MaxOutputTokens
+	}
+	if override.StopSequences != nil {
+		merged.StopSequences = override.StopSequences
+	}
+	if override.ThinkingBudget != nil {
+		merged.ThinkingBudget = override.ThinkingBudget
+	}
+	if override.ResponseMIMEType != "" {
+		merged.ResponseMIMEType = override.ResponseMIMEType
+	}
+	return merged
+}
+
+// copySafety copia um mapa de filtros de segurança
+func copySafety(safety map[string]string) map[string]string {
+	copied := make(map[string]string, len(safety))
+	for category, threshold := range safety {
+		copied[category] = threshold
+	}
+	return copied
+}
+
+// float32Ptr e int32Ptr ajudam a montar os parâmetros opcionais
+func float32Ptr(value float32) *float32 {
+	return &value
+}
+
+func int32Ptr(value int32) *int32 {
+	return &value
+}
+
+// personaContextKey é a chave da persona no contexto da chamada
+type personaContextKey struct{}
+
+// withPersona marca a chamada à IA com a persona
+// O contexto atravessa os wrappers (novas tentativas, circuit breaker) até o provedor
+func withPersona(ctx context.Context, persona Persona) context.Context {
+	return context.WithValue(ctx, personaContextKey{}, persona)
+}
+
+// personaFromContext retorna a persona da chamada (vazia se não foi marcada)
+func personaFromContext(ctx context.Context) Persona {
+	persona, _ := ctx.Value(personaContextKey{}).(Persona)
+	return persona
+}
+
+// formatGeneration descreve os parâmetros de geração para o !persona
+func formatGeneration(generation GenerationSettings) string {
+	var lines []string
+	if generation.Temperature != nil {
+		lines = append(lines, fmt.Sprintf("temperatura: %.2f", *generation.Temperature))
+	}
+	if generation.TopP != nil {
+		lines = append(lines, fmt.Sprintf("topp: %.2f", *generation.TopP))
+	}
+	if generation.MaxOutputTokens != nil {
+		lines = append(lines, fmt.Sprintf("tokens: %d", *generation.MaxOutputTokens))
+	}
+	if generation.ThinkingBudget != nil {
+		lines = append(lines, fmt.Sprintf("pensamento: %d", *generation.ThinkingBudget))
+	}
+	if len(generation.StopSequences) > 0 {
+		lines = append(lines, fmt.Sprintf("parada: %s", strings.Join(generation.StopSequences, " | ")))
+	}
+	if generation.ResponseMIMEType != "" {
+		lines = append(lines, fmt.Sprintf("formato: %s", generation.ResponseMIMEType))
+	}
+	if len(lines) == 0 {
+		return "padrão do provedor"
+	}
+	return strings.Join(lines, ", ")
+}
+
+// handlePersonaCommand mostra e altera os parâmetros de geração das personas (só administradores)
+// Uso: !persona | !persona humor | !persona humor temperatura 0.9 | !persona humor padrao
+func (ch *CommandHandler) handlePersonaCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
+	if !bot.requireAdmin(ctx, evt) {
+		return nil
+	}
+
+	names := make([]string, 0, len(defaultPersonas))
+	for persona := range defaultPersonas {
+		names = append(names, string(persona))
+	}
+	sort.Strings(names)
+
+	// Sem argumentos: listar todas as personas
+	if len(args) == 0 {
+		var text strings.Builder
+		text.WriteString("🎭 *Personas da IA*\n")
+		for _, name := range names {
+			persona := Persona(name)
+			fmt.Fprintf(&text, "\n*%s* (%s)\n%s\n", name, personaCommands[persona], formatGeneration(personas.Generation(persona)))
+		}
+		text.WriteString("\nAlterar: !persona <nome> <parâmetro> <valor>\nParâmetros: temperatura, topp, tokens, pensamento, parada (separe com |), formato\nUse \"padrao\" como valor para voltar ao padrão, ou !persona <nome> padrao para descartar todos os parâmetros alterados (os filtros de segurança continuam).")
+		return ch.sendReplyMessage(ctx, text.String(), nil, evt, bot)
+	}
+
+	persona := Persona(strings.ToLower(args[0]))
+	if _, ok := defaultPersonas[persona]; !ok {
+		return ch.sendReplyMessage(ctx, fmt.Sprintf("❌ Persona desconhecida: %s\nPersonas: %s", args[0], strings.Join(names, ", ")), nil, evt, bot)
+	}
+
+	switch {
+	case len(args) == 1:
+		text := fmt.Sprintf("🎭 *%s* (%s)\n%s", persona, personaCommands[persona], formatGeneration(personas.Generation(persona)))
+		return ch.sendReplyMessage(ctx, text, nil, evt, bot)
+
+	case len(args) == 2 && (strings.EqualFold(args[1], "padrao") || strings.EqualFold(args[1], "padrão")):
+		personas.ResetGeneration(persona)
+		log.Info().Str("persona", string(persona)).Str("by", evt.Info.Sender.String()).Msg("Persona voltou ao padrão")
+		return ch.sendReplyMessage(ctx, fmt.Sprintf("✅ Persona *%s* voltou ao padrão:\n%s", persona, formatGeneration(personas.Generation(persona))), nil, evt, bot)
+
+	case len(args) >= 3:
+		key := strings.ToLower(args[1])
+		value := strings.Join(args[2:], " ")
+		err := personas.SetGeneration(persona, key, value)
+		if err != nil {
+			return ch.sendReplyMessage(ctx, fmt.Sprintf("❌ %s", err), nil, evt, bot)
+		}
+		log.Info().
+			Str("persona", string(persona)).
+			Str("key", key).
+			Str("value", value).
+			Str("by", evt.Info.Sender.String()).
+			Msg("Parâmetro de geração da persona alterado")
+		return ch.sendReplyMessage(ctx, fmt.Sprintf("✅ Persona *%s* atualizada:\n%s", persona, formatGeneration(personas.Generation(persona))), nil, evt, bot)
+
+	default:
+		return ch.sendReplyMessage(ctx, "❌ Use: !persona <nome> <parâmetro> <valor>\nExemplo: !persona humor temperatura 1.1", nil, evt, bot)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPersonaRegistryResetGeneration(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		wantSafety string // Limite esperado para assédio depois do reset (vazio: volta ao padrão)
+		wantSaved  bool   // A persona continua no arquivo depois do reset
+	}{
+		{
+			name:       "mantém os filtros de segurança do arquivo",
+			file:       `{"humor": {"safety": {"HARM_CATEGORY_HARASSMENT": "BLOCK_LOW_AND_ABOVE"}, "generation": {"temperature": 1.5}}}`,
+			wantSafety: safetyBlockLow,
+			wantSaved:  true,
+		},
+		{
+			name: "sem filtros a persona sai do arquivo",
+			file: `{"humor": {"generation": {"temperature": 1.5, "max_output_tokens": 100}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), personasFile)
+			if err := os.WriteFile(path, []byte(tt.file), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			registry := NewPersonaRegistry()
+			if err := registry.LoadFile(path); err != nil {
+				t.Fatal(err)
+			}
+			if err := registry.SetGeneration(personaHumor, "tokens", "300"); err != nil {
+				t.Fatal(err)
+			}
+
+			registry.ResetGeneration(personaHumor)
+
+			if got := registry.Generation(personaHumor); got.Temperature != defaultPersonas[personaHumor].Generation.Temperature || got.MaxOutputTokens != defaultPersonas[personaHumor].Generation.MaxOutputTokens {
+				t.Errorf("Generation() = %s, esperava o padrão", formatGeneration(got))
+			}
+
+			wantSafety := tt.wantSafety
+			if wantSafety == "" {
+				wantSafety = defaultPersonas[personaHumor].Safety[harmHarassment]
+			}
+			if got := registry.Settings(personaHumor).Safety[harmHarassment]; got != wantSafety {
+				t.Errorf("filtro de assédio = %q, esperava %q", got, wantSafety)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var saved map[Persona]PersonaSettings
+			if err := json.Unmarshal(data, &saved); err != nil {
+				t.Fatal(err)
+			}
+			settings, ok := saved[personaHumor]
+			if ok != tt.wantSaved {
+				t.Fatalf("persona no arquivo = %v, esperava %v: %s", ok, tt.wantSaved, data)
+			}
+			if ok && settings.Generation.Temperature != nil {
+				t.Errorf("arquivo ainda tem parâmetros de geração: %s", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,839 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// reminderCheckInterval é o intervalo em que o agendador procura lembretes vencidos
+const reminderCheckInterval = 15 * time.Second
+
+// reminderLateThreshold é o atraso a partir do qual o lembrete é marcado como enviado com atraso
+// (ex: o bot estava desligado no horário)
+const reminderLateThreshold = 5 * time.Minute
+
+// reminderMaxActive limita os lembretes ativos de cada pessoa por conversa
+const reminderMaxActive = 10
+
+// Novas tentativas quando o envio de um lembrete falha: a espera dobra a cada falha (30s, 1min, 2min, 4min)
+// e, depois de reminderMaxAttempts falhas, o lembrete é marcado como falho
+const (
+	reminderMaxAttempts    = 5
+	reminderRetryBaseDelay = 30 * time.Second
+)
+
+// reminderMaxDuration é o maior tempo relativo aceito ("em 365 dias"); quantidades maiores são rejeitadas
+const reminderMaxDuration = 365 * 24 * time.Hour
+
+// reminderDefaultHour é a hora usada quando o usuário informa o dia sem horário ("amanhã pagar boleto")
+const reminderDefaultHour = 9
+
+// Recorrências aceitas pelos lembretes
+const (
+	reminderOnce   = ""
+	reminderDaily  = "diario"
+	reminderWeekly = "semanal"
+)
+
+// Reminder representa um lembrete agendado em uma conversa
+type Reminder struct {
+	ID         int64
+	ChatJID    string
+	CreatorJID string
+	Text       string
+	RemindAt   time.Time
+	Recurrence string // reminderOnce, reminderDaily ou reminderWeekly
+	Attempts   int    // Envios que falharam na ocorrência atual
+}
+
+// Expressões usadas na interpretação de datas e horários em português
+var (
+	reminderClockRe    = regexp.MustCompile(`^(\d{1,2})(?:h|hs|:)?(\d{2})?(?:h|hs)?$`)
+	reminderDateRe     = regexp.MustCompile(`^(\d{1,2})/(\d{1,2})(?:/(\d{2}|\d{4}))?$`)
+	reminderDurationRe = regexp.MustCompile(`^(\d+)(min|mins|m|h|hs|d)?$`)
+)
+
+// reminderWeekdays associa os nomes dos dias (sem acento, sem "-feira" e no singular) ao dia da semana
+var reminderWeekdays = map[string]time.Weekday{
+	"domingo": time.Sunday,
+	"segunda": time.Monday,
+	"terca":   time.Tuesday,
+	"quarta":  time.Wednesday,
+	"quinta":  time.Thursday,
+	"sexta":   time.Friday,
+	"sabado":  time.Saturday,
+}
+
+// reminderWeekdayAbbrevs são as abreviações dos dias, aceitas só com ponto ("ter.") ou seguidas de um horário
+// ("ter 9h"), já que várias são palavras comuns ("ter", "sex", "qua", "seg")
+var reminderWeekdayAbbrevs = map[string]time.Weekday{
+	"dom": time.Sunday, "seg": time.Monday, "ter": time.Tuesday, "qua": time.Wednesday,
+	"qui": time.Thursday, "sex": time.Friday, "sab": time.Saturday,
+}
+
+// weekdayShortNames são as abreviações dos dias da semana exibidas nos lembretes
+var weekdayShortNames = [...]string{"dom", "seg", "ter", "qua", "qui", "sex", "sáb"}
+
+// reminderUnits associa as unidades de tempo aceitas em "em 30 minutos" à duração
+var reminderUnits = map[string]time.Duration{
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minuto": time.Minute, "minutos": time.Minute,
+	"h": time.Hour, "hs": time.Hour, "hora": time.Hour, "horas": time.Hour,
+	"d": 24 * time.Hour, "dia": 24 * time.Hour, "dias": 24 * time.Hour,
+	"semana": 7 * 24 * time.Hour, "semanas": 7 * 24 * time.Hour,
+}
+
+// remindersTableSchema é o esquema da tabela de lembretes
+// remind_at e retry_at são guardados em segundos Unix para permitir comparações diretas no SQL
+const remindersTableSchema = `
+		CREATE TABLE IF NOT EXISTS reminders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_jid TEXT NOT NULL,
+			creator_jid TEXT NOT NULL,
+			reminder_text TEXT NOT NULL,
+			remind_at INTEGER NOT NULL,
+			recurrence TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'done', 'cancelled', 'failed')),
+			attempts INTEGER NOT NULL DEFAULT 0,
+			retry_at INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+	`
+
+// initReminderTables cria a tabela de lembretes
+func (c *ChatContext) initReminderTables() error {
+	_, err := c.db.Exec(remindersTableSchema)
+	if err != nil {
+		return fmt.Errorf("erro ao criar tabela reminders: %w", err)
+	}
+
+	createRemindersIndexQuery := `
+		CREATE INDEX IF NOT EXISTS idx_reminders_status_time
+		ON reminders (status, remind_at);
+	`
+
+	_, err = c.db.Exec(createRemindersIndexQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar índice reminders: %w", err)
+	}
+
+	return nil
+}
+
+// SaveReminder agenda um novo lembrete e retorna seu ID
+func (c *ChatContext) SaveReminder(ctx context.Context, reminder Reminder) (int64, error) {
+	query := `
+		INSERT INTO reminders (chat_jid, creator_jid, reminder_text, remind_at, recurrence, status, created_at)
+		VALUES (?, ?, ?, ?, ?, 'active', ?)
+	`
+
+	result, err := c.db.ExecContext(ctx, query, reminder.ChatJID, reminder.CreatorJID, reminder.Text,
+		reminder.RemindAt.Unix(), reminder.Recurrence, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("erro ao salvar lembrete: %w", err)
+	}
+
+	reminderID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("erro ao obter ID do lembrete: %w", err)
+	}
+
+	return reminderID, nil
+}
+
+// CountActiveReminders conta os lembretes ativos de uma pessoa em uma conversa
+func (c *ChatContext) CountActiveReminders(ctx context.Context, chatJID, creatorJID string) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM reminders
+		WHERE chat_jid = ? AND creator_jid = ? AND status = 'active'
+	`
+
+	var count int
+	err := c.db.QueryRowContext(ctx, query, chatJID, creatorJID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao contar lembretes: %w", err)
+	}
+
+	return count, nil
+}
+
+// LoadChatReminders carrega os lembretes ativos de uma conversa, do mais próximo ao mais distante
+func (c *ChatContext) LoadChatReminders(ctx context.Context, chatJID string) ([]Reminder, error) {
+	query := `
+		SELECT id, chat_jid, creator_jid, reminder_text, remind_at, recurrence, attempts
+		FROM reminders
+		WHERE chat_jid = ? AND status = 'active'
+		ORDER BY remind_at ASC, id ASC
+	`
+
+	return c.queryReminders(ctx, query, chatJID)
+}
+
+// LoadDueReminders carrega os lembretes ativos cujo horário já chegou
+// Lembretes cujo envio falhou só voltam quando chega o horário da próxima tentativa
+func (c *ChatContext) LoadDueReminders(ctx context.Context, now time.Time) ([]Reminder, error) {
+	query := `
+		SELECT id, chat_jid, creator_jid, reminder_text, remind_at, recurrence, attempts
+		FROM reminders
+		WHERE status = 'active' AND remind_at <= ? AND retry_at <= ?
+		ORDER BY remind_at ASC, id ASC
+	`
+
+	return c.queryReminders(ctx, query, now.Unix(), now.Unix())
+}
+
+// queryReminders executa uma consulta de lembretes e converte as linhas
+func (c *ChatContext) queryReminders(ctx context.Context, query string, args ...any) ([]Reminder, error) {
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao carregar lembretes: %w", err)
+	}
+	defer rows.Close()
+
+	var reminders []Reminder
+	for rows.Next() {
+		var (
+			reminder Reminder
+			remindAt int64
+		)
+		err := rows.Scan(&reminder.ID, &reminder.ChatJID, &reminder.CreatorJID, &reminder.Text, &remindAt, &reminder.Recurrence, &reminder.Attempts)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler lembrete: %w", err)
+		}
+		reminder.RemindAt = time.Unix(remindAt, 0).In(botLocation)
+		reminders = append(reminders, reminder)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao iterar lembretes: %w", err)
+	}
+
+	return reminders, nil
+}
+
+// CompleteReminder marca um lembrete como enviado
+// Lembretes recorrentes são reagendados para a próxima ocorrência depois de now
+func (c *ChatContext) CompleteReminder(ctx context.Context, reminder Reminder, now time.Time) error {
+	var err error
+	if reminder.Recurrence == reminderOnce {
+		_, err = c.db.ExecContext(ctx, `UPDATE reminders SET status = 'done' WHERE id = ?`, reminder.ID)
+	} else {
+		next := nextReminderOccurrence(reminder.RemindAt, reminder.Recurrence, now)
+		_, err = c.db.ExecContext(ctx, `UPDATE reminders SET remind_at = ?, attempts = 0, retry_at = 0 WHERE id = ?`, next.Unix(), reminder.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar lembrete: %w", err)
+	}
+	return nil
+}
+
+// RetryReminder registra uma falha no envio de um lembrete e agenda a próxima tentativa
+// Depois de reminderMaxAttempts falhas o lembrete único é marcado como falho e o recorrente pula para a
+// próxima ocorrência. Retorna true quando o bot desistiu de enviar a ocorrência
+func (c *ChatContext) RetryReminder(ctx context.Context, reminder Reminder, now time.Time) (bool, error) {
+	attempts := reminder.Attempts + 1
+	if attempts >= reminderMaxAttempts {
+		if reminder.Recurrence != reminderOnce {
+			return true, c.CompleteReminder(ctx, reminder, now)
+		}
+		_, err := c.db.ExecContext(ctx, `UPDATE reminders SET status = 'failed', attempts = ? WHERE id = ?`, attempts, reminder.ID)
+		if err != nil {
+			return false, fmt.Errorf("erro ao marcar lembrete como falho: %w", err)
+		}
+		return true, nil
+	}
+
+	retryAt := now.Add(reminderRetryBaseDelay << (attempts - 1))
+	_, err := c.db.ExecContext(ctx, `UPDATE reminders SET attempts = ?, retry_at = ? WHERE id = ?`, attempts, retryAt.Unix(), reminder.ID)
+	if err != nil {
+		return false, fmt.Errorf("erro ao agendar nova tentativa do lembrete: %w", err)
+	}
+	return false, nil
+}
+
+// CancelReminder cancela um lembrete ativo da conversa criado pela pessoa
+// Retorna false quando não há lembrete com esse ID (ou ele pertence a outra pessoa)
+func (c *ChatContext) CancelReminder(ctx context.Context, reminderID int64, chatJID, creatorJID string) (bool, error) {
+	query := `
+		UPDATE reminders SET status = 'cancelled'
+		WHERE id = ? AND chat_jid = ? AND creator_jid = ? AND status = 'active'
+	`
+
+	result, err := c.db.ExecContext(ctx, query, reminderID, chatJID, creatorJID)
+	if err != nil {
+		return false, fmt.Errorf("erro ao cancelar lembrete: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("erro ao verificar cancelamento do lembrete: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// nextReminderOccurrence calcula a próxima ocorrência de um lembrete recorrente depois de now
+// Mantém o horário do relógio (usa datas do calendário em vez de somar 24h)
+func nextReminderOccurrence(at time.Time, recurrence string, now time.Time) time.Time {
+	days := 1
+	if recurrence == reminderWeekly {
+		days = 7
+	}
+
+	at = at.In(botLocation)
+	for !at.After(now) {
+		at = at.AddDate(0, 0, days)
+	}
+	return at
+}
+
+// parseReminderWeekday interpreta o nome de um dia da semana ("segunda", "sexta-feira", "sabados")
+// As abreviações ("seg", "sex") só são aceitas quando abbrev é true
+func parseReminderWeekday(word string, abbrev bool) (time.Weekday, bool) {
+	word = strings.TrimSuffix(strings.TrimSuffix(word, "s"), "-feira")
+	word = strings.TrimSuffix(word, "s")
+	if weekday, ok := reminderWeekdays[word]; ok {
+		return weekday, true
+	}
+	if !abbrev {
+		return 0, false
+	}
+	weekday, ok := reminderWeekdayAbbrevs[word]
+	return weekday, ok
+}
+
+// parseReminderClock interpreta um horário no início das palavras ("9h", "9h30", "09:30", "meio-dia")
+// Um número sozinho ("9") só é aceito quando bare é true (depois de "às")
+// Retorna hora, minuto e quantas palavras foram consumidas
+func parseReminderClock(words []string, bare bool) (int, int, int, bool) {
+	if len(words) == 0 {
+		return 0, 0, 0, false
+	}
+
+	switch {
+	case words[0] == "meio-dia":
+		return 12, 0, 1, true
+	case words[0] == "meia-noite":
+		return 0, 0, 1, true
+	case len(words) > 1 && words[0] == "meio" && words[1] == "dia":
+		return 12, 0, 2, true
+	case len(words) > 1 && words[0] == "meia" && words[1] == "noite":
+		return 0, 0, 2, true
+	}
+
+	m := reminderClockRe.FindStringSubmatch(words[0])
+	if m == nil {
+		return 0, 0, 0, false
+	}
+
+	consumed := 1
+	isBare := !strings.ContainsAny(words[0], "h:")
+	if isBare && len(words) > 1 && (words[1] == "h" || words[1] == "hora" || words[1] == "horas") {
+		// "às 9 horas"
+		consumed, isBare = 2, false
+	}
+	if isBare && !bare {
+		return 0, 0, 0, false
+	}
+
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	if hour > 23 || minute > 59 {
+		return 0, 0, 0, false
+	}
+
+	// "9h da noite", "3h da tarde", "7h da manhã"; "12h da noite" é meia-noite
+	if len(words) > consumed+1 && words[consumed] == "da" {
+		switch words[consumed+1] {
+		case "tarde", "noite":
+			if hour < 12 {
+				hour += 12
+			} else if hour == 12 && words[consumed+1] == "noite" {
+				hour = 0
+			}
+			consumed += 2
+		case "manha", "madrugada":
+			consumed += 2
+		}
+	}
+
+	return hour, minute, consumed, true
+}
+
+// parseReminderDuration interpreta uma duração relativa ("30 minutos", "2h", "uma hora", "meia hora")
+// Retorna a duração e quantas palavras foram consumidas; durações acima de reminderMaxDuration são rejeitadas
+func parseReminderDuration(words []string) (time.Duration, int, bool) {
+	if len(words) == 0 {
+		return 0, 0, false
+	}
+
+	if len(words) > 1 && words[0] == "meia" && words[1] == "hora" {
+		return 30 * time.Minute, 2, true
+	}
+
+	amount := 0
+	unit := ""
+	switch words[0] {
+	case "um", "uma":
+		amount = 1
+	default:
+		m := reminderDurationRe.FindStringSubmatch(words[0])
+		if m == nil {
+			return 0, 0, false
+		}
+		var err error
+		amount, err = strconv.Atoi(m[1])
+		if err != nil {
+			return 0, 0, false
+		}
+		unit = m[2]
+	}
+
+	consumed := 1
+	if unit == "" {
+		if len(words) < 2 {
+			return 0, 0, false
+		}
+		unit = words[1]
+		consumed = 2
+	}
+
+	duration, ok := reminderUnits[unit]
+	// Comparar a quantidade antes de multiplicar evita o estouro de "99999999999999999 dias"
+	if !ok || amount <= 0 || amount > int(reminderMaxDuration/duration) {
+		return 0, 0, false
+	}
+	return time.Duration(amount) * duration, consumed, true
+}
+
+// parseReminderDate interpreta uma data "dd/mm" ou "dd/mm/aaaa"
+// Sem o ano, usa a próxima ocorrência da data a partir de hoje
+func parseReminderDate(word string, now time.Time) (time.Time, bool) {
+	m := reminderDateRe.FindStringSubmatch(word)
+	if m == nil {
+		return time.Time{}, false
+	}
+
+	day, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	year := now.Year()
+	if m[3] != "" {
+		year, _ = strconv.Atoi(m[3])
+		if year < 100 {
+			year += 2000
+		}
+	}
+
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, botLocation)
+	// Datas inválidas (31/02) são normalizadas pelo time.Date; rejeitar
+	if date.Day() != day || int(date.Month()) != month {
+		return time.Time{}, false
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, botLocation)
+	if m[3] == "" && date.Before(today) {
+		date = date.AddDate(1, 0, 0)
+	}
+	return date, true
+}
+
+// parseReminderWhen interpreta o início de "!lembrete <quando> <texto>"
+// Aceita expressões como "amanhã 9h", "em 30 minutos", "daqui a 2 horas", "hoje às 18h30",
+// "sexta 14h", "25/12 10h", "todo dia 8h" e "toda segunda 8h"
+// Retorna o horário do primeiro envio, a recorrência e o texto do lembrete
+func parseReminderWhen(args []string, now time.Time) (time.Time, string, string, error) {
+	now = now.In(botLocation)
+
+	words := make([]string, len(args))
+	for i, arg := range args {
+		words[i] = strings.Trim(accentReplacer.Replace(strings.ToLower(arg)), ",;.")
+	}
+	at := func(k int) string {
+		if k < len(words) {
+			return words[k]
+		}
+		return ""
+	}
+	// clockAt informa se há um horário a partir da palavra k ("9h", "às 9", "ao meio-dia")
+	clockAt := func(k int) bool {
+		bare := false
+		if at(k) == "as" || at(k) == "a" || at(k) == "ao" {
+			k, bare = k+1, true
+		}
+		_, _, _, ok := parseReminderClock(words[min(k, len(words)):], bare)
+		return ok
+	}
+	// weekdayAt interpreta o dia da semana da palavra k; abreviações só com ponto ou seguidas de horário
+	weekdayAt := func(k int) (time.Weekday, bool) {
+		dotted := k < len(args) && strings.HasSuffix(strings.TrimRight(args[k], ",;"), ".")
+		return parseReminderWeekday(at(k), dotted || clockAt(k+1))
+	}
+
+	i := 0
+	recurrence := reminderOnce
+	var (
+		day        time.Time // Dia escolhido (zero quando não informado)
+		weekday    time.Weekday
+		hasWeekday bool
+	)
+
+	// Recorrência: "todo dia", "todos os dias", "diariamente", "toda segunda", "todas as sextas"
+	switch {
+	case at(0) == "diariamente":
+		recurrence, i = reminderDaily, 1
+	case at(0) == "todo" && at(1) == "dia":
+		recurrence, i = reminderDaily, 2
+	case at(0) == "todos" && at(1) == "os" && at(2) == "dias":
+		recurrence, i = reminderDaily, 3
+	case at(0) == "toda" || at(0) == "todo":
+		if wd, ok := weekdayAt(1); ok {
+			recurrence, weekday, hasWeekday, i = reminderWeekly, wd, true, 2
+		}
+	case (at(0) == "todas" && at(1) == "as") || (at(0) == "todos" && at(1) == "os"):
+		if wd, ok := weekdayAt(2); ok {
+			recurrence, weekday, hasWeekday, i = reminderWeekly, wd, true, 3
+		}
+	}
+
+	if recurrence == reminderOnce {
+		// Tempo relativo: "em 30 minutos", "daqui a 2 horas", "daqui 1 hora"
+		if at(0) == "em" || at(0) == "daqui" {
+			start := 1
+			if at(0) == "daqui" && at(1) == "a" {
+				start = 2
+			}
+			if duration, consumed, ok := parseReminderDuration(words[min(start, len(words)):]); ok {
+				text := reminderText(args[start+consumed:])
+				if text == "" {
+					return time.Time{}, "", "", fmt.Errorf("faltou o texto do lembrete")
+				}
+				return now.Add(duration).Truncate(time.Second), reminderOnce, text, nil
+			}
+		}
+
+		// Dia: "hoje", "amanhã", "depois de amanhã", "sexta", "na próxima segunda", "25/12"
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, botLocation)
+		switch {
+		case at(0) == "hoje":
+			day, i = today, 1
+		case at(0) == "amanha":
+			day, i = today.AddDate(0, 0, 1), 1
+		case at(0) == "depois" && at(1) == "de" && at(2) == "amanha":
+			day, i = today.AddDate(0, 0, 2), 3
+		default:
+			start := 0
+			for start < 2 && (at(start) == "na" || at(start) == "no" || at(start) == "proxima" || at(start) == "proximo") {
+				start++
+			}
+			if wd, ok := weekdayAt(start); ok {
+				weekday, hasWeekday, i = wd, true, start+1
+			} else if date, ok := parseReminderDate(at(0), now); ok {
+				day, i = date, 1
+			}
+		}
+	}
+
+	// Horário: "9h", "às 9h30", "as 18:00", "às 9", "ao meio-dia", "8h da noite"
+	hour, minute, hasClock := reminderDefaultHour, 0, false
+	clockStart := i
+	bare := false
+	if at(i) == "as" || at(i) == "a" || at(i) == "ao" {
+		clockStart, bare = i+1, true
+	}
+	if h, m, consumed, ok := parseReminderClock(words[min(clockStart, len(words)):], bare); ok {
+		hour, minute, hasClock = h, m, true
+		i = clockStart + consumed
+	}
+
+	if i == 0 {
+		return time.Time{}, "", "", fmt.Errorf("não entendi quando devo lembrar")
+	}
+
+	var remindAt time.Time
+	switch {
+	case hasWeekday:
+		// Próximo dia da semana pedido (hoje, se o horário ainda não passou)
+		daysAhead := (int(weekday) - int(now.Weekday()) + 7) % 7
+		remindAt = time.Date(now.Year(), now.Month(), now.Day()+daysAhead, hour, minute, 0, 0, botLocation)
+		if !remindAt.After(now) {
+			remindAt = remindAt.AddDate(0, 0, 7)
+		}
+	case !day.IsZero():
+		remindAt = time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, botLocation)
+	default:
+		// Só o horário (ou "todo dia"): hoje, ou amanhã se o horário já passou
+		if !hasClock && recurrence == reminderOnce {
+			return time.Time{}, "", "", fmt.Errorf("não entendi quando devo lembrar")
+		}
+		remindAt = time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, botLocation)
+		if !remindAt.After(now) {
+			remindAt = remindAt.AddDate(0, 0, 1)
+		}
+	}
+
+	if !remindAt.After(now) {
+		return time.Time{}, "", "", fmt.Errorf("esse horário já passou")
+	}
+
+	text := reminderText(args[i:])
+	if text == "" {
+		return time.Time{}, "", "", fmt.Errorf("faltou o texto do lembrete")
+	}
+
+	return remindAt, recurrence, text, nil
+}
+
+// reminderText monta o texto do lembrete, removendo conectivos iniciais ("de", "para", "que")
+func reminderText(args []string) string {
+	if len(args) > 0 {
+		switch accentReplacer.Replace(strings.ToLower(args[0])) {
+		case "de", "da", "do", "para", "pra", "que", "-", ":":
+			args = args[1:]
+		}
+	}
+	return strings.TrimSpace(strings.Join(args, " "))
+}
+
+// formatReminderTime formata o horário de um lembrete ("sex 18/10 às 09:00")
+func formatReminderTime(at time.Time) string {
+	at = at.In(botLocation)
+	return fmt.Sprintf("%s %s às %s", weekdayShortNames[at.Weekday()], at.Format("02/01"), at.Format("15:04"))
+}
+
+// formatReminderRecurrence descreve a recorrência de um lembrete ("todo dia", "toda segunda-feira")
+func formatReminderRecurrence(reminder Reminder) string {
+	switch reminder.Recurrence {
+	case reminderDaily:
+		return "todo dia às " + reminder.RemindAt.In(botLocation).Format("15:04")
+	case reminderWeekly:
+		weekday := reminder.RemindAt.In(botLocation).Weekday()
+		prefix := "toda"
+		if weekday == time.Saturday || weekday == time.Sunday {
+			prefix = "todo"
+		}
+		return fmt.Sprintf("%s %s às %s", prefix, weekdayNames[weekday], reminder.RemindAt.In(botLocation).Format("15:04"))
+	}
+	return ""
+}
+
+// handleLembreteCommand processa "!lembrete <quando> <texto>"
+func (ch *CommandHandler) handleLembreteCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
+	usage := "❌ Use: *!lembrete <quando> <texto>*\n\n_Exemplos:_\n• !lembrete amanhã 9h pagar o boleto\n• !lembrete em 30 minutos tirar o bolo do forno\n• !lembrete sexta às 18h happy hour\n• !lembrete 25/12 10h ligar pra vó\n• !lembrete todo dia 8h tomar remédio\n• !lembrete toda segunda 8h reunião"
+	if len(args) == 0 {
+		return ch.sendReplyMessage(ctx, usage, nil, evt, bot)
+	}
+
+	remindAt, recurrence, text, err := parseReminderWhen(args, time.Now())
+	if err != nil {
+		return ch.sendReplyMessage(ctx, fmt.Sprintf("❌ Não consegui agendar: %s.\n\n%s", err, strings.TrimPrefix(usage, "❌ ")), nil, evt, bot)
+	}
+
+	chatJID := evt.Info.Chat.String()
+	creatorJID := evt.Info.Sender.ToNonAD().String()
+
+	count, err := bot.chatContext.CountActiveReminders(ctx, chatJID, creatorJID)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao contar lembretes")
+		return ch.sendReplyMessage(ctx, "❌ Erro ao agendar o lembrete. Tente novamente mais tarde.", nil, evt, bot)
+	}
+	if count >= reminderMaxActive {
+		return ch.sendReplyMessage(ctx, fmt.Sprintf("❌ Você já tem %d lembretes ativos nesta conversa. Cancele algum com *!cancelarlembrete <número>*.", count), nil, evt, bot)
+	}
+
+	reminder := Reminder{
+		ChatJID:    chatJID,
+		CreatorJID: creatorJID,
+		Text:       text,
+		RemindAt:   remindAt,
+		Recurrence: recurrence,
+	}
+	reminder.ID, err = bot.chatContext.SaveReminder(ctx, reminder)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao salvar lembrete")
+		return ch.sendReplyMessage(ctx, "❌ Erro ao agendar o lembrete. Tente novamente mais tarde.", nil, evt, bot)
+	}
+
+	log.Info().
+		Int64("id", reminder.ID).
+		Str("chat", chatJID).
+		Str("creator", creatorJID).
+		Time("remindAt", remindAt).
+		Str("recurrence", recurrence).
+		Msg("Lembrete agendado")
+
+	confirmation := fmt.Sprintf("⏰ Lembrete *#%d* agendado para %s:\n%s", reminder.ID, formatReminderTime(remindAt), text)
+	if recurrence != reminderOnce {
+		confirmation += fmt.Sprintf("\n\n🔁 Repete %s", formatReminderRecurrence(reminder))
+	}
+	confirmation += "\n\n_Para cancelar: !cancelarlembrete " + strconv.FormatInt(reminder.ID, 10) + "_"
+
+	return ch.sendReplyMessage(ctx, confirmation, nil, evt, bot)
+}
+
+// handleLembretesCommand processa "!lembretes" listando os lembretes ativos da conversa
+func (ch *CommandHandler) handleLembretesCommand(ctx context.Context, evt *events.Message, bot *BotClient) error {
+	reminders, err := bot.chatContext.LoadChatReminders(ctx, evt.Info.Chat.String())
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao carregar lembretes")
+		return ch.sendReplyMessage(ctx, "❌ Erro ao carregar os lembretes. Tente novamente mais tarde.", nil, evt, bot)
+	}
+	if len(reminders) == 0 {
+		return ch.sendReplyMessage(ctx, "📭 Nenhum lembrete agendado nesta conversa.\nUse *!lembrete <quando> <texto>* para criar um.", nil, evt, bot)
+	}
+
+	var list strings.Builder
+	list.WriteString("⏰ *Lembretes agendados:*\n")
+
+	var mentions []string
+	seen := make(map[string]bool)
+	for _, reminder := range reminders {
+		creator, err := types.ParseJID(reminder.CreatorJID)
+		if err != nil {
+			continue
+		}
+		list.WriteString(fmt.Sprintf("\n*#%d* - %s\n%s\n_por @%s", reminder.ID, formatReminderTime(reminder.RemindAt), reminder.Text, creator.User))
+		if recurrence := formatReminderRecurrence(reminder); recurrence != "" {
+			list.WriteString(" • 🔁 " + recurrence)
+		}
+		list.WriteString("_\n")
+
+		if !seen[reminder.CreatorJID] {
+			seen[reminder.CreatorJID] = true
+			mentions = append(mentions, reminder.CreatorJID)
+		}
+	}
+	list.WriteString("\n_Para cancelar: !cancelarlembrete <número>_")
+
+	_, err = bot.chunker.Send(ctx, evt.Info.Chat, evt.Info.Sender, list.String(), &waProto.ContextInfo{MentionedJID: mentions})
+	return err
+}
+
+// handleCancelarLembreteCommand processa "!cancelarlembrete <número>"
+// Só quem criou o lembrete pode cancelá-lo
+func (ch *CommandHandler) handleCancelarLembreteCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
+	if len(args) == 0 {
+		return ch.sendReplyMessage(ctx, "❌ Use: *!cancelarlembrete <número>* (veja os números com *!lembretes*)", nil, evt, bot)
+	}
+
+	reminderID, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil || reminderID <= 0 {
+		return ch.sendReplyMessage(ctx, "❌ Número de lembrete inválido. Veja os números com *!lembretes*.", nil, evt, bot)
+	}
+
+	cancelled, err := bot.chatContext.CancelReminder(ctx, reminderID, evt.Info.Chat.String(), evt.Info.Sender.ToNonAD().String())
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao cancelar lembrete")
+		return ch.sendReplyMessage(ctx, "❌ Erro ao cancelar o lembrete. Tente novamente mais tarde.", nil, evt, bot)
+	}
+	if !cancelled {
+		return ch.sendReplyMessage(ctx, fmt.Sprintf("❌ Lembrete #%d não encontrado (ou não foi você quem criou).", reminderID), nil, evt, bot)
+	}
+
+	log.Info().Int64("id", reminderID).Str("chat", evt.Info.Chat.String()).Msg("Lembrete cancelado")
+	return ch.sendReplyMessage(ctx, fmt.Sprintf("🗑️ Lembrete #%d cancelado.", reminderID), nil, evt, bot)
+}
+
+// runReminderScheduler envia os lembretes vencidos até o contexto ser cancelado
+// Os lembretes ficam no SQLite, então os que venceram com o bot desligado são enviados ao reiniciar
+func (bot *BotClient) runReminderScheduler(ctx context.Context) {
+	ticker := time.NewTicker(reminderCheckInterval)
+	defer ticker.Stop()
+
+	log.Info().Dur("interval", reminderCheckInterval).Msg("Agendador de lembretes iniciado")
+
+	for {
+		bot.sendDueReminders(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDueReminders envia os lembretes cujo horário já chegou
+func (bot *BotClient) sendDueReminders(ctx context.Context) {
+	if !bot.WAClient.IsLoggedIn() {
+		return
+	}
+
+	now := time.Now()
+	reminders, err := bot.chatContext.LoadDueReminders(ctx, now)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao carregar lembretes vencidos")
+		return
+	}
+
+	for _, reminder := range reminders {
+		err := bot.sendReminder(ctx, reminder, now)
+		if err != nil {
+			log.Error().Err(err).Int64("id", reminder.ID).Int("attempt", reminder.Attempts+1).Msg("Erro ao enviar lembrete")
+
+			// Tentar de novo mais tarde, com espera crescente, até desistir
+			gaveUp, retryErr := bot.chatContext.RetryReminder(ctx, reminder, now)
+			if retryErr != nil {
+				log.Error().Err(retryErr).Int64("id", reminder.ID).Msg("Erro ao registrar falha do lembrete")
+			} else if gaveUp {
+				log.Warn().Int64("id", reminder.ID).Str("chat", reminder.ChatJID).Int("attempts", reminderMaxAttempts).Msg("Lembrete não enviado após várias tentativas; desistindo")
+			}
+			continue
+		}
+
+		err = bot.chatContext.CompleteReminder(ctx, reminder, now)
+		if err != nil {
+			log.Error().Err(err).Int64("id", reminder.ID).Msg("Erro ao atualizar lembrete enviado")
+		}
+	}
+}
+
+// sendReminder envia um lembrete na conversa mencionando quem o criou
+func (bot *BotClient) sendReminder(ctx context.Context, reminder Reminder, now time.Time) error {
+	chat, err := types.ParseJID(reminder.ChatJID)
+	if err != nil {
+		return fmt.Errorf("erro ao interpretar chat do lembrete: %w", err)
+	}
+	creator, err := types.ParseJID(reminder.CreatorJID)
+	if err != nil {
+		return fmt.Errorf("erro ao interpretar criador do lembrete: %w", err)
+	}
+
+	text := fmt.Sprintf("⏰ *Lembrete* para @%s:\n%s", creator.User, reminder.Text)
+	if now.Sub(reminder.RemindAt) > reminderLateThreshold {
+		text += fmt.Sprintf("\n\n_(era para %s; enviado com atraso)_", formatReminderTime(reminder.RemindAt))
+	}
+	if recurrence := formatReminderRecurrence(reminder); recurrence != "" {
+		text += "\n\n_🔁 " + recurrence + " • !cancelarlembrete " + strconv.FormatInt(reminder.ID, 10) + " para parar_"
+	}
+
+	msg := &waProto.Message{
+		ExtendedTextMessage: &waProto.ExtendedTextMessage{
+			Text: &text,
+			ContextInfo: &waProto.ContextInfo{
+				MentionedJID: []string{creator.String()},
+			},
+		},
+	}
+
+	_, err = bot.WAClient.SendMessage(ctx, chat, msg)
+	if err != nil {
+		return fmt.Errorf("erro ao enviar lembrete: %w", err)
+	}
+
+	log.Info().
+		Int64("id", reminder.ID).
+		Str("chat", reminder.ChatJID).
+		Str("recurrence", reminder.Recurrence).
+		Msg("Lembrete enviado")
+	return nil
+}
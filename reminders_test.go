@@ -0,0 +1,220 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// reminderNow é o "agora" dos testes: quarta-feira, 14/10/2026 às 15:00
+var reminderNow = time.Date(2026, 10, 14, 15, 0, 0, 0, botLocation)
+
+// reminderAt monta um horário no fuso do bot
+func reminderAt(month time.Month, day, hour, minute int) time.Time {
+	return time.Date(2026, month, day, hour, minute, 0, 0, botLocation)
+}
+
+func TestParseReminderWhen(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		want           time.Time
+		wantRecurrence string
+		wantText       string
+		wantErr        bool
+	}{
+		{name: "minutos relativos", input: "em 30 minutos tomar água", want: reminderAt(10, 14, 15, 30), wantText: "tomar água"},
+		{name: "meia hora", input: "em meia hora ligar pro João", want: reminderAt(10, 14, 15, 30), wantText: "ligar pro João"},
+		{name: "daqui a", input: "daqui a 2 horas reunião", want: reminderAt(10, 14, 17, 0), wantText: "reunião"},
+		{name: "limite de um ano", input: "em 365 dias renovar", want: time.Date(2027, 10, 14, 15, 0, 0, 0, botLocation), wantText: "renovar"},
+		{name: "mais de um ano", input: "em 366 dias renovar", wantErr: true},
+		{name: "quantidade que estouraria a duração", input: "em 99999999999999999 dias x", wantErr: true},
+		{name: "amanhã com horário", input: "amanhã 9h pagar boleto", want: reminderAt(10, 15, 9, 0), wantText: "pagar boleto"},
+		{name: "amanhã sem horário usa o padrão", input: "amanhã de pagar boleto", want: reminderAt(10, 15, reminderDefaultHour, 0), wantText: "pagar boleto"},
+		{name: "hoje às", input: "hoje às 18h30 academia", want: reminderAt(10, 14, 18, 30), wantText: "academia"},
+		{name: "hoje em horário que já passou", input: "hoje 10h academia", wantErr: true},
+		{name: "só o horário que já passou vai para amanhã", input: "14h reunião", want: reminderAt(10, 15, 14, 0), wantText: "reunião"},
+		{name: "12h da noite é meia-noite", input: "12h da noite tomar remédio", want: reminderAt(10, 15, 0, 0), wantText: "tomar remédio"},
+		{name: "dia da semana", input: "sexta 14h dentista", want: reminderAt(10, 16, 14, 0), wantText: "dentista"},
+		{name: "mesmo dia da semana mais tarde", input: "quarta 16h futebol", want: reminderAt(10, 14, 16, 0), wantText: "futebol"},
+		{name: "mesmo dia da semana já passou vira a próxima", input: "quarta 10h futebol", want: reminderAt(10, 21, 10, 0), wantText: "futebol"},
+		{name: "próxima segunda com às", input: "na próxima segunda-feira às 8 reunião", want: reminderAt(10, 19, 8, 0), wantText: "reunião"},
+		{name: "abreviação seguida de horário", input: "sex 14h dentista", want: reminderAt(10, 16, 14, 0), wantText: "dentista"},
+		{name: "abreviação com ponto", input: "ter. levar o carro", want: reminderAt(10, 20, reminderDefaultHour, 0), wantText: "levar o carro"},
+		{name: "abreviação sem ponto nem horário é palavra comum", input: "ter que ligar pra mãe", wantErr: true},
+		{name: "data", input: "25/12 10h natal", want: reminderAt(12, 25, 10, 0), wantText: "natal"},
+		{name: "data que já passou vai para o próximo ano", input: "10/10 aniversário", want: time.Date(2027, 10, 10, reminderDefaultHour, 0, 0, 0, botLocation), wantText: "aniversário"},
+		{name: "data inexistente", input: "31/02 10h pagar", wantErr: true},
+		{name: "data com ano passado", input: "01/01/2020 10h pagar", wantErr: true},
+		{name: "todo dia", input: "todo dia 8h remédio", want: reminderAt(10, 15, 8, 0), wantRecurrence: reminderDaily, wantText: "remédio"},
+		{name: "todo dia ainda hoje", input: "todos os dias às 20h regar as plantas", want: reminderAt(10, 14, 20, 0), wantRecurrence: reminderDaily, wantText: "regar as plantas"},
+		{name: "toda semana", input: "toda segunda 8h reunião", want: reminderAt(10, 19, 8, 0), wantRecurrence: reminderWeekly, wantText: "reunião"},
+		{name: "todas as sextas", input: "todas as sextas 18h happy hour", want: reminderAt(10, 16, 18, 0), wantRecurrence: reminderWeekly, wantText: "happy hour"},
+		{name: "toda semana com abreviação e horário", input: "toda qua 9h planejamento", want: reminderAt(10, 21, 9, 0), wantRecurrence: reminderWeekly, wantText: "planejamento"},
+		{name: "sem texto", input: "amanhã 9h", wantErr: true},
+		{name: "sem quando", input: "comprar pão", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, recurrence, text, err := parseReminderWhen(strings.Fields(tt.input), reminderNow)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseReminderWhen(%q) = %s, esperava erro", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseReminderWhen(%q) erro inesperado: %v", tt.input, err)
+			}
+			if !got.Equal(tt.want) || recurrence != tt.wantRecurrence || text != tt.wantText {
+				t.Errorf("parseReminderWhen(%q) = %s, %q, %q; esperava %s, %q, %q", tt.input, got, recurrence, text, tt.want, tt.wantRecurrence, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestParseReminderClock(t *testing.T) {
+	tests := []struct {
+		input        string
+		bare         bool
+		wantHour     int
+		wantMinute   int
+		wantConsumed int // 0 quando não é um horário
+	}{
+		{input: "9h", wantHour: 9, wantConsumed: 1},
+		{input: "9h30", wantHour: 9, wantMinute: 30, wantConsumed: 1},
+		{input: "09:30", wantHour: 9, wantMinute: 30, wantConsumed: 1},
+		{input: "9", bare: false},
+		{input: "9", bare: true, wantHour: 9, wantConsumed: 1},
+		{input: "9 horas", wantHour: 9, wantConsumed: 2},
+		{input: "meio-dia", wantHour: 12, wantConsumed: 1},
+		{input: "meia noite", wantHour: 0, wantConsumed: 2},
+		{input: "8h da noite", wantHour: 20, wantConsumed: 3},
+		{input: "3h da tarde", wantHour: 15, wantConsumed: 3},
+		{input: "7h da manha", wantHour: 7, wantConsumed: 3},
+		{input: "12h da noite", wantHour: 0, wantConsumed: 3},
+		{input: "12h da tarde", wantHour: 12, wantConsumed: 3},
+		{input: "24h"},
+		{input: "9:60"},
+		{input: "amanha"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			hour, minute, consumed, ok := parseReminderClock(strings.Fields(tt.input), tt.bare)
+			if ok != (tt.wantConsumed > 0) || hour != tt.wantHour || minute != tt.wantMinute || consumed != tt.wantConsumed {
+				t.Errorf("parseReminderClock(%q, %v) = %d, %d, %d, %v; esperava %d, %d, %d", tt.input, tt.bare, hour, minute, consumed, ok, tt.wantHour, tt.wantMinute, tt.wantConsumed)
+			}
+		})
+	}
+}
+
+func TestParseReminderDuration(t *testing.T) {
+	tests := []struct {
+		input        string
+		want         time.Duration
+		wantConsumed int // 0 quando não é uma duração válida
+	}{
+		{input: "30 minutos", want: 30 * time.Minute, wantConsumed: 2},
+		{input: "2h", want: 2 * time.Hour, wantConsumed: 1},
+		{input: "uma hora", want: time.Hour, wantConsumed: 2},
+		{input: "meia hora", want: 30 * time.Minute, wantConsumed: 2},
+		{input: "3d", want: 3 * 24 * time.Hour, wantConsumed: 1},
+		{input: "52 semanas", want: 52 * 7 * 24 * time.Hour, wantConsumed: 2},
+		{input: "53 semanas"},
+		{input: "8761 horas"},
+		{input: "99999999999999999 dias"},
+		{input: "99999999999999999999999 dias"},
+		{input: "0 min"},
+		{input: "5"},
+		{input: "5 anos"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, consumed, ok := parseReminderDuration(strings.Fields(tt.input))
+			if ok != (tt.wantConsumed > 0) || got != tt.want || consumed != tt.wantConsumed {
+				t.Errorf("parseReminderDuration(%q) = %s, %d, %v; esperava %s, %d", tt.input, got, consumed, ok, tt.want, tt.wantConsumed)
+			}
+		})
+	}
+}
+
+func TestParseReminderDate(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   time.Time
+		wantOK bool
+	}{
+		{input: "25/12", want: reminderAt(12, 25, 0, 0), wantOK: true},
+		{input: "14/10", want: reminderAt(10, 14, 0, 0), wantOK: true},
+		{input: "10/10", want: time.Date(2027, 10, 10, 0, 0, 0, 0, botLocation), wantOK: true},
+		{input: "1/1/27", want: time.Date(2027, 1, 1, 0, 0, 0, 0, botLocation), wantOK: true},
+		{input: "29/02/2028", want: time.Date(2028, 2, 29, 0, 0, 0, 0, botLocation), wantOK: true},
+		{input: "29/02/2027"},
+		{input: "31/02"},
+		{input: "32/01"},
+		{input: "01/13"},
+		{input: "amanha"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := parseReminderDate(tt.input, reminderNow)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("parseReminderDate(%q) = %s, %v; esperava %s, %v", tt.input, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseReminderWeekday(t *testing.T) {
+	tests := []struct {
+		word   string
+		abbrev bool
+		want   time.Weekday
+		wantOK bool
+	}{
+		{word: "segunda", want: time.Monday, wantOK: true},
+		{word: "sexta-feira", want: time.Friday, wantOK: true},
+		{word: "sabados", want: time.Saturday, wantOK: true},
+		{word: "domingos", want: time.Sunday, wantOK: true},
+		{word: "ter"},
+		{word: "sex"},
+		{word: "ter", abbrev: true, want: time.Tuesday, wantOK: true},
+		{word: "sab", abbrev: true, want: time.Saturday, wantOK: true},
+		{word: "feira", abbrev: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			got, ok := parseReminderWeekday(tt.word, tt.abbrev)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseReminderWeekday(%q, %v) = %s, %v; esperava %s, %v", tt.word, tt.abbrev, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNextReminderOccurrence(t *testing.T) {
+	tests := []struct {
+		name       string
+		at         time.Time
+		recurrence string
+		now        time.Time
+		want       time.Time
+	}{
+		{name: "diário no mesmo dia", at: reminderAt(10, 15, 8, 0), recurrence: reminderDaily, now: reminderAt(10, 17, 7, 0), want: reminderAt(10, 17, 8, 0)},
+		{name: "diário depois do horário", at: reminderAt(10, 15, 8, 0), recurrence: reminderDaily, now: reminderAt(10, 17, 8, 0), want: reminderAt(10, 18, 8, 0)},
+		{name: "semanal pula semanas perdidas", at: reminderAt(10, 19, 8, 0), recurrence: reminderWeekly, now: reminderAt(10, 27, 9, 0), want: reminderAt(11, 2, 8, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextReminderOccurrence(tt.at, tt.recurrence, tt.now); !got.Equal(tt.want) {
+				t.Errorf("nextReminderOccurrence() = %s, esperava %s", got, tt.want)
+			}
+		})
+	}
+}
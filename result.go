@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Motivos de término normalizados entre os provedores (os mesmos nomes usados pelo Gemini)
+const (
+	finishReasonStop      = "STOP"
+	finishReasonMaxTokens = "MAX_TOKENS"
+)
+
+// LLMResult é o resultado completo de uma geração de texto
+type LLMResult struct {
+	Text          string         // Todas as partes de texto da resposta, juntas e formatadas para o WhatsApp
+	Parts         int            // Quantidade de partes de texto recebidas
+	FinishReason  string         // Motivo de término (STOP, MAX_TOKENS, SAFETY, RECITATION...)
+	SafetyRatings []SafetyRating // Avaliações de segurança da resposta
+	Usage         LLMUsage       // Tokens consumidos
+	Model         string         // Modelo que gerou a resposta
+}
+
+// Truncated informa se a resposta foi cortada pelo limite de tokens de saída
+func (r *LLMResult) Truncated() bool {
+	return r.FinishReason == finishReasonMaxTokens
+}
+
+// LLMUsage são os tokens consumidos por uma chamada
+type LLMUsage struct {
+	PromptTokens    int
+	CandidateTokens int
+	ThinkingTokens  int
+	TotalTokens     int
+}
+
+// Add soma o uso de outra chamada (ex: as rodadas de um streaming com ferramentas)
+func (u LLMUsage) Add(other LLMUsage) LLMUsage {
+	return LLMUsage{
+		PromptTokens:    u.PromptTokens + other.PromptTokens,
+		CandidateTokens: u.CandidateTokens + other.CandidateTokens,
+		ThinkingTokens:  u.ThinkingTokens + other.ThinkingTokens,
+		TotalTokens:     u.TotalTokens + other.TotalTokens,
+	}
+}
+
+// SafetyRating é a avaliação de uma categoria de risco feita pelo provedor
+type SafetyRating struct {
+	Category    string // Ex: HARM_CATEGORY_HARASSMENT
+	Probability string // NEGLIGIBLE, LOW, MEDIUM ou HIGH
+	Blocked     bool   // A categoria causou o bloqueio
+}
+
+// BlockedError descreve um pedido ou uma resposta bloqueados pelos filtros do provedor
+// errors.Is(err, ErrLLMBlocked) continua funcionando para quem só quer saber se houve bloqueio
+type BlockedError struct {
+	Prompt        bool           // true: o pedido foi bloqueado; false: a resposta gerada foi descartada
+	Reason        string         // SAFETY, BLOCKLIST, PROHIBITED_CONTENT, RECITATION, SPII...
+	SafetyRatings []SafetyRating // Avaliações que levaram ao bloqueio
+}
+
+// Error implementa a interface error
+func (e *BlockedError) Error() string {
+	stage := "resposta bloqueada"
+	if e.Prompt {
+		stage = "prompt bloqueado"
+	}
+	if category := e.blockedCategory(); category != "" {
+		return fmt.Sprintf("%s: %s (%s, %s)", ErrLLMBlocked, stage, e.Reason, category)
+	}
+	return fmt.Sprintf("%s: %s (%s)", ErrLLMBlocked, stage, e.Reason)
+}
+
+// Unwrap permite comparar com ErrLLMBlocked
+func (e *BlockedError) Unwrap() error {
+	return ErrLLMBlocked
+}
+
+// blockedCategory retorna a categoria que causou o bloqueio (ou a de maior probabilidade)
+func (e *BlockedError) blockedCategory() string {
+	best := ""
+	bestRank := 0
+	for _, rating := range e.SafetyRatings {
+		if rating.Blocked {
+			return rating.Category
+		}
+		if rank := harmProbabilityRank[rating.Probability]; rank > bestRank {
+			best, bestRank = rating.Category, rank
+		}
+	}
+	// Probabilidades baixas não explicam um bloqueio
+	if bestRank < harmProbabilityRank["MEDIUM"] {
+		return ""
+	}
+	return best
+}
+
+// harmProbabilityRank ordena as probabilidades de risco
+var harmProbabilityRank = map[string]int{
+	"NEGLIGIBLE": 1,
+	"LOW":        2,
+	"MEDIUM":     3,
+	"HIGH":       4,
+}
+
+// harmCategoryNames traduz as categorias de risco para as mensagens ao usuário
+var harmCategoryNames = map[string]string{
+	"HARM_CATEGORY_HARASSMENT":        "assédio ou ofensa",
+	"HARM_CATEGORY_HATE_SPEECH":       "discurso de ódio",
+	"HARM_CATEGORY_SEXUALLY_EXPLICIT": "conteúdo sexual explícito",
+	"HARM_CATEGORY_DANGEROUS_CONTENT": "conteúdo perigoso",
+	"HARM_CATEGORY_CIVIC_INTEGRITY":   "integridade eleitoral",
+}
+
+// UserMessage retorna a mensagem explicando o bloqueio para quem pediu
+func (e *BlockedError) UserMessage() string {
+	reason := ""
+	if name, ok := harmCategoryNames[e.blockedCategory()]; ok {
+		reason = fmt.Sprintf(" (%s)", name)
+	}
+
+	switch {
+	case e.Reason == "RECITATION":
+		return "🚫 A resposta reproduzia conteúdo protegido por direitos autorais e foi descartada. Tente pedir algo mais original."
+	case e.Reason == "SPII":
+		return "🚫 A resposta continha dados pessoais sensíveis e foi bloqueada."
+	case e.Reason == "BLOCKLIST" || e.Reason == "PROHIBITED_CONTENT":
+		if e.Prompt {
+			return "🚫 Esse pedido tem termos que eu não posso processar. Tente escrever de outro jeito."
+		}
+		return "🚫 A resposta caiu em conteúdo proibido e foi descartada. Tente pedir de outro jeito."
+	case e.Prompt && e.Reason == "SAFETY":
+		return fmt.Sprintf("🚫 Não posso responder a esse pedido: ele foi barrado pelos filtros de segurança%s. Tente reformular.", reason)
+	case e.Prompt:
+		return "🚫 Não posso responder a esse pedido. Tente reformular."
+	default:
+		return fmt.Sprintf("🚫 A resposta gerada foi barrada pelos filtros de segurança%s. Tente pedir de outro jeito.", reason)
+	}
+}
+
+// blockedUserMessage retorna a mensagem de bloqueio quando o erro é um bloqueio dos filtros
+func blockedUserMessage(err error) (string, bool) {
+	var blocked *BlockedError
+	if errors.As(err, &blocked) {
+		return blocked.UserMessage(), true
+	}
+	if errors.Is(err, ErrLLMBlocked) {
+		return "🚫 O conteúdo foi barrado pelos filtros de segurança. Tente pedir de outro jeito.", true
+	}
+	return "", false
+}
+
+// resultText extrai o texto de um resultado, para os métodos que retornam só a string
+func resultText(result *LLMResult, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	return result.Text, nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// Intervalos do backoff exponencial entre tentativas
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 8 * time.Second
+)
+
+// ErrLLMBlocked indica que o conteúdo foi bloqueado pelos filtros de segurança do provedor
+var ErrLLMBlocked = errors.New("conteúdo bloqueado pelos filtros de segurança")
+
+// llmErrorKind classifica um erro do provedor de IA para decidir o que fazer em seguida
+type llmErrorKind int
+
+const (
+	llmErrorRetryable llmErrorKind = iota // Transitório (429, 5xx, timeout): tentar de novo o mesmo modelo
+	llmErrorFallback                      // Problema do modelo (404, requisição recusada): tentar o próximo modelo
+	llmErrorFatal                         // Não adianta tentar (bloqueio de segurança, API key inválida, cancelado)
+)
+
+// String retorna o nome da classe do erro para os logs
+func (k llmErrorKind) String() string {
+	switch k {
+	case llmErrorRetryable:
+		return "retryable"
+	case llmErrorFallback:
+		return "fallback"
+	default:
+		return "fatal"
+	}
+}
+
+// llmErrorStatus extrai o status HTTP de um erro do Gemini ou da API compatível com OpenAI
+func llmErrorStatus(err error) (int, string, bool) {
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code, apiErr.Message, true
+	}
+	var apiErrPtr *genai.APIError
+	if errors.As(err, &apiErrPtr) {
+		return apiErrPtr.Code, apiErrPtr.Message, true
+	}
+	var openAIErr *OpenAIStatusError
+	if errors.As(err, &openAIErr) {
+		return openAIErr.StatusCode, openAIErr.Message, true
+	}
+	return 0, "", false
+}
+
+// classifyLLMError decide se um erro do provedor justifica nova tentativa, troca de modelo ou desistência
+func classifyLLMError(ctx context.Context, err error) llmErrorKind {
+	// Quem chamou desistiu (ou o prazo geral acabou): não tentar mais nada
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return llmErrorFatal
+	}
+	if errors.Is(err, ErrLLMBlocked) {
+		return llmErrorFatal
+	}
+
+	if status, message, ok := llmErrorStatus(err); ok {
+		switch {
+		case status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500:
+			return llmErrorRetryable
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			return llmErrorFatal
+		case status == http.StatusBadRequest && strings.Contains(strings.ToLower(message), "api key"):
+			// O Gemini responde 400 para API key inválida
+			return llmErrorFatal
+		default:
+			return llmErrorFallback
+		}
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return llmErrorRetryable
+	}
+
+	// Erros sem status (conexão recusada, resposta vazia...) costumam ser passageiros
+	return llmErrorRetryable
+}
+
+// retryDelay calcula a espera antes da tentativa seguinte: backoff exponencial com jitter
+// A espera fica entre metade e o total de base*2^(tentativa-1), limitada a retryMaxDelay
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << (attempt - 1)
+	if delay <= 0 || delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// ResilientProvider envolve uma cadeia de provedores (um por modelo) com novas tentativas e fallback
+// Erros transitórios são tentados de novo com backoff; se o modelo continuar falhando,
+// a chamada passa para o próximo modelo da cadeia (ex: gemini-2.5-flash -> gemini-1.5-flash)
+type ResilientProvider struct {
+	chain       []LLMProvider // O primeiro é o principal; os demais são os fallbacks, em ordem
+	maxAttempts int           // Tentativas por modelo
+}
+
+// NewResilientProvider cria o provedor com novas tentativas e a cadeia de fallback
+func NewResilientProvider(primary LLMProvider, fallbacks []LLMProvider, maxAttempts int) *ResilientProvider {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &ResilientProvider{
+		chain:       append([]LLMProvider{primary}, fallbacks...),
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Unwrap retorna o provedor principal
+func (r *ResilientProvider) Unwrap() LLMProvider {
+	return r.chain[0]
+}
+
+// Name identifica o provedor principal nos logs
+func (r *ResilientProvider) Name() string {
+	return r.chain[0].Name()
+}
+
+// GetModel retorna o modelo principal
+func (r *ResilientProvider) GetModel() string {
+	return r.chain[0].GetModel()
+}
+
+// SetModel troca o modelo principal (os fallbacks continuam os mesmos)
+func (r *ResilientProvider) SetModel(model string) {
+	r.chain[0].SetModel(model)
+}
+
+// FallbackModels retorna os modelos usados quando o principal falha
+func (r *ResilientProvider) FallbackModels() []string {
+	models := make([]string, 0, len(r.chain)-1)
+	for _, provider := range r.chain[1:] {
+		models = append(models, provider.GetModel())
+	}
+	return models
+}
+
+// GenerateContent gera uma resposta com novas tentativas e fallback
+func (r *ResilientProvider) GenerateContent(ctx context.Context, prompt string) (string, error) {
+	return resultText(r.Generate(ctx, prompt, nil))
+}
+
+// GenerateContentWithHistory gera uma resposta com histórico, com novas tentativas e fallback
+func (r *ResilientProvider) GenerateContentWithHistory(ctx context.Context, prompt string, history []ChatMessage) (string, error) {
+	return resultText(r.Generate(ctx, prompt, history))
+}
+
+// Generate gera a resposta completa com novas tentativas e fallback
+func (r *ResilientProvider) Generate(ctx context.Context, prompt string, history []ChatMessage) (*LLMResult, error) {
+	var result *LLMResult
+	err := r.do(ctx, "Generate", func(ctx context.Context, provider LLMProvider) error {
+		var err error
+		result, err = provider.Generate(ctx, prompt, history)
+		return err
+	})
+	return result, err
+}
+
+// CountTokens conta os tokens com novas tentativas e fallback
+func (r *ResilientProvider) CountTokens(ctx context.Context, text string) (int, error) {
+	var count int
+	err := r.do(ctx, "CountTokens", func(ctx context.Context, provider LLMProvider) error {
+		var err error
+		count, err = provider.CountTokens(ctx, text)
+		return err
+	})
+	return count, err
+}
+
+// GenerateContentStream gera a resposta em streaming com novas tentativas e fallback
+func (r *ResilientProvider) GenerateContentStream(ctx context.Context, prompt string) iter.Seq2[string, error] {
+	return r.stream(ctx, "GenerateContentStream", nil, func(ctx context.Context, provider LLMProvider) iter.Seq2[string, error] {
+		return provider.GenerateContentStream(ctx, prompt)
+	})
+}
+
+// GenerateContentStreamWithTools gera a resposta em streaming com ferramentas, novas tentativas e fallback
+// Modelos da cadeia sem suporte a ferramentas respondem só com texto
+func (r *ResilientProvider) GenerateContentStreamWithTools(ctx context.Context, prompt string, tools *ToolRegistry, toolCtx *ToolContext) iter.Seq2[string, error] {
+	return r.stream(ctx, "GenerateContentStreamWithTools", toolCtx, func(ctx context.Context, provider LLMProvider) iter.Seq2[string, error] {
+		return streamWithTools(ctx, provider, prompt, tools, toolCtx)
+	})
+}
+
+// stream repete o streaming enquanto nada tiver sido entregue ao usuário
+// Depois do primeiro trecho (ou de uma ferramenta executada) o erro é repassado:
+// repetir duplicaria o texto já enviado ou as ações das ferramentas
+func (r *ResilientProvider) stream(ctx context.Context, operation string, toolCtx *ToolContext, open func(context.Context, LLMProvider) iter.Seq2[string, error]) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		stopped := false
+		err := r.do(ctx, operation, func(ctx context.Context, provider LLMProvider) error {
+			delivered := false
+			for text, err := range open(ctx, provider) {
+				if err != nil {
+					if delivered || (toolCtx != nil && toolCtx.calls > 0) {
+						stopped = true
+						yield("", err)
+						return nil
+					}
+					return err
+				}
+				delivered = true
+				if !yield(text, nil) {
+					stopped = true
+					return nil
+				}
+			}
+			return nil
+		})
+		if err != nil && !stopped {
+			yield("", err)
+		}
+	}
+}
+
+// do executa a chamada percorrendo a cadeia de modelos, com backoff entre as tentativas
+// O modelo escolhido para o chat (withModel) vale só para o principal; os fallbacks usam os próprios modelos
+func (r *ResilientProvider) do(ctx context.Context, operation string, call func(context.Context, LLMProvider) error) error {
+	var lastErr error
+	for index, provider := range r.chain {
+		callCtx := ctx
+		if index > 0 {
+			callCtx = withModel(ctx, "")
+		}
+		model := modelFromContext(callCtx, provider.GetModel())
+
+		for attempt := 1; attempt <= r.maxAttempts; attempt++ {
+			err := call(callCtx, provider)
+			if err == nil {
+				if index > 0 || attempt > 1 {
+					log.Info().
+						Str("operation", operation).
+						Str("model", model).
+						Int("attempt", attempt).
+						Msg("Chamada à IA bem-sucedida após falhas anteriores")
+				}
+				return nil
+			}
+			lastErr = err
+
+			kind := classifyLLMError(ctx, err)
+			log.Warn().
+				Err(err).
+				Str("operation", operation).
+				Str("model", model).
+				Int("attempt", attempt).
+				Str("class", kind.String()).
+				Msg("Falha na chamada à IA")
+
+			if kind == llmErrorFatal {
+				return err
+			}
+			if kind == llmErrorFallback || attempt == r.maxAttempts {
+				break
+			}
+
+			// Aguardar antes da próxima tentativa (respeitando o cancelamento)
+			select {
+			case <-ctx.Done():
+				return lastErr
+			case <-time.After(retryDelay(attempt)):
+			}
+		}
+
+		if index+1 < len(r.chain) {
+			log.Warn().
+				Str("operation", operation).
+				Str("from", model).
+				Str("to", r.chain[index+1].GetModel()).
+				Msg("Usando modelo de fallback")
+		}
+	}
+
+	return fmt.Errorf("todas as tentativas falharam: %w", lastErr)
+}
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// storyMaxParts limita o número de trechos de uma história colaborativa
+const storyMaxParts = 40
+
+// Story representa uma história colaborativa de um grupo
+type Story struct {
+	ID        int64
+	ChatJID   string
+	Genre     string
+	Title     string
+	Status    string // "active" ou "finished"
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// StoryPart representa um trecho da história (abertura, continuação ou final)
+type StoryPart struct {
+	AuthorJID  string
+	AuthorName string
+	Idea       string // Ideia sugerida pelo membro (vazia na abertura)
+	Text       string
+	Timestamp  time.Time
+}
+
+// storyLocks evita que duas continuações da mesma história sejam geradas ao mesmo tempo
+type storyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lockFor retorna o mutex da história de um grupo
+func (s *storyLocks) lockFor(chatJID string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.locks == nil {
+		s.locks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := s.locks[chatJID]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.locks[chatJID] = lock
+	}
+	return lock
+}
+
+// initStoryTables cria as tabelas das histórias colaborativas
+func (c *ChatContext) initStoryTables() error {
+	createStoriesTableQuery := `
+		CREATE TABLE IF NOT EXISTS stories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_jid TEXT NOT NULL,
+			genre TEXT NOT NULL,
+			title TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'finished')),
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			finished_at DATETIME
+		);
+	`
+
+	_, err := c.db.Exec(createStoriesTableQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar tabela stories: %w", err)
+	}
+
+	createStoriesIndexQuery := `
+		CREATE INDEX IF NOT EXISTS idx_stories_chat_status
+		ON stories (chat_jid, status);
+	`
+
+	_, err = c.db.Exec(createStoriesIndexQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar índice stories: %w", err)
+	}
+
+	createPartsTableQuery := `
+		CREATE TABLE IF NOT EXISTS story_parts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			story_id INTEGER NOT NULL,
+			author_jid TEXT NOT NULL DEFAULT '',
+			author_name TEXT NOT NULL DEFAULT '',
+			idea TEXT NOT NULL DEFAULT '',
+			part_text TEXT NOT NULL,
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+	`
+
+	_, err = c.db.Exec(createPartsTableQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar tabela story_parts: %w", err)
+	}
+
+	createPartsIndexQuery := `
+		CREATE INDEX IF NOT EXISTS idx_story_parts_story
+		ON story_parts (story_id, id);
+	`
+
+	_, err = c.db.Exec(createPartsIndexQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar índice story_parts: %w", err)
+	}
+
+	return nil
+}
+
+// StartStory cria uma nova história ativa para o grupo
+func (c *ChatContext) StartStory(ctx context.Context, chatJID, genre, createdBy string) (int64, error) {
+	query := `
+		INSERT INTO stories (chat_jid, genre, status, created_by, created_at)
+		VALUES (?, ?, 'active', ?, ?)
+	`
+
+	result, err := c.db.ExecContext(ctx, query, chatJID, genre, createdBy, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("erro ao criar história: %w", err)
+	}
+
+	storyID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("erro ao obter ID da história: %w", err)
+	}
+
+	return storyID, nil
+}
+
+// LoadActiveStory carrega a história ativa do grupo (nil se não houver)
+func (c *ChatContext) LoadActiveStory(ctx context.Context, chatJID string) (*Story, error) {
+	query := `
+		SELECT id, chat_jid, genre, title, status, created_by, created_at
+		FROM stories
+		WHERE chat_jid = ? AND status = 'active'
+		ORDER BY id DESC
+		LIMIT 1
+	`
+
+	var story Story
+	err := c.db.QueryRowContext(ctx, query, chatJID).Scan(
+		&story.ID, &story.ChatJID, &story.Genre, &story.Title, &story.Status, &story.CreatedBy, &story.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar história ativa: %w", err)
+	}
+
+	return &story, nil
+}
+
+// SaveStoryPart adiciona um trecho à história
+func (c *ChatContext) SaveStoryPart(ctx context.Context, storyID int64, part StoryPart) error {
+	query := `
+		INSERT INTO story_parts (story_id, author_jid, author_name, idea, part_text, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := c.db.ExecContext(ctx, query, storyID, part.AuthorJID, part.AuthorName, part.Idea, part.Text, time.Now())
+	if err != nil {
+		return fmt.Errorf("erro ao salvar trecho da história: %w", err)
+	}
+
+	return nil
+}
+
+// LoadStoryParts carrega todos os trechos da história em ordem
+func (c *ChatContext) LoadStoryParts(ctx context.Context, storyID int64) ([]StoryPart, error) {
+	query := `
+		SELECT author_jid, author_name, idea, part_text, timestamp
+		FROM story_parts
+		WHERE story_id = ?
+		ORDER BY id ASC
+	`
+
+	rows, err := c.db.QueryContext(ctx, query, storyID)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar trechos da história: %w", err)
+	}
+	defer rows.Close()
+
+	var parts []StoryPart
+	for rows.Next() {
+		var part StoryPart
+		err := rows.Scan(&part.AuthorJID, &part.AuthorName, &part.Idea, &part.Text, &part.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler trecho da história: %w", err)
+		}
+		parts = append(parts, part)
+	}
+
+	return parts, nil
+}
+
+// FinishStory encerra a história com o título informado
+func (c *ChatContext) FinishStory(ctx context.Context, storyID int64, title string) error {
+	query := `
+		UPDATE stories
+		SET status = 'finished', title = ?, finished_at = ?
+		WHERE id = ?
+	`
+
+	_, err := c.db.ExecContext(ctx, query, title, time.Now(), storyID)
+	if err != nil {
+		return fmt.Errorf("erro ao encerrar história: %w", err)
+	}
+
+	return nil
+}
+
+// formatStorySoFar junta os trechos da história para o prompt
+func formatStorySoFar(parts []StoryPart) string {
+	texts := make([]string, len(parts))
+	for i, part := range parts {
+		texts[i] = part.Text
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// storyAuthors retorna os nomes de quem contribuiu com ideias, sem repetição
+func storyAuthors(parts []StoryPart) []string {
+	seen := make(map[string]bool)
+	var authors []string
+	for _, part := range parts {
+		if part.AuthorName == "" || seen[part.AuthorName] {
+			continue
+		}
+		seen[part.AuthorName] = true
+		authors = append(authors, part.AuthorName)
+	}
+	return authors
+}
+
+// buildStoryDocument monta o texto completo da história para exportação
+func buildStoryDocument(story *Story, title string, parts []StoryPart) string {
+	var doc strings.Builder
+	doc.WriteString(title + "\n")
+	doc.WriteString(strings.Repeat("=", len([]rune(title))) + "\n\n")
+	doc.WriteString(fmt.Sprintf("Gênero: %s\n", story.Genre))
+	doc.WriteString(fmt.Sprintf("Iniciada em: %s\n", story.CreatedAt.Local().Format("02/01/2006 15:04")))
+	if authors := storyAuthors(parts); len(authors) > 0 {
+		doc.WriteString(fmt.Sprintf("Autores: %s\n", strings.Join(authors, ", ")))
+	}
+	doc.WriteString("\n")
+
+	for _, part := range parts {
+		doc.WriteString(strings.TrimSpace(part.Text) + "\n\n")
+	}
+
+	doc.WriteString("---\nHistória criada em grupo com o DuckerIA.\n")
+	return doc.String()
+}
+
+// parseStoryEnding separa o título (primeira linha "TÍTULO: ...") do final da história
+func parseStoryEnding(response, genre string) (string, string) {
+	title := fmt.Sprintf("Uma história de %s", genre)
+	lines := strings.SplitN(strings.TrimSpace(response), "\n", 2)
+	first := strings.TrimSpace(strings.Trim(lines[0], "*#_ "))
+
+	for _, prefix := range []string{"TÍTULO:", "Título:", "TITULO:", "Titulo:"} {
+		if strings.HasPrefix(first, prefix) {
+			if candidate := strings.TrimSpace(strings.Trim(strings.TrimPrefix(first, prefix), "*\"_ ")); candidate != "" {
+				title = candidate
+			}
+			if len(lines) > 1 {
+				return title, strings.TrimSpace(lines[1])
+			}
+			return title, ""
+		}
+	}
+
+	return title, strings.TrimSpace(response)
+}
+
+// sendStoryText envia uma mensagem de texto simples da história
+func (ch *CommandHandler) sendStoryText(ctx context.Context, text string, evt *events.Message, bot *BotClient) error {
+	msg := &waProto.Message{
+		Conversation: &text,
+	}
+	_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+	return err
+}
+
+// handleHistoriaIniciarCommand processa "!historia iniciar <gênero>"
+func (ch *CommandHandler) handleHistoriaIniciarCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
+	chatJID := evt.Info.Chat.String()
+	lock := ch.stories.lockFor(chatJID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	active, err := bot.chatContext.LoadActiveStory(ctx, chatJID)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao consultar história ativa")
+		return ch.sendStoryText(ctx, "❌ Erro ao iniciar a história. Tente novamente mais tarde.", evt, bot)
+	}
+	if active != nil {
+		return ch.sendStoryText(ctx, fmt.Sprintf("📖 Já existe uma história de %s em andamento!\nUse *!continuar <ideia>* para seguir ou *!historia fim* para encerrar.", active.Genre), evt, bot)
+	}
+
+	genre := "aventura"
+	if len(args) > 0 {
+		genre = strings.ToLower(strings.Join(args, " "))
+	}
+
+	// Enviar evento de "digitando"
+	errTyping := bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresenceComposing, types.ChatPresenceMediaText)
+	if errTyping != nil {
+		log.Warn().Err(errTyping).Msg("Erro ao enviar status de digitando")
+	}
+	defer bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
+
+	instruction := `Você é um contador de histórias criativo e envolvente em português brasileiro.
+Você vai conduzir uma história colaborativa do gênero %s em um grupo de WhatsApp: os membros vão sugerir o que acontece depois.
+
+Escreva APENAS a abertura da história:
+- Apresente o cenário e o(s) protagonista(s)
+- Entre 2 e 3 parágrafos curtos
+- Termine em um momento de expectativa, deixando espaço para os membros decidirem o rumo
+- NÃO conclua a história
+- NÃO use emojis
+- Adequado para todos os públicos
+- Responda APENAS com o texto da história, sem títulos, explicações ou comentários
+
+Escreva a abertura agora:`
+	prompt := fmt.Sprintf(instruction, genre)
+
+	log.Info().
+		Str("chat", chatJID).
+		Str("tipo", genre).
+		Msg("Iniciando história colaborativa com IA")
+
+	opening, err := bot.llm.GenerateContent(withInstruction(bot.aiContext(ctx, evt, personaHistoria, "historia iniciar"), instruction), prompt)
+	if err != nil {
+		return bot.handleAIError(ctx, evt.Info.Chat, err, "Erro ao gerar abertura da história com IA", "❌ Erro ao iniciar a história. Tente novamente mais tarde.")
+	}
+	opening = strings.TrimSpace(opening)
+
+	storyID, err := bot.chatContext.StartStory(ctx, chatJID, genre, evt.Info.Sender.ToNonAD().String())
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao salvar história")
+		return ch.sendStoryText(ctx, "❌ Erro ao iniciar a história. Tente novamente mais tarde.", evt, bot)
+	}
+
+	err = bot.chatContext.SaveStoryPart(ctx, storyID, StoryPart{Text: opening})
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao salvar abertura da história")
+		return ch.sendStoryText(ctx, "❌ Erro ao iniciar a história. Tente novamente mais tarde.", evt, bot)
+	}
+
+	storyMsg := fmt.Sprintf("📖 *História colaborativa de %s*\n\n%s\n\n_O que acontece agora? Use *!continuar <ideia>* para decidir o rumo ou *!historia fim* para encerrar._", strings.Title(genre), opening)
+	_, err = bot.chunker.Send(ctx, evt.Info.Chat, evt.Info.Sender, storyMsg, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao enviar abertura da história")
+		return err
+	}
+
+	log.Info().
+		Int64("storyID", storyID).
+		Str("tipo", genre).
+		Msg("História colaborativa iniciada")
+
+	return nil
+}
+
+// handleContinuarCommand processa "!continuar <ideia>" avançando a história ativa do grupo
+func (ch *CommandHandler) handleContinuarCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
+	if bot.llm == nil {
+		return ch.sendStoryText(ctx, "❌ IA não está configurada. Configure um provedor (ex: API key do Gemini) para usar este comando.", evt, bot)
+	}
+
+	if len(args) == 0 {
+		return ch.sendStoryText(ctx, "❌ Use: !continuar <ideia>\nExemplo: !continuar a luz se apaga e alguém bate na porta", evt, bot)
+	}
+	idea := strings.Join(args, " ")
+
+	chatJID := evt.Info.Chat.String()
+	lock := ch.stories.lockFor(chatJID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	story, err := bot.chatContext.LoadActiveStory(ctx, chatJID)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao consultar história ativa")
+		return ch.sendStoryText(ctx, "❌ Erro ao continuar a história. Tente novamente mais tarde.", evt, bot)
+	}
+	if story == nil {
+		return ch.sendStoryText(ctx, "📖 Nenhuma história em andamento.\nUse *!historia iniciar <gênero>* para começar uma (ex: !historia iniciar terror).", evt, bot)
+	}
+
+	parts, err := bot.chatContext.LoadStoryParts(ctx, story.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao carregar trechos da história")
+		return ch.sendStoryText(ctx, "❌ Erro ao continuar a história. Tente novamente mais tarde.", evt, bot)
+	}
+	if len(parts) >= storyMaxParts {
+		return ch.sendStoryText(ctx, "📖 A história já está bem longa! Use *!historia fim* para dar um final a ela.", evt, bot)
+	}
+
+	// Enviar evento de "digitando"
+	errTyping := bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresenceComposing, types.ChatPresenceMediaText)
+	if errTyping != nil {
+		log.Warn().Err(errTyping).Msg("Erro ao enviar status de digitando")
+	}
+	defer bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
+
+	authorName := evt.Info.PushName
+	if authorName == "" {
+		authorName = evt.Info.Sender.User
+	}
+
+	instruction := `Você é um contador de histórias criativo conduzindo uma história colaborativa do gênero %s em português brasileiro.
+
+História até agora:
+%s
+
+%s sugeriu o que acontece a seguir: "%s"
+
+Escreva APENAS o próximo trecho da história:
+- Incorpore a ideia sugerida de forma natural e criativa
+- Mantenha a coerência com os personagens e acontecimentos anteriores
+- Entre 1 e 2 parágrafos curtos
+- Termine deixando espaço para a próxima ideia
+- NÃO conclua a história
+- NÃO use emojis
+- Adequado para todos os públicos (se a ideia for inapropriada, adapte-a)
+- Responda APENAS com o texto do trecho, sem títulos, explicações ou comentários
+
+Escreva o próximo trecho agora:`
+	prompt := fmt.Sprintf(instruction, story.Genre, formatStorySoFar(parts), authorName, idea)
+
+	log.Info().
+		Int64("storyID", story.ID).
+		Int("parts", len(parts)).
+		Str("author", authorName).
+		Msg("Continuando história colaborativa com IA")
+
+	continuation, err := bot.llm.GenerateContent(withInstruction(bot.aiContext(ctx, evt, personaHistoria, "continuar"), instruction), prompt)
+	if err != nil {
+		return bot.handleAIError(ctx, evt.Info.Chat, err, "Erro ao continuar história com IA", "❌ Erro ao continuar a história. Tente novamente mais tarde.")
+	}
+	continuation = strings.TrimSpace(continuation)
+
+	err = bot.chatContext.SaveStoryPart(ctx, story.ID, StoryPart{
+		AuthorJID:  evt.Info.Sender.ToNonAD().String(),
+		AuthorName: authorName,
+		Idea:       idea,
+		Text:       continuation,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao salvar trecho da história")
+		return ch.sendStoryText(ctx, "❌ Erro ao continuar a história. Tente novamente mais tarde.", evt, bot)
+	}
+
+	storyMsg := fmt.Sprintf("📖 *Capítulo %d* _(ideia de %s)_\n\n%s", len(parts)+1, authorName, continuation)
+	_, err = bot.chunker.Send(ctx, evt.Info.Chat, evt.Info.Sender, storyMsg, nil)
+	return err
+}
+
+// handleHistoriaFimCommand processa "!historia fim": gera o final e exporta a história como documento
+func (ch *CommandHandler) handleHistoriaFimCommand(ctx context.Context, evt *events.Message, bot *BotClient) error {
+	chatJID := evt.Info.Chat.String()
+	lock := ch.stories.lockFor(chatJID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	story, err := bot.chatContext.LoadActiveStory(ctx, chatJID)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao consultar história ativa")
+		return ch.sendStoryText(ctx, "❌ Erro ao encerrar a história. Tente novamente mais tarde.", evt, bot)
+	}
+	if story == nil {
+		return ch.sendStoryText(ctx, "📖 Nenhuma história em andamento.\nUse *!historia iniciar <gênero>* para começar uma.", evt, bot)
+	}
+
+	parts, err := bot.chatContext.LoadStoryParts(ctx, story.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao carregar trechos da história")
+		return ch.sendStoryText(ctx, "❌ Erro ao encerrar a história. Tente novamente mais tarde.", evt, bot)
+	}
+
+	// Enviar evento de "digitando"
+	errTyping := bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresenceComposing, types.ChatPresenceMediaText)
+	if errTyping != nil {
+		log.Warn().Err(errTyping).Msg("Erro ao enviar status de digitando")
+	}
+	defer bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
+
+	instruction := `Você é um contador de histórias criativo conduzindo uma história colaborativa do gênero %s em português brasileiro.
+
+História até agora:
+%s
+
+Escreva o FINAL da história:
+- Na primeira linha, escreva o título da história completa no formato: TÍTULO: <título>
+- Depois, escreva a conclusão em 1 a 3 parágrafos, amarrando os acontecimentos anteriores
+- NÃO use emojis
+- Adequado para todos os públicos
+- Responda APENAS com a linha do título e o final, sem explicações ou comentários
+
+Escreva agora:`
+	prompt := fmt.Sprintf(instruction, story.Genre, formatStorySoFar(parts))
+
+	log.Info().
+		Int64("storyID", story.ID).
+		Int("parts", len(parts)).
+		Msg("Encerrando história colaborativa com IA")
+
+	response, err := bot.llm.GenerateContent(withInstruction(bot.aiContext(ctx, evt, personaHistoria, "historia fim"), instruction), prompt)
+	if err != nil {
+		return bot.handleAIError(ctx, evt.Info.Chat, err, "Erro ao gerar final da história com IA", "❌ Erro ao encerrar a história. Tente novamente mais tarde.")
+	}
+
+	title, ending := parseStoryEnding(response, story.Genre)
+	if ending != "" {
+		parts = append(parts, StoryPart{Text: ending})
+		err = bot.chatContext.SaveStoryPart(ctx, story.ID, StoryPart{Text: ending})
+		if err != nil {
+			log.Warn().Err(err).Msg("Erro ao salvar final da história, mas continuando")
+		}
+	}
+
+	err = bot.chatContext.FinishStory(ctx, story.ID, title)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao encerrar história")
+		return ch.sendStoryText(ctx, "❌ Erro ao encerrar a história. Tente novamente mais tarde.", evt, bot)
+	}
+
+	endingMsg := fmt.Sprintf("📖 *%s* — Fim\n\n%s", title, ending)
+	_, err = bot.chunker.Send(ctx, evt.Info.Chat, evt.Info.Sender, endingMsg, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao enviar final da história")
+		return err
+	}
+
+	// Exportar a história completa como documento .txt
+	document := buildStoryDocument(story, title, parts)
+	fileName := sanitizeFileName(title) + ".txt"
+	caption := fmt.Sprintf("📚 História completa: %s", title)
+	err = bot.mediaService.SendDocument(ctx, evt.Info.Chat, []byte(document), "text/plain", fileName, caption)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao exportar história como documento")
+		return ch.sendStoryText(ctx, "❌ Não foi possível enviar o arquivo da história, mas ela foi salva.", evt, bot)
+	}
+
+	log.Info().
+		Int64("storyID", story.ID).
+		Str("title", title).
+		Int("parts", len(parts)).
+		Msg("História colaborativa encerrada e exportada")
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"strings"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// streamEditInterval é o intervalo mínimo entre edições da mensagem durante o streaming
+const streamEditInterval = 1500 * time.Millisecond
+
+// streamCursor indica na mensagem que a resposta ainda está sendo escrita
+const streamCursor = " ▍"
+
+// streamInterruptedNote é adicionado quando o streaming falha depois de já ter enviado texto
+const streamInterruptedNote = "\n\n_(resposta interrompida)_"
+
+// SendStream envia uma resposta gerada em streaming
+// Cada item do stream é o texto acumulado até o momento. A primeira parte é enviada assim que chega e atualizada por edições (com intervalo mínimo) até o texto
+// final; partes que excedem o tamanho da mensagem são enviadas depois, como no Send. Se a edição falhar,
+// a mensagem parcial é apagada e a resposta completa é enviada de uma vez.
+// Retorna o texto gerado (sem o prefixo); só retorna erro quando nada chegou ao usuário (nada foi gerado
+// ou o único envio falhou). Falhas depois de o texto aparecer no chat são apenas registradas no log.
+func (mc *MessageChunker) SendStream(ctx context.Context, chat, requester types.JID, prefix string, stream iter.Seq2[string, error]) (string, error) {
+	var (
+		text      string
+		messageID types.MessageID
+		lastEdit  time.Time
+		lastShown string
+		editing   = true
+		streamErr error
+	)
+
+	for snapshot, err := range stream {
+		if err != nil {
+			streamErr = err
+			break
+		}
+		text = snapshot
+		if !editing {
+			continue
+		}
+
+		// Só a primeira mensagem é atualizada durante o streaming
+		preview := splitMessage(strings.TrimSpace(prefix+text), mc.maxRunes)
+		if len(preview) == 0 || preview[0] == lastShown {
+			continue
+		}
+
+		if messageID == "" {
+			resp, err := mc.client.SendMessage(ctx, chat, &waProto.Message{Conversation: proto.String(preview[0] + streamCursor)})
+			if err != nil {
+				log.Warn().Err(err).Msg("Erro ao enviar primeira parte do streaming, enviando resposta completa no final")
+				editing = false
+				continue
+			}
+			messageID = resp.ID
+			lastEdit = time.Now()
+			lastShown = preview[0]
+			continue
+		}
+
+		if time.Since(lastEdit) < streamEditInterval {
+			continue
+		}
+		err = mc.editMessage(ctx, chat, messageID, preview[0]+streamCursor)
+		if err != nil {
+			log.Warn().Err(err).Msg("Erro ao editar mensagem durante o streaming, enviando resposta completa no final")
+			editing = false
+			continue
+		}
+		lastEdit = time.Now()
+		lastShown = preview[0]
+	}
+
+	if strings.TrimSpace(text) == "" {
+		if messageID != "" {
+			mc.revokeMessage(ctx, chat, messageID)
+		}
+		if streamErr != nil {
+			return "", streamErr
+		}
+		return "", fmt.Errorf("resposta vazia da IA")
+	}
+
+	message := prefix + text
+	if streamErr != nil {
+		log.Warn().Err(streamErr).Int("length", len(text)).Msg("Streaming interrompido, enviando resposta parcial")
+		message += streamInterruptedNote
+	}
+
+	// Com a mensagem parcial já exibida, uma falha ao finalizar só vai para o log:
+	// o chamador não deve tratar a resposta como perdida nem enviar uma mensagem de erro depois dela
+	if err := mc.finishStream(ctx, chat, requester, message, messageID, editing); err != nil {
+		if messageID == "" {
+			return "", err
+		}
+		log.Warn().Err(err).Str("chat", chat.String()).Int("length", len(text)).Msg("Erro ao finalizar resposta em streaming")
+	}
+	return text, nil
+}
+
+// finishStream finaliza a resposta: edita a primeira mensagem com o texto final e envia as demais partes
+func (mc *MessageChunker) finishStream(ctx context.Context, chat, requester types.JID, message string, messageID types.MessageID, editing bool) error {
+	// Sem mensagem parcial (ou com edição falhando): envio único da resposta completa
+	if messageID == "" || !editing {
+		if messageID != "" {
+			mc.revokeMessage(ctx, chat, messageID)
+		}
+		_, err := mc.Send(ctx, chat, requester, message, nil)
+		return err
+	}
+
+	chunks := splitMessage(strings.TrimSpace(message), mc.maxRunes)
+	first, rest := chunks[0], chunks[1:]
+
+	var pending []string
+	if len(rest) > mc.maxParts-1 {
+		rest, pending = rest[:mc.maxParts-1], rest[mc.maxParts-1:]
+	}
+	mc.setPending(chat, requester, pending)
+	if len(rest) == 0 && len(pending) > 0 {
+		first += chunkMoreFooter
+	}
+
+	err := mc.editMessage(ctx, chat, messageID, first)
+	if err != nil {
+		log.Warn().Err(err).Msg("Erro ao finalizar mensagem do streaming, enviando resposta completa")
+		mc.revokeMessage(ctx, chat, messageID)
+		_, err := mc.Send(ctx, chat, requester, message, nil)
+		return err
+	}
+
+	if len(rest) == 0 {
+		mc.client.SendChatPresence(ctx, chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
+		return nil
+	}
+
+	mc.client.SendChatPresence(ctx, chat, types.ChatPresenceComposing, types.ChatPresenceMediaText)
+	_, err = mc.sendChunks(ctx, chat, rest, nil, len(pending) > 0)
+	return err
+}
+
+// editMessage substitui o texto de uma mensagem enviada pelo bot
+func (mc *MessageChunker) editMessage(ctx context.Context, chat types.JID, messageID types.MessageID, text string) error {
+	edit := mc.client.BuildEdit(chat, messageID, &waProto.Message{Conversation: proto.String(text)})
+	_, err := mc.client.SendMessage(ctx, chat, edit)
+	if err != nil {
+		return fmt.Errorf("erro ao editar mensagem: %w", err)
+	}
+	return nil
+}
+
+// revokeMessage apaga para todos uma mensagem parcial enviada pelo bot (melhor esforço)
+func (mc *MessageChunker) revokeMessage(ctx context.Context, chat types.JID, messageID types.MessageID) {
+	_, err := mc.client.SendMessage(ctx, chat, mc.client.BuildRevoke(chat, types.EmptyJID, messageID))
+	if err != nil {
+		log.Warn().Err(err).Str("id", messageID).Msg("Erro ao apagar mensagem parcial do streaming")
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Limites do !resumo
+const (
+	summaryDefaultMessages = 50  // Mensagens resumidas quando o comando vem sem argumento
+	summaryMaxMessages     = 300 // Máximo de mensagens enviadas à IA em um resumo
+	summaryMinMessages     = 3   // Menos que isso não vale um resumo
+	summaryMaxMessageChars = 500 // Mensagens mais longas são cortadas no prompt
+)
+
+// summaryUsage explica como usar o !resumo
+const summaryUsage = "Use: !resumo [mensagens|tempo]\nExemplos: !resumo (últimas 50 mensagens), !resumo 100, !resumo 2h, !resumo 30 minutos"
+
+// LoadGroupMessagesSince recupera as mensagens de um grupo desde um horário (no máximo as maxMessages mais recentes)
+func (c *ChatContext) LoadGroupMessagesSince(ctx context.Context, groupJID string, since time.Time, maxMessages int) ([]ChatMessage, error) {
+	query := `
+		SELECT id, user_jid, message_type, message_text, timestamp
+		FROM chat_history
+		WHERE user_jid = ? AND timestamp >= ?
+		ORDER BY timestamp DESC
+		LIMIT ?
+	`
+
+	rows, err := c.db.QueryContext(ctx, query, groupJID, since, maxMessages)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar mensagens do grupo: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []ChatMessage
+	for rows.Next() {
+		var msg ChatMessage
+		err := rows.Scan(&msg.ID, &msg.UserJID, &msg.MessageType, &msg.MessageText, &msg.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler mensagem do grupo: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	// Inverter para ordem cronológica (mais antiga primeiro)
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	return messages, nil
+}
+
+// parseSummaryRange interpreta os argumentos do !resumo
+// Um número sozinho é a quantidade de mensagens ("!resumo 100"); com unidade é um período ("!resumo 2h", "!resumo 30 minutos")
+func parseSummaryRange(args []string) (int, time.Duration, bool) {
+	if len(args) == 0 {
+		return summaryDefaultMessages, 0, true
+	}
+
+	words := make([]string, len(args))
+	for i, arg := range args {
+		words[i] = strings.ToLower(arg)
+	}
+
+	if window, consumed, ok := parseReminderDuration(words); ok && consumed == len(words) {
+		return 0, window, true
+	}
+
+	count, err := strconv.Atoi(words[0])
+	if err != nil || count <= 0 || len(words) > 1 {
+		return 0, 0, false
+	}
+	return min(count, summaryMaxMessages), 0, true
+}
+
+// formatSummaryPeriod descreve o período de um resumo ("das últimas 2 horas", "dos últimos 30 minutos", "do último dia")
+func formatSummaryPeriod(window time.Duration) string {
+	switch {
+	case window%(24*time.Hour) == 0:
+		if days := int(window / (24 * time.Hour)); days > 1 {
+			return fmt.Sprintf("dos últimos %d dias", days)
+		}
+		return "do último dia"
+	case window%time.Hour == 0:
+		if hours := int(window / time.Hour); hours > 1 {
+			return fmt.Sprintf("das últimas %d horas", hours)
+		}
+		return "da última hora"
+	default:
+		if minutes := int(window / time.Minute); minutes > 1 {
+			return fmt.Sprintf("dos últimos %d minutos", minutes)
+		}
+		return "do último minuto"
+	}
+}
+
+// formatSummaryTranscript monta a conversa enviada à IA, uma mensagem por linha: "[15:04] Nome: texto"
+func formatSummaryTranscript(messages []ChatMessage) string {
+	var transcript strings.Builder
+	for _, msg := range messages {
+		text := strings.Join(strings.Fields(msg.MessageText), " ")
+		if runes := []rune(text); len(runes) > summaryMaxMessageChars {
+			text = string(runes[:summaryMaxMessageChars]) + "…"
+		}
+		if msg.MessageType == "assistant" {
+			text = "DuckerIA (bot): " + text
+		}
+		fmt.Fprintf(&transcript, "[%s] %s\n", msg.Timestamp.In(botLocation).Format("15:04"), text)
+	}
+	return transcript.String()
+}
+
+// handleResumoCommand resume as últimas mensagens ou as últimas horas da conversa do grupo
+// Uso: !resumo | !resumo <mensagens> | !resumo <tempo>
+func (ch *CommandHandler) handleResumoCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
+	// Verificar se é um grupo
+	if evt.Info.Chat.Server != types.GroupServer {
+		errorMsg := "❌ Este comando só funciona em grupos!"
+		msg := &waProto.Message{
+			Conversation: &errorMsg,
+		}
+		_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+		return err
+	}
+
+	// O resumo é gerado pela IA: respeitar a IA desabilitada no grupo
+	if rules := bot.groupProcessor.GetGroupRules(evt.Info.Chat.String()); !rules.EnableAI {
+		return ch.sendReplyMessage(ctx, "❌ A IA está desabilitada neste grupo.", nil, evt, bot)
+	}
+
+	// Verificar se o provedor de IA está configurado
+	if bot.llm == nil {
+		errorMsg := "❌ IA não está configurada. Configure um provedor (ex: API key do Gemini) para usar este comando."
+		msg := &waProto.Message{
+			Conversation: &errorMsg,
+		}
+		_, err := bot.WAClient.SendMessage(ctx, evt.Info.Chat, msg)
+		return err
+	}
+
+	count, window, ok := parseSummaryRange(args)
+	if !ok {
+		return ch.sendReplyMessage(ctx, "❌ "+summaryUsage, nil, evt, bot)
+	}
+
+	groupJID := evt.Info.Chat.String()
+	var (
+		messages []ChatMessage
+		err      error
+		period   string
+	)
+	if window > 0 {
+		messages, err = bot.chatContext.LoadGroupMessagesSince(ctx, groupJID, time.Now().Add(-window), summaryMaxMessages)
+		period = formatSummaryPeriod(window)
+	} else {
+		messages, err = bot.chatContext.LoadGroupMessages(ctx, groupJID, count)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("group", groupJID).Msg("Erro ao carregar mensagens para o resumo")
+		return ch.sendReplyMessage(ctx, "❌ Erro ao carregar as mensagens do grupo. Tente novamente mais tarde.", nil, evt, bot)
+	}
+	if period == "" {
+		period = fmt.Sprintf("das últimas %d mensagens", len(messages))
+	}
+	if len(messages) < summaryMinMessages {
+		return ch.sendReplyMessage(ctx, "📝 Ainda não há mensagens suficientes nesse período para resumir.\nO bot só guarda as mensagens enviadas enquanto está no grupo.", nil, evt, bot)
+	}
+
+	// Enviar evento de "digitando"
+	errTyping := bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresenceComposing, types.ChatPresenceMediaText)
+	if errTyping != nil {
+		log.Warn().Err(errTyping).Msg("Erro ao enviar status de digitando")
+	}
+	defer bot.WAClient.SendChatPresence(ctx, evt.Info.Chat, types.ChatPresencePaused, types.ChatPresenceMediaText)
+
+	instruction := `Você resume conversas de grupos de WhatsApp em português brasileiro.
+
+Resuma a conversa abaixo em tópicos curtos:
+- Um tópico por assunto discutido, na ordem em que apareceram
+- Em cada tópico, diga quem disse o quê usando os nomes das pessoas (ex: "Ana sugeriu..., João discordou...")
+- Destaque decisões, combinados, datas e perguntas que ficaram sem resposta
+- No máximo 10 tópicos, cada um com uma ou duas frases
+- Ignore cumprimentos, figurinhas e conversas sem conteúdo
+- Seja fiel ao que foi dito: não invente nem opine
+- Não use emojis
+
+Conversa (%d mensagens):
+%s
+Resumo em tópicos:`
+	prompt := fmt.Sprintf(instruction, len(messages), formatSummaryTranscript(messages))
+
+	log.Info().
+		Str("group", groupJID).
+		Int("messages", len(messages)).
+		Dur("window", window).
+		Msg("Processando comando !resumo")
+
+	// O mesmo trecho da conversa gera o mesmo resumo: pedidos repetidos sem mensagens novas saem do cache
+	summary, err := bot.cache.Generate(withInstruction(bot.aiContext(ctx, evt, personaResumo, "resumo"), instruction), bot.llm, "resumo", prompt)
+	if err != nil {
+		return bot.handleAIError(ctx, evt.Info.Chat, err, "Erro ao gerar resumo com IA", "❌ Erro ao gerar o resumo. Tente novamente mais tarde.")
+	}
+
+	_, err = bot.chunker.Send(ctx, evt.Info.Chat, evt.Info.Sender, fmt.Sprintf("📝 *Resumo %s:*\n\n%s", period, summary), nil)
+	return err
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/genai"
+)
+
+// maxToolIterations limita quantas rodadas de chamadas de ferramentas a IA pode fazer por resposta
+const maxToolIterations = 5
+
+// toolReminderLayout é o formato de data/hora aceito pela ferramenta de lembretes
+const toolReminderLayout = "2006-01-02 15:04"
+
+// botLocation é o fuso horário usado pelo bot (horário de Brasília, sem horário de verão)
+var botLocation = loadBotLocation()
+
+// loadBotLocation carrega o fuso do Maranhão, com fallback para UTC-3 quando não há tzdata no sistema
+func loadBotLocation() *time.Location {
+	location, err := time.LoadLocation("America/Fortaleza")
+	if err != nil {
+		return time.FixedZone("BRT", -3*60*60)
+	}
+	return location
+}
+
+// weekdayNames são os dias da semana em português
+var weekdayNames = [...]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"}
+
+// ToolContext carrega a mensagem que originou a resposta da IA para as ferramentas
+type ToolContext struct {
+	Bot   *BotClient
+	Event *events.Message
+
+	calls int // Ferramentas já executadas nesta resposta
+}
+
+// ToolHandler executa uma ferramenta com os argumentos enviados pela IA
+// O mapa retornado é devolvido ao modelo como resposta da função
+type ToolHandler func(ctx context.Context, tc *ToolContext, args map[string]any) (map[string]any, error)
+
+// Tool descreve uma função Go que a IA pode chamar
+type Tool struct {
+	Name        string         // Nome da função (snake_case)
+	Description string         // Quando e para que a IA deve usar a função
+	Parameters  map[string]any // JSON Schema dos argumentos
+	Handler     ToolHandler
+}
+
+// ToolRegistry guarda as ferramentas disponíveis para a IA
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]*Tool
+}
+
+// NewToolRegistry cria um registro de ferramentas vazio
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools: make(map[string]*Tool),
+	}
+}
+
+// Register adiciona (ou substitui) uma ferramenta no registro
+func (r *ToolRegistry) Register(tool *Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[tool.Name] = tool
+}
+
+// Declarations retorna as declarações das ferramentas no formato do Gemini, ordenadas por nome
+func (r *ToolRegistry) Declarations() []*genai.FunctionDeclaration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	declarations := make([]*genai.FunctionDeclaration, 0, len(r.tools))
+	for _, tool := range r.tools {
+		declarations = append(declarations, &genai.FunctionDeclaration{
+			Name:                 tool.Name,
+			Description:          tool.Description,
+			ParametersJsonSchema: tool.Parameters,
+		})
+	}
+	sort.Slice(declarations, func(i, j int) bool {
+		return declarations[i].Name < declarations[j].Name
+	})
+	return declarations
+}
+
+// Execute executa a chamada de função pedida pela IA
+// Erros viram a resposta {"erro": "..."} para que o modelo possa explicá-los ao usuário
+func (r *ToolRegistry) Execute(ctx context.Context, tc *ToolContext, call *genai.FunctionCall) map[string]any {
+	r.mu.RLock()
+	tool, ok := r.tools[call.Name]
+	r.mu.RUnlock()
+	if !ok {
+		return map[string]any{"erro": fmt.Sprintf("ferramenta desconhecida: %s", call.Name)}
+	}
+
+	log.Info().
+		Str("tool", call.Name).
+		Interface("args", call.Args).
+		Msg("Executando ferramenta chamada pela IA")
+
+	if tc != nil {
+		tc.calls++
+	}
+
+	result, err := tool.Handler(ctx, tc, call.Args)
+	if err != nil {
+		log.Warn().Err(err).Str("tool", call.Name).Msg("Erro ao executar ferramenta")
+		return map[string]any{"erro": err.Error()}
+	}
+	return result
+}
+
+// NewDefaultToolRegistry cria o registro com as ferramentas padrão do bot
+func NewDefaultToolRegistry() *ToolRegistry {
+	registry := NewToolRegistry()
+
+	registry.Register(&Tool{
+		Name:        "obter_data_hora",
+		Description: "Retorna a data e a hora atuais no fuso do bot (horário de Brasília). Use antes de interpretar expressões como 'amanhã', 'daqui a 2 horas' ou 'sexta que vem'.",
+		Parameters: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{},
+		},
+		Handler: toolCurrentTime,
+	})
+
+	registry.Register(&Tool{
+		Name:        "listar_membros_grupo",
+		Description: "Lista os nomes dos membros do grupo atual. Só funciona em grupos.",
+		Parameters: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{},
+		},
+		Handler: toolGroupMembers,
+	})
+
+	registry.Register(&Tool{
+		Name:        "criar_lembrete",
+		Description: "Agenda um lembrete que o bot envia nesta conversa no horário pedido, mencionando quem pediu. Pode se repetir todo dia ou toda semana. Use quando o usuário pedir para ser lembrado de algo.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"quando": map[string]any{
+					"type":        "string",
+					"description": "Data e hora do lembrete no horário de Brasília, no formato AAAA-MM-DD HH:MM",
+				},
+				"texto": map[string]any{
+					"type":        "string",
+					"description": "Do que o usuário quer ser lembrado",
+				},
+				"repetir": map[string]any{
+					"type":        "string",
+					"description": "Repetição do lembrete: diario (todo dia no mesmo horário), semanal (toda semana no mesmo dia e horário) ou omitido para enviar uma vez só",
+				},
+			},
+			"required": []string{"quando", "texto"},
+		},
+		Handler: toolCreateReminder,
+	})
+
+	registry.Register(&Tool{
+		Name:        "executar_comando",
+		Description: "Executa um comando do bot no grupo atual, como se o usuário tivesse digitado !<comando> <argumentos>. Comandos permitidos: " + strings.Join(toolCommandNames(), ", ") + " e as ações com GIF (tapa, abraco, etc.). O próprio comando envia as mensagens no grupo. Só funciona em grupos.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"comando": map[string]any{
+					"type":        "string",
+					"description": "Nome do comando, sem o '!' (ex: piada)",
+				},
+				"argumentos": map[string]any{
+					"type":        "string",
+					"description": "Argumentos do comando separados por espaço (ex: programacao, ou @Fulano)",
+				},
+			},
+			"required": []string{"comando"},
+		},
+		Handler: toolRunCommand,
+	})
+
+	return registry
+}
+
+// toolCommands são os comandos que a IA pode executar (os demais exigem ação explícita do usuário)
+var toolCommands = map[string]bool{
+	"piada":        true,
+	"cantada":      true,
+	"historia":     true,
+	"história":     true,
+	"imagem":       true,
+	"img":          true,
+	"roletacasais": true,
+	"resumo":       true,
+	"help":         true,
+	"ajuda":        true,
+}
+
+// toolCommandNames retorna os nomes principais dos comandos permitidos na ferramenta executar_comando
+func toolCommandNames() []string {
+	return []string{"piada", "cantada", "historia", "imagem", "roletacasais", "resumo", "help"}
+}
+
+// toolCurrentTime retorna a data e a hora atuais
+func toolCurrentTime(ctx context.Context, tc *ToolContext, args map[string]any) (map[string]any, error) {
+	now := time.Now().In(botLocation)
+	return map[string]any{
+		"data_hora":  now.Format(toolReminderLayout),
+		"dia_semana": weekdayNames[now.Weekday()],
+		"fuso":       "UTC-03:00",
+	}, nil
+}
+
+// toolGroupMembers lista os nomes dos membros do grupo da conversa
+func toolGroupMembers(ctx context.Context, tc *ToolContext, args map[string]any) (map[string]any, error) {
+	chat := tc.Event.Info.Chat
+	if chat.Server != types.GroupServer {
+		return nil, fmt.Errorf("esta conversa não é um grupo")
+	}
+
+	groupInfo, err := tc.Bot.WAClient.GetGroupInfo(ctx, chat)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao obter informações do grupo: %w", err)
+	}
+
+	ch := tc.Bot.groupProcessor.commandHandler
+	botJID := tc.Bot.WAClient.Store.ID.ToNonAD().String()
+
+	members := []string{}
+	for _, participant := range groupInfo.Participants {
+		if participant.JID.ToNonAD().String() == botJID {
+			continue
+		}
+		name := ch.getParticipantName(ctx, participant.JID, tc.Bot)
+		if name == "" || ch.isOnlyNumber(name) {
+			continue
+		}
+		members = append(members, name)
+	}
+
+	return map[string]any{
+		"grupo":   groupInfo.Name,
+		"total":   len(groupInfo.Participants),
+		"membros": members,
+	}, nil
+}
+
+// toolCreateReminder agenda um lembrete na conversa atual (o mesmo do !lembrete, salvo no SQLite)
+func toolCreateReminder(ctx context.Context, tc *ToolContext, args map[string]any) (map[string]any, error) {
+	when, _ := args["quando"].(string)
+	text, _ := args["texto"].(string)
+	recurrence, _ := args["repetir"].(string)
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("o texto do lembrete está vazio")
+	}
+
+	switch recurrence {
+	case reminderOnce, reminderDaily, reminderWeekly:
+	default:
+		return nil, fmt.Errorf("repetição inválida %q, use \"diario\", \"semanal\" ou deixe vazio", recurrence)
+	}
+
+	remindAt, err := time.ParseInLocation(toolReminderLayout, strings.TrimSpace(when), botLocation)
+	if err != nil {
+		return nil, fmt.Errorf("data/hora inválida %q, use o formato AAAA-MM-DD HH:MM", when)
+	}
+	if !remindAt.After(time.Now()) {
+		return nil, fmt.Errorf("o horário %s já passou", remindAt.Format(toolReminderLayout))
+	}
+
+	reminder := Reminder{
+		ChatJID:    tc.Event.Info.Chat.String(),
+		CreatorJID: tc.Event.Info.Sender.ToNonAD().String(),
+		Text:       text,
+		RemindAt:   remindAt,
+		Recurrence: recurrence,
+	}
+
+	count, err := tc.Bot.chatContext.CountActiveReminders(ctx, reminder.ChatJID, reminder.CreatorJID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= reminderMaxActive {
+		return nil, fmt.Errorf("o usuário já tem %d lembretes ativos nesta conversa; ele pode cancelar algum com !cancelarlembrete <número>", count)
+	}
+
+	reminder.ID, err = tc.Bot.chatContext.SaveReminder(ctx, reminder)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Int64("id", reminder.ID).
+		Str("chat", reminder.ChatJID).
+		Str("creator", reminder.CreatorJID).
+		Time("remindAt", remindAt).
+		Str("recurrence", recurrence).
+		Msg("Lembrete agendado pela IA")
+
+	result := map[string]any{
+		"id":            reminder.ID,
+		"agendado_para": remindAt.Format(toolReminderLayout),
+		"dia_semana":    weekdayNames[remindAt.Weekday()],
+		"texto":         text,
+		"cancelar_com":  fmt.Sprintf("!cancelarlembrete %d", reminder.ID),
+	}
+	if recurrence != reminderOnce {
+		result["repete"] = formatReminderRecurrence(reminder)
+	}
+	return result, nil
+}
+
+// toolRunCommand executa um comando do bot no grupo da conversa
+func toolRunCommand(ctx context.Context, tc *ToolContext, args map[string]any) (map[string]any, error) {
+	if tc.Event.Info.Chat.Server != types.GroupServer {
+		return nil, fmt.Errorf("comandos só podem ser executados em grupos")
+	}
+
+	command, _ := args["comando"].(string)
+	command = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(command), "!"))
+	argText, _ := args["argumentos"].(string)
+
+	ch := tc.Bot.groupProcessor.commandHandler
+	if _, isAction := ch.actionIndex[command]; !toolCommands[command] && !isAction {
+		return nil, fmt.Errorf("o comando %q não pode ser executado pela IA", command)
+	}
+
+	err := ch.ProcessCommand(ctx, command, strings.Fields(argText), tc.Event, tc.Bot)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao executar !%s: %w", command, err)
+	}
+
+	return map[string]any{
+		"executado": true,
+		"comando":   "!" + strings.TrimSpace(command+" "+argText),
+	}, nil
+}
@@ -0,0 +1,767 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ErrLLMQuotaExceeded é retornado sem chamar o provedor quando o grupo esgotou a cota mensal de tokens
+var ErrLLMQuotaExceeded = errors.New("cota mensal de IA esgotada")
+
+// aiQuotaExceededMessage é o aviso enviado ao grupo que esgotou a cota mensal
+const aiQuotaExceededMessage = "⚠️ A cota mensal de IA deste grupo acabou. Ela renova no dia 1º do próximo mês.\nOs comandos sem IA (!tapa, !roletacasais, !lembrete, !help...) continuam funcionando."
+
+// usageTopLimit é quantos chats e usuários aparecem nos rankings do !uso
+const usageTopLimit = 5
+
+// maxTokenQuota é a maior cota mensal aceita pelo !uso cota (1 trilhão de tokens)
+const maxTokenQuota = 1_000_000_000_000
+
+// modelPrice é o preço de um modelo em dólares por milhão de tokens
+type modelPrice struct {
+	Input  float64
+	Output float64 // Também cobrado pelos tokens de raciocínio
+}
+
+// modelPrices são os preços de tabela dos modelos Gemini (nível pago, prompts até 200 mil tokens)
+// O modelo é comparado pelo prefixo mais longo, então versões preview usam o preço da família
+var modelPrices = map[string]modelPrice{
+	"gemini-2.5-pro":         {Input: 1.25, Output: 10.00},
+	"gemini-2.5-flash":       {Input: 0.30, Output: 2.50},
+	"gemini-2.5-flash-lite":  {Input: 0.10, Output: 0.40},
+	"gemini-2.5-flash-image": {Input: 0.30, Output: 30.00},
+	"gemini-2.0-flash":       {Input: 0.10, Output: 0.40},
+	"gemini-2.0-flash-lite":  {Input: 0.075, Output: 0.30},
+	"gemini-1.5-pro":         {Input: 1.25, Output: 5.00},
+	"gemini-1.5-flash":       {Input: 0.075, Output: 0.30},
+	"gemini-1.5-flash-8b":    {Input: 0.0375, Output: 0.15},
+}
+
+// priceForModel retorna o preço do modelo (false para modelos sem preço conhecido, ex: locais)
+func priceForModel(model string) (modelPrice, bool) {
+	best := ""
+	for prefix := range modelPrices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return modelPrice{}, false
+	}
+	return modelPrices[best], true
+}
+
+// UsageRecord é o uso de uma chamada ao provedor de IA
+type UsageRecord struct {
+	ChatJID   string
+	UserJID   string
+	Command   string
+	Model     string
+	Usage     LLMUsage
+	Latency   time.Duration
+	CreatedAt time.Time
+}
+
+// UsageTotals soma o uso de várias chamadas
+type UsageTotals struct {
+	Key             string // Chat, usuário ou comando do agrupamento (vazio no total)
+	Calls           int64
+	PromptTokens    int64
+	CandidateTokens int64
+	ThinkingTokens  int64
+	TotalTokens     int64
+	Latency         time.Duration // Soma das latências
+	Cost            float64       // Custo estimado em dólares
+	Unpriced        bool          // Algum modelo sem preço conhecido entrou na soma
+}
+
+// initUsageTables cria as tabelas de uso da IA e de cotas dos grupos
+func (c *ChatContext) initUsageTables() error {
+	// created_at é guardado em segundos Unix para somar por dia e por mês direto no SQL
+	createUsageTableQuery := `
+		CREATE TABLE IF NOT EXISTS llm_usage (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_jid TEXT NOT NULL,
+			user_jid TEXT NOT NULL DEFAULT '',
+			command TEXT NOT NULL DEFAULT '',
+			model TEXT NOT NULL,
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			candidate_tokens INTEGER NOT NULL DEFAULT 0,
+			thinking_tokens INTEGER NOT NULL DEFAULT 0,
+			total_tokens INTEGER NOT NULL DEFAULT 0,
+			latency_ms INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL
+		);
+	`
+
+	_, err := c.db.Exec(createUsageTableQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar tabela llm_usage: %w", err)
+	}
+
+	createUsageIndexQuery := `
+		CREATE INDEX IF NOT EXISTS idx_llm_usage_chat_time
+		ON llm_usage (chat_jid, created_at);
+	`
+
+	_, err = c.db.Exec(createUsageIndexQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar índice llm_usage: %w", err)
+	}
+
+	createUsageTimeIndexQuery := `
+		CREATE INDEX IF NOT EXISTS idx_llm_usage_time
+		ON llm_usage (created_at);
+	`
+
+	_, err = c.db.Exec(createUsageTimeIndexQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar índice llm_usage por data: %w", err)
+	}
+
+	createQuotasTableQuery := `
+		CREATE TABLE IF NOT EXISTS llm_quotas (
+			chat_jid TEXT PRIMARY KEY,
+			monthly_tokens INTEGER NOT NULL,
+			updated_by TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+	`
+
+	_, err = c.db.Exec(createQuotasTableQuery)
+	if err != nil {
+		return fmt.Errorf("erro ao criar tabela llm_quotas: %w", err)
+	}
+
+	return nil
+}
+
+// SaveLLMUsage registra o uso de uma chamada à IA
+func (c *ChatContext) SaveLLMUsage(ctx context.Context, record UsageRecord) error {
+	query := `
+		INSERT INTO llm_usage (chat_jid, user_jid, command, model, prompt_tokens, candidate_tokens,
+			thinking_tokens, total_tokens, latency_ms, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := c.db.ExecContext(ctx, query, record.ChatJID, record.UserJID, record.Command, record.Model,
+		record.Usage.PromptTokens, record.Usage.CandidateTokens, record.Usage.ThinkingTokens,
+		record.Usage.TotalTokens, record.Latency.Milliseconds(), record.CreatedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("erro ao salvar uso da IA: %w", err)
+	}
+
+	return nil
+}
+
+// UsageTotals soma o uso da IA desde o instante informado, ordenado do maior consumo para o menor
+// groupBy agrupa por "chat_jid", "user_jid" ou "command" (vazio: um único total)
+// chatJID vazio considera todos os chats
+func (c *ChatContext) UsageTotals(ctx context.Context, groupBy, chatJID string, since time.Time) ([]UsageTotals, error) {
+	key := "''"
+	switch groupBy {
+	case "":
+	case "chat_jid", "user_jid", "command":
+		key = groupBy
+	default:
+		return nil, fmt.Errorf("agrupamento de uso inválido: %s", groupBy)
+	}
+
+	query := `
+		SELECT ` + key + `, model, COUNT(*), SUM(prompt_tokens), SUM(candidate_tokens),
+			SUM(thinking_tokens), SUM(total_tokens), SUM(latency_ms)
+		FROM llm_usage
+		WHERE created_at >= ?`
+	queryArgs := []any{since.Unix()}
+	if chatJID != "" {
+		query += ` AND chat_jid = ?`
+		queryArgs = append(queryArgs, chatJID)
+	}
+	query += ` GROUP BY 1, 2`
+
+	rows, err := c.db.QueryContext(ctx, query, queryArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao somar uso da IA: %w", err)
+	}
+	defer rows.Close()
+
+	// O custo depende do modelo: somar por modelo e juntar no agrupamento pedido
+	totals := make(map[string]*UsageTotals)
+	for rows.Next() {
+		var keyValue, model string
+		var row UsageTotals
+		var latencyMs int64
+		err := rows.Scan(&keyValue, &model, &row.Calls, &row.PromptTokens, &row.CandidateTokens,
+			&row.ThinkingTokens, &row.TotalTokens, &latencyMs)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler uso da IA: %w", err)
+		}
+
+		total, ok := totals[keyValue]
+		if !ok {
+			total = &UsageTotals{Key: keyValue}
+			totals[keyValue] = total
+		}
+		total.Calls += row.Calls
+		total.PromptTokens += row.PromptTokens
+		total.CandidateTokens += row.CandidateTokens
+		total.ThinkingTokens += row.ThinkingTokens
+		total.TotalTokens += row.TotalTokens
+		total.Latency += time.Duration(latencyMs) * time.Millisecond
+
+		if price, ok := priceForModel(model); ok {
+			total.Cost += (float64(row.PromptTokens)*price.Input +
+				float64(row.CandidateTokens+row.ThinkingTokens)*price.Output) / 1_000_000
+		} else if row.TotalTokens > 0 {
+			total.Unpriced = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao percorrer uso da IA: %w", err)
+	}
+
+	result := make([]UsageTotals, 0, len(totals))
+	for _, total := range totals {
+		result = append(result, *total)
+	}
+	slices.SortFunc(result, func(a, b UsageTotals) int {
+		return cmp.Or(cmp.Compare(b.TotalTokens, a.TotalTokens), strings.Compare(a.Key, b.Key))
+	})
+	return result, nil
+}
+
+// ChatTokensSince soma os tokens consumidos por um chat desde o instante informado
+func (c *ChatContext) ChatTokensSince(ctx context.Context, chatJID string, since time.Time) (int64, error) {
+	var tokens int64
+	query := `SELECT COALESCE(SUM(total_tokens), 0) FROM llm_usage WHERE chat_jid = ? AND created_at >= ?`
+	err := c.db.QueryRowContext(ctx, query, chatJID, since.Unix()).Scan(&tokens)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao somar tokens do chat: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// SetChatQuota define a cota mensal de tokens de um chat (0 = sem limite)
+func (c *ChatContext) SetChatQuota(ctx context.Context, chatJID string, monthlyTokens int64, updatedBy string) error {
+	query := `
+		INSERT INTO llm_quotas (chat_jid, monthly_tokens, updated_by, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(chat_jid) DO UPDATE SET
+			monthly_tokens = excluded.monthly_tokens,
+			updated_by = excluded.updated_by,
+			updated_at = excluded.updated_at
+	`
+
+	_, err := c.db.ExecContext(ctx, query, chatJID, monthlyTokens, updatedBy)
+	if err != nil {
+		return fmt.Errorf("erro ao salvar cota do chat: %w", err)
+	}
+
+	return nil
+}
+
+// ClearChatQuota faz o chat voltar à cota padrão (flag -groupquota)
+func (c *ChatContext) ClearChatQuota(ctx context.Context, chatJID string) (bool, error) {
+	result, err := c.db.ExecContext(ctx, `DELETE FROM llm_quotas WHERE chat_jid = ?`, chatJID)
+	if err != nil {
+		return false, fmt.Errorf("erro ao remover cota do chat: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("erro ao verificar remoção da cota do chat: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// GetChatQuota retorna a cota mensal definida para o chat (false se usa a cota padrão)
+func (c *ChatContext) GetChatQuota(ctx context.Context, chatJID string) (int64, bool, error) {
+	var tokens int64
+	err := c.db.QueryRowContext(ctx, `SELECT monthly_tokens FROM llm_quotas WHERE chat_jid = ?`, chatJID).Scan(&tokens)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("erro ao buscar cota do chat: %w", err)
+	}
+
+	return tokens, true, nil
+}
+
+// UsageTracker registra o uso da IA e aplica as cotas mensais dos grupos
+type UsageTracker struct {
+	chatContext *ChatContext
+	groupQuota  int64 // Cota mensal padrão dos grupos em tokens (0 = sem limite)
+}
+
+// NewUsageTracker cria o registrador de uso com a cota mensal padrão dos grupos
+func NewUsageTracker(chatContext *ChatContext, groupQuota int64) *UsageTracker {
+	return &UsageTracker{
+		chatContext: chatContext,
+		groupQuota:  groupQuota,
+	}
+}
+
+// Record grava o uso de uma chamada; falhas só vão para o log
+func (u *UsageTracker) Record(ctx context.Context, record UsageRecord) {
+	err := u.chatContext.SaveLLMUsage(ctx, record)
+	if err != nil {
+		log.Error().Err(err).Str("chat", record.ChatJID).Msg("Erro ao registrar uso da IA")
+	}
+}
+
+// Quota retorna a cota mensal do chat e se ela foi definida para ele (false: cota padrão)
+// Só grupos têm cota: conversas privadas retornam 0 (sem limite)
+func (u *UsageTracker) Quota(ctx context.Context, chat types.JID) (int64, bool, error) {
+	if chat.Server != types.GroupServer {
+		return 0, false, nil
+	}
+
+	quota, custom, err := u.chatContext.GetChatQuota(ctx, chat.String())
+	if err != nil || custom {
+		return quota, custom, err
+	}
+	return u.groupQuota, false, nil
+}
+
+// CheckQuota retorna ErrLLMQuotaExceeded quando o grupo já consumiu a cota do mês
+// Erros do banco não bloqueiam a IA: a chamada segue e o erro vai para o log
+func (u *UsageTracker) CheckQuota(ctx context.Context, chat types.JID) error {
+	quota, _, err := u.Quota(ctx, chat)
+	if err != nil {
+		log.Warn().Err(err).Str("chat", chat.String()).Msg("Erro ao verificar cota de IA, liberando a chamada")
+		return nil
+	}
+	if quota <= 0 {
+		return nil
+	}
+
+	used, err := u.chatContext.ChatTokensSince(ctx, chat.String(), monthStart(time.Now()))
+	if err != nil {
+		log.Warn().Err(err).Str("chat", chat.String()).Msg("Erro ao verificar cota de IA, liberando a chamada")
+		return nil
+	}
+	if used >= quota {
+		return fmt.Errorf("%w: %d de %d tokens", ErrLLMQuotaExceeded, used, quota)
+	}
+	return nil
+}
+
+// dayStart retorna a meia-noite do dia do instante, no fuso do bot
+func dayStart(now time.Time) time.Time {
+	now = now.In(botLocation)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, botLocation)
+}
+
+// monthStart retorna o início do mês do instante, no fuso do bot (quando as cotas renovam)
+func monthStart(now time.Time) time.Time {
+	now = now.In(botLocation)
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, botLocation)
+}
+
+// aiCall identifica a origem de uma chamada à IA para a contabilidade de uso
+type aiCall struct {
+	chat    types.JID
+	user    types.JID
+	command string
+	tracker *UsageTracker
+}
+
+// aiCallContextKey é a chave da origem da chamada no contexto
+type aiCallContextKey struct{}
+
+// usageContext marca no contexto o chat, o autor e o comando da chamada à IA
+// Os provedores usam essa marca para registrar os tokens consumidos e o QuotaProvider para aplicar a cota
+func (bot *BotClient) usageContext(ctx context.Context, evt *events.Message, command string) context.Context {
+	if bot.usage == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, aiCallContextKey{}, &aiCall{
+		chat:    evt.Info.Chat,
+		user:    evt.Info.Sender.ToNonAD(),
+		command: command,
+		tracker: bot.usage,
+	})
+}
+
+// aiCallFromContext retorna a origem da chamada (nil quando não foi marcada)
+func aiCallFromContext(ctx context.Context) *aiCall {
+	call, _ := ctx.Value(aiCallContextKey{}).(*aiCall)
+	return call
+}
+
+// recordUsage registra os tokens de uma chamada ao provedor, se ela veio de um comando do bot
+// Chamado pelos provedores a cada requisição, inclusive novas tentativas e fallbacks
+func recordUsage(ctx context.Context, model string, usage LLMUsage, latency time.Duration) {
+	call := aiCallFromContext(ctx)
+	if call == nil || call.tracker == nil {
+		return
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CandidateTokens + usage.ThinkingTokens
+	}
+	if usage.TotalTokens == 0 {
+		return
+	}
+
+	// O registro não deve se perder se o comando for cancelado logo depois da resposta
+	call.tracker.Record(context.WithoutCancel(ctx), UsageRecord{
+		ChatJID:   call.chat.String(),
+		UserJID:   call.user.String(),
+		Command:   call.command,
+		Model:     model,
+		Usage:     usage,
+		Latency:   latency,
+		CreatedAt: time.Now(),
+	})
+}
+
+// checkQuota aplica a cota do grupo de onde a chamada veio (chamadas sem origem não têm cota)
+func checkQuota(ctx context.Context) error {
+	call := aiCallFromContext(ctx)
+	if call == nil || call.tracker == nil {
+		return nil
+	}
+	return call.tracker.CheckQuota(ctx, call.chat)
+}
+
+// QuotaProvider recusa as chamadas dos grupos que esgotaram a cota mensal de tokens
+type QuotaProvider struct {
+	inner LLMProvider
+}
+
+// NewQuotaProvider envolve o provedor com a verificação de cota
+func NewQuotaProvider(inner LLMProvider) *QuotaProvider {
+	return &QuotaProvider{inner: inner}
+}
+
+// Unwrap retorna o provedor envolvido
+func (q *QuotaProvider) Unwrap() LLMProvider {
+	return q.inner
+}
+
+// Name identifica o provedor nos logs
+func (q *QuotaProvider) Name() string {
+	return q.inner.Name()
+}
+
+// GetModel retorna o modelo atual
+func (q *QuotaProvider) GetModel() string {
+	return q.inner.GetModel()
+}
+
+// SetModel troca o modelo
+func (q *QuotaProvider) SetModel(model string) {
+	q.inner.SetModel(model)
+}
+
+// GenerateContent gera uma resposta se o grupo ainda tiver cota
+func (q *QuotaProvider) GenerateContent(ctx context.Context, prompt string) (string, error) {
+	return resultText(q.Generate(ctx, prompt, nil))
+}
+
+// GenerateContentWithHistory gera uma resposta com histórico se o grupo ainda tiver cota
+func (q *QuotaProvider) GenerateContentWithHistory(ctx context.Context, prompt string, history []ChatMessage) (string, error) {
+	return resultText(q.Generate(ctx, prompt, history))
+}
+
+// Generate gera a resposta completa se o grupo ainda tiver cota
+func (q *QuotaProvider) Generate(ctx context.Context, prompt string, history []ChatMessage) (*LLMResult, error) {
+	if err := checkQuota(ctx); err != nil {
+		return nil, err
+	}
+	return q.inner.Generate(ctx, prompt, history)
+}
+
+// CountTokens conta os tokens (a contagem não consome cota)
+func (q *QuotaProvider) CountTokens(ctx context.Context, text string) (int, error) {
+	return q.inner.CountTokens(ctx, text)
+}
+
+// EmbeddingModel retorna o modelo de embeddings do provedor envolvido (vazio se ele não gera embeddings)
+func (q *QuotaProvider) EmbeddingModel() string {
+	embedder, ok := embedderOf(q.inner)
+	if !ok {
+		return ""
+	}
+	return embedder.EmbeddingModel()
+}
+
+// Embed gera os embeddings se o grupo ainda tiver cota
+func (q *QuotaProvider) Embed(ctx context.Context, texts []string, query bool) ([][]float32, error) {
+	embedder, ok := embedderOf(q.inner)
+	if !ok {
+		return nil, fmt.Errorf("o provedor %s não gera embeddings", q.Name())
+	}
+	if err := checkQuota(ctx); err != nil {
+		return nil, err
+	}
+	return embedder.Embed(ctx, texts, query)
+}
+
+// GenerateContentStream gera a resposta em streaming se o grupo ainda tiver cota
+func (q *QuotaProvider) GenerateContentStream(ctx context.Context, prompt string) iter.Seq2[string, error] {
+	return q.stream(ctx, func() iter.Seq2[string, error] {
+		return q.inner.GenerateContentStream(ctx, prompt)
+	})
+}
+
+// GenerateContentStreamWithTools gera a resposta em streaming com ferramentas se o grupo ainda tiver cota
+func (q *QuotaProvider) GenerateContentStreamWithTools(ctx context.Context, prompt string, tools *ToolRegistry, toolCtx *ToolContext) iter.Seq2[string, error] {
+	return q.stream(ctx, func() iter.Seq2[string, error] {
+		return streamWithTools(ctx, q.inner, prompt, tools, toolCtx)
+	})
+}
+
+// stream verifica a cota antes de abrir o streaming
+func (q *QuotaProvider) stream(ctx context.Context, open func() iter.Seq2[string, error]) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		if err := checkQuota(ctx); err != nil {
+			yield("", err)
+			return
+		}
+		for text, err := range open() {
+			if !yield(text, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// parseTokenAmount interpreta quantidades de tokens como "500000", "500k", "500mil", "1.5m" ou "2mi"
+// Rejeita valores negativos, infinitos, NaN e acima de maxTokenQuota
+func parseTokenAmount(text string) (int64, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	multiplier := 1.0
+	for _, suffix := range []struct {
+		text  string
+		value float64
+	}{{"mil", 1e3}, {"mi", 1e6}, {"k", 1e3}, {"m", 1e6}} {
+		if number, ok := strings.CutSuffix(text, suffix.text); ok {
+			text, multiplier = strings.TrimSpace(number), suffix.value
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(text, ",", "."), 64)
+	if err != nil || math.IsInf(value, 0) || math.IsNaN(value) || value < 0 {
+		return 0, fmt.Errorf("quantidade de tokens inválida: %s", text)
+	}
+	value *= multiplier
+	if value > maxTokenQuota {
+		return 0, fmt.Errorf("quantidade de tokens acima do máximo: %s", text)
+	}
+	return int64(value), nil
+}
+
+// formatTokenCount formata uma quantidade de tokens de forma curta (ex: 950, 34,5 mil, 1,25 mi)
+func formatTokenCount(tokens int64) string {
+	switch {
+	case tokens < 1_000:
+		return strconv.FormatInt(tokens, 10)
+	case tokens < 1_000_000:
+		return strings.ReplaceAll(fmt.Sprintf("%.1f mil", float64(tokens)/1e3), ".", ",")
+	default:
+		return strings.ReplaceAll(fmt.Sprintf("%.2f mi", float64(tokens)/1e6), ".", ",")
+	}
+}
+
+// formatUsageTotals descreve um total de uso em uma linha: chamadas, tokens, custo e latência média
+func formatUsageTotals(total UsageTotals) string {
+	if total.Calls == 0 {
+		return "nenhuma chamada"
+	}
+
+	cost := strings.ReplaceAll(fmt.Sprintf("~US$ %.4f", total.Cost), ".", ",")
+	if total.Unpriced {
+		cost += " (há modelos sem preço)"
+	}
+	latency := strings.ReplaceAll(fmt.Sprintf("%.1fs", (total.Latency/time.Duration(total.Calls)).Seconds()), ".", ",")
+	return fmt.Sprintf("%d chamadas · %s tokens · %s · %s em média",
+		total.Calls, formatTokenCount(total.TotalTokens), cost, latency)
+}
+
+// handleUsoCommand mostra o consumo da IA e ajusta a cota mensal do grupo (só administradores)
+// Uso: !uso | !uso cota <tokens> | !uso cota padrao
+func (ch *CommandHandler) handleUsoCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
+	if !bot.requireAdmin(ctx, evt) {
+		return nil
+	}
+
+	if bot.usage == nil {
+		return ch.sendReplyMessage(ctx, "❌ A contabilidade de uso da IA não está disponível.", nil, evt, bot)
+	}
+
+	if len(args) > 0 && strings.ToLower(args[0]) == "cota" {
+		return ch.handleUsoCotaCommand(ctx, args[1:], evt, bot)
+	}
+
+	now := time.Now()
+	today, month := dayStart(now), monthStart(now)
+	isGroup := evt.Info.Chat.Server == types.GroupServer
+	chatJID := evt.Info.Chat.String()
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "📊 *Uso da IA* (%s)\n", now.In(botLocation).Format("02/01/2006"))
+
+	if isGroup {
+		text.WriteString("\n*Este grupo*\n")
+		fmt.Fprintf(&text, "Hoje: %s\n", formatUsageTotals(bot.usageTotal(ctx, chatJID, today)))
+		fmt.Fprintf(&text, "Mês: %s\n", formatUsageTotals(bot.usageTotal(ctx, chatJID, month)))
+		text.WriteString(bot.quotaDescription(ctx, evt.Info.Chat, month))
+
+		if commands, err := bot.chatContext.UsageTotals(ctx, "command", chatJID, month); err != nil {
+			log.Error().Err(err).Msg("Erro ao somar uso da IA por comando")
+		} else if len(commands) > 0 {
+			text.WriteString("\n*Por comando no mês*\n")
+			for _, command := range commands {
+				fmt.Fprintf(&text, "• %s: %s tokens\n", command.Key, formatTokenCount(command.TotalTokens))
+			}
+		}
+
+		if users, err := bot.chatContext.UsageTotals(ctx, "user_jid", chatJID, month); err != nil {
+			log.Error().Err(err).Msg("Erro ao somar uso da IA por usuário")
+		} else if len(users) > 0 {
+			text.WriteString("\n*Quem mais usou no mês*\n")
+			for _, user := range users[:min(len(users), usageTopLimit)] {
+				fmt.Fprintf(&text, "• %s: %s tokens\n", usageJIDName(ctx, bot, user.Key), formatTokenCount(user.TotalTokens))
+			}
+		}
+	}
+
+	text.WriteString("\n*Todos os chats*\n")
+	fmt.Fprintf(&text, "Hoje: %s\n", formatUsageTotals(bot.usageTotal(ctx, "", today)))
+	fmt.Fprintf(&text, "Mês: %s\n", formatUsageTotals(bot.usageTotal(ctx, "", month)))
+
+	if chats, err := bot.chatContext.UsageTotals(ctx, "chat_jid", "", month); err != nil {
+		log.Error().Err(err).Msg("Erro ao somar uso da IA por chat")
+	} else if len(chats) > 0 {
+		text.WriteString("\n*Chats que mais usaram no mês*\n")
+		for _, chat := range chats[:min(len(chats), usageTopLimit)] {
+			fmt.Fprintf(&text, "• %s: %s tokens · %s\n", usageJIDName(ctx, bot, chat.Key),
+				formatTokenCount(chat.TotalTokens), strings.ReplaceAll(fmt.Sprintf("~US$ %.4f", chat.Cost), ".", ","))
+		}
+	}
+
+	if isGroup {
+		text.WriteString("\n!uso cota <tokens> - definir a cota mensal deste grupo (0 = sem limite)\n!uso cota padrao - voltar à cota padrão")
+	}
+
+	_, err := bot.chunker.Send(ctx, evt.Info.Chat, evt.Info.Sender, strings.TrimSpace(text.String()), nil)
+	return err
+}
+
+// handleUsoCotaCommand define ou remove a cota mensal de tokens do grupo
+func (ch *CommandHandler) handleUsoCotaCommand(ctx context.Context, args []string, evt *events.Message, bot *BotClient) error {
+	if evt.Info.Chat.Server != types.GroupServer {
+		return ch.sendReplyMessage(ctx, "❌ As cotas valem só para grupos. Use o comando dentro do grupo.", nil, evt, bot)
+	}
+
+	chatJID := evt.Info.Chat.String()
+	if len(args) == 0 {
+		return ch.sendReplyMessage(ctx, strings.TrimSpace(bot.quotaDescription(ctx, evt.Info.Chat, monthStart(time.Now())))+
+			"\n\nUse: !uso cota <tokens> (ex: 500mil, 2mi; 0 = sem limite) ou !uso cota padrao", nil, evt, bot)
+	}
+
+	if value := strings.ToLower(args[0]); value == "padrao" || value == "padrão" {
+		removed, err := bot.chatContext.ClearChatQuota(ctx, chatJID)
+		if err != nil {
+			log.Error().Err(err).Msg("Erro ao remover cota do grupo")
+			return ch.sendReplyMessage(ctx, "❌ Erro ao voltar à cota padrão.", nil, evt, bot)
+		}
+		if !removed {
+			return ch.sendReplyMessage(ctx, "ℹ️ Este grupo já usa a cota padrão.", nil, evt, bot)
+		}
+		log.Info().Str("chat", chatJID).Str("by", evt.Info.Sender.String()).Msg("Grupo voltou à cota padrão de IA")
+		return ch.sendReplyMessage(ctx, "✅ Este grupo voltou à cota padrão.", nil, evt, bot)
+	}
+
+	tokens, err := parseTokenAmount(strings.Join(args, ""))
+	if err != nil {
+		return ch.sendReplyMessage(ctx, "❌ Quantidade inválida. Exemplos: !uso cota 500000, !uso cota 500mil, !uso cota 2mi", nil, evt, bot)
+	}
+
+	err = bot.chatContext.SetChatQuota(ctx, chatJID, tokens, evt.Info.Sender.String())
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao salvar cota do grupo")
+		return ch.sendReplyMessage(ctx, "❌ Erro ao definir a cota do grupo.", nil, evt, bot)
+	}
+
+	log.Info().Str("chat", chatJID).Int64("tokens", tokens).Str("by", evt.Info.Sender.String()).Msg("Cota mensal de IA do grupo alterada")
+	if tokens == 0 {
+		return ch.sendReplyMessage(ctx, "✅ Este grupo agora não tem limite mensal de IA.", nil, evt, bot)
+	}
+	return ch.sendReplyMessage(ctx, fmt.Sprintf("✅ Cota mensal deste grupo: *%s tokens*.", formatTokenCount(tokens)), nil, evt, bot)
+}
+
+// usageTotal soma o uso de um chat (ou de todos) desde o instante informado; erros vão para o log
+func (bot *BotClient) usageTotal(ctx context.Context, chatJID string, since time.Time) UsageTotals {
+	totals, err := bot.chatContext.UsageTotals(ctx, "", chatJID, since)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao somar uso da IA")
+	}
+	if len(totals) == 0 {
+		return UsageTotals{}
+	}
+	return totals[0]
+}
+
+// quotaDescription descreve a cota mensal do grupo e quanto dela já foi usado
+func (bot *BotClient) quotaDescription(ctx context.Context, chat types.JID, month time.Time) string {
+	quota, custom, err := bot.usage.Quota(ctx, chat)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao buscar cota do grupo")
+		return "Cota mensal: indisponível\n"
+	}
+
+	origin := "padrão"
+	if custom {
+		origin = "deste grupo"
+	}
+	if quota <= 0 {
+		return fmt.Sprintf("Cota mensal: sem limite (%s)\n", origin)
+	}
+
+	used, err := bot.chatContext.ChatTokensSince(ctx, chat.String(), month)
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao somar tokens do grupo")
+	}
+	status := ""
+	if used >= quota {
+		status = " ⛔ esgotada"
+	}
+	return fmt.Sprintf("Cota mensal: %s de %s tokens (%d%%, %s)%s\n",
+		formatTokenCount(used), formatTokenCount(quota), used*100/quota, origin, status)
+}
+
+// usageJIDName descreve um chat ou usuário do ranking: nome do grupo ou número de telefone
+func usageJIDName(ctx context.Context, bot *BotClient, jid string) string {
+	parsed, err := types.ParseJID(jid)
+	if err != nil || jid == "" {
+		return jid
+	}
+	if parsed.Server == types.GroupServer {
+		if info, err := bot.WAClient.GetGroupInfo(ctx, parsed); err == nil && info.Name != "" {
+			return info.Name
+		}
+		return jid
+	}
+	if parsed.Server == types.DefaultUserServer {
+		return "+" + parsed.User
+	}
+	return parsed.User
+}
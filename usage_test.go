@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestParseTokenAmount(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "500000", want: 500_000},
+		{input: "500mil", want: 500_000},
+		{input: "500 mil", want: 500_000},
+		{input: "500k", want: 500_000},
+		{input: "2mi", want: 2_000_000},
+		{input: "1.5m", want: 1_500_000},
+		{input: "1,5k", want: 1_500},
+		{input: "0", want: 0},
+		{input: "1000000mi", want: maxTokenQuota},
+		{input: "1000001mi", wantErr: true},
+		{input: "inf", wantErr: true},
+		{input: "-inf", wantErr: true},
+		{input: "infmi", wantErr: true},
+		{input: "nan", wantErr: true},
+		{input: "-1", wantErr: true},
+		{input: "1e30", wantErr: true},
+		{input: "1e30k", wantErr: true},
+		{input: "muito", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseTokenAmount(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseTokenAmount(%q) = %d, esperava erro", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTokenAmount(%q) erro inesperado: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseTokenAmount(%q) = %d, esperava %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}